@@ -0,0 +1,361 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+	"github.com/vNodesV/vProx/internal/geo"
+	"github.com/vNodesV/vProx/internal/limit"
+	applog "github.com/vNodesV/vProx/internal/logging"
+)
+
+// --------------------- ACCESS LISTS ---------------------
+
+// AccessCfg is an [access] table of CIDR (or single IP) allow and deny lists
+// plus country/ASN rules, either per chain or global in
+// $configDir/access.toml. For CIDRs the most specific matching entry decides
+// (deny wins a tie); an address matching nothing is refused only when an
+// allow list is set. The global table is checked first, then the chain of
+// the request's host, before the limiter and routing.
+//
+// The client address is the connection's peer; proxy headers are only
+// believed when the peer is in TrustedProxies (see accessClientIP).
+type AccessCfg struct {
+	Allow          []string  `toml:"allow"`
+	Deny           []string  `toml:"deny"`
+	Geo            []GeoRule `toml:"geo"`
+	TrustedProxies []string  `toml:"trusted_proxies"` // CIDRs/IPs whose CF-Connecting-IP / X-Forwarded-For are believed
+
+	rules    []accessRule   // longest prefix first, built by compile
+	trusted  []netip.Prefix // TrustedProxies, built by compile
+	geoAllow bool           // some Geo rule is action = "allow"
+}
+
+// GeoRule is one [[access.geo]] rule. It matches a client whose country is
+// in Countries and whose ASN is in ASNs (an empty list matches any). The
+// first matching rule of a table applies:
+//   - deny:  refuse with 403 (geo-denied)
+//   - limit: rate-limit the client at RPS/Burst in its own bucket (geo-limited)
+//   - allow: accept; once a table has allow rules, clients matching none of
+//     its rules are refused
+type GeoRule struct {
+	Name      string   `toml:"name"`
+	Countries []string `toml:"countries"` // ISO-3166 alpha-2, e.g. "US"
+	ASNs      []string `toml:"asns"`      // "AS16509" or "16509"
+	Action    string   `toml:"action"`    // deny | limit | allow
+	RPS       float64  `toml:"rps"`
+	Burst     int      `toml:"burst"`
+}
+
+type accessRule struct {
+	prefix netip.Prefix
+	allow  bool
+}
+
+// compile parses the lists; field names the table in errors.
+func (a *AccessCfg) compile(field string) error {
+	a.rules = a.rules[:0]
+	add := func(list []string, allow bool, key string) error {
+		for _, s := range list {
+			p, err := parsePrefix(s)
+			if err != nil {
+				return fmt.Errorf("%s.%s: %w", field, key, err)
+			}
+			a.rules = append(a.rules, accessRule{prefix: p, allow: allow})
+		}
+		return nil
+	}
+	if err := add(a.Allow, true, "allow"); err != nil {
+		return err
+	}
+	if err := add(a.Deny, false, "deny"); err != nil {
+		return err
+	}
+	a.trusted = a.trusted[:0]
+	for _, s := range a.TrustedProxies {
+		p, err := parsePrefix(s)
+		if err != nil {
+			return fmt.Errorf("%s.trusted_proxies: %w", field, err)
+		}
+		a.trusted = append(a.trusted, p)
+	}
+	sort.SliceStable(a.rules, func(i, j int) bool {
+		if a.rules[i].prefix.Bits() != a.rules[j].prefix.Bits() {
+			return a.rules[i].prefix.Bits() > a.rules[j].prefix.Bits()
+		}
+		return !a.rules[i].allow && a.rules[j].allow
+	})
+
+	a.geoAllow = false
+	for i := range a.Geo {
+		g := &a.Geo[i]
+		key := fmt.Sprintf("%s.geo[%d]", field, i)
+		if strings.TrimSpace(g.Name) == "" {
+			g.Name = key
+		}
+		for j, c := range g.Countries {
+			c = strings.ToUpper(strings.TrimSpace(c))
+			if len(c) != 2 {
+				return fmt.Errorf("%s.countries: invalid country code %q", key, g.Countries[j])
+			}
+			g.Countries[j] = c
+		}
+		for j, asn := range g.ASNs {
+			asn = strings.ToUpper(strings.TrimSpace(asn))
+			if !strings.HasPrefix(asn, "AS") {
+				asn = "AS" + asn
+			}
+			if _, err := strconv.ParseUint(asn[2:], 10, 32); err != nil {
+				return fmt.Errorf("%s.asns: invalid ASN %q", key, g.ASNs[j])
+			}
+			g.ASNs[j] = asn
+		}
+		if len(g.Countries) == 0 && len(g.ASNs) == 0 {
+			return fmt.Errorf("%s: countries or asns is required", key)
+		}
+		g.Action = strings.ToLower(strings.TrimSpace(g.Action))
+		switch g.Action {
+		case "deny":
+		case "allow":
+			a.geoAllow = true
+		case "limit":
+			if g.RPS <= 0 || g.Burst < 1 {
+				return fmt.Errorf("%s: limit needs rps > 0 and burst >= 1", key)
+			}
+		default:
+			return fmt.Errorf("%s.action must be deny, limit or allow, got %q", key, g.Action)
+		}
+	}
+	return nil
+}
+
+// parsePrefix parses a CIDR or a single IP (as a host prefix).
+func parsePrefix(s string) (netip.Prefix, error) {
+	s = strings.TrimSpace(s)
+	p, err := netip.ParsePrefix(s)
+	if err != nil {
+		addr, aerr := netip.ParseAddr(s)
+		if aerr != nil {
+			return netip.Prefix{}, fmt.Errorf("invalid CIDR or IP %q", s)
+		}
+		p = netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen())
+	}
+	return p.Masked(), nil
+}
+
+func (a *AccessCfg) active() bool { return a != nil && (len(a.rules) > 0 || len(a.Geo) > 0) }
+
+// matches reports whether the rule covers a client in country cc / asn.
+func (g *GeoRule) matches(cc, asn string) bool {
+	return (len(g.Countries) == 0 || slices.Contains(g.Countries, cc)) &&
+		(len(g.ASNs) == 0 || slices.Contains(g.ASNs, asn))
+}
+
+// decideGeo applies the table's country/ASN rules. It returns whether the
+// client may pass, the rule that decided (nil: none matched), and a denial
+// detail.
+func (a *AccessCfg) decideGeo(cc, asn string) (bool, *GeoRule, string) {
+	for i := range a.Geo {
+		g := &a.Geo[i]
+		if g.matches(cc, asn) {
+			return g.Action != "deny", g, "rule=" + g.Name
+		}
+	}
+	if a.geoAllow {
+		return false, nil, "not in geo allow rules"
+	}
+	return true, nil, ""
+}
+
+// decide reports whether ip may pass and the entry that decided.
+func (a *AccessCfg) decide(ip netip.Addr) (bool, string) {
+	for _, r := range a.rules {
+		if r.prefix.Contains(ip) {
+			if r.allow {
+				return true, "allow " + r.prefix.String()
+			}
+			return false, "deny " + r.prefix.String()
+		}
+	}
+	if len(a.Allow) > 0 {
+		return false, "not in allow list"
+	}
+	return true, ""
+}
+
+func validateAccess(c *ChainConfig) error {
+	return c.Access.compile("access")
+}
+
+func accessPath() string {
+	if v := strings.TrimSpace(os.Getenv("VPROX_ACCESS_FILE")); v != "" {
+		return v
+	}
+	return filepath.Join(configDir, "access.toml")
+}
+
+// loadAccess reads the global [access] table; a missing file means none.
+func loadAccess(path string) (*AccessCfg, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var f struct {
+		Access AccessCfg `toml:"access"`
+	}
+	if err := toml.Unmarshal(b, &f); err != nil {
+		return nil, fmt.Errorf("decode %s: %w", path, err)
+	}
+	if err := f.Access.compile("access"); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &f.Access, nil
+}
+
+// withAccess refuses clients outside the global or per-chain access lists
+// with 403 and an access-denied or geo-denied event (with geo fields) in
+// rate-limit.jsonl, and passes a matching geo limit rule on to the limiter.
+func withAccess(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rt := currentRoutes()
+		host := normalizeHost(r.Host)
+		chain := rt.chains[host]
+		var chainAccess *AccessCfg
+		if chain != nil {
+			chainAccess = &chain.Access
+		}
+		if !rt.access.active() && !chainAccess.active() {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		start := time.Now()
+		ok, detail := false, "unparsable client address"
+		reason := "access-denied"
+		var limitRule *GeoRule
+		var limitScope string
+		if ip, err := accessClientIP(r, rt.access, chainAccess); err == nil {
+			ok = true
+			if rt.access.active() {
+				ok, detail = rt.access.decide(ip)
+				detail = "global " + detail
+			}
+			if ok && chainAccess.active() {
+				ok, detail = chainAccess.decide(ip)
+				detail = "chain " + detail
+			}
+			if ok && (rt.access.hasGeo() || chainAccess.hasGeo()) {
+				cc, asn := geoLookup(ip.String())
+				for _, s := range []struct {
+					name string
+					a    *AccessCfg
+				}{{"global", rt.access}, {"chain", chainAccess}} {
+					if !s.a.hasGeo() {
+						continue
+					}
+					var g *GeoRule
+					if ok, g, detail = s.a.decideGeo(cc, asn); !ok {
+						reason, detail = "geo-denied", s.name+" "+detail
+						break
+					}
+					if g != nil && g.Action == "limit" {
+						limitRule, limitScope = g, s.name // the chain's rule wins
+						if s.name == "chain" {
+							limitScope = "chain:" + chain.ChainName
+						}
+					}
+				}
+			}
+		}
+		if ok {
+			if limitRule != nil {
+				r = r.WithContext(context.WithValue(r.Context(), geoPolicyCtxKey{}, limit.Policy{
+					Name:   limitScope + ":" + limitRule.Name,
+					Reason: "geo-limited",
+					Spec:   limit.RateSpec{RPS: limitRule.RPS, Burst: limitRule.Burst},
+				}))
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+		applog.EnsureRequestID(r)
+		if limiter != nil {
+			limiter.LogEvent(r, reason, detail)
+		}
+		writeError(w, r, http.StatusForbidden, "access denied")
+		logRequestSummary(r, false, "access-denied", host, start)
+	})
+}
+
+// accessClientIP returns the address access decisions are made on. It is
+// the connection's peer unless the peer is a trusted proxy of one of the
+// tables: then CF-Connecting-IP, else the right-most X-Forwarded-For entry
+// that is not itself a trusted proxy. Headers from other peers are ignored,
+// since any client can send them.
+func accessClientIP(r *http.Request, tables ...*AccessCfg) (netip.Addr, error) {
+	ap, err := netip.ParseAddrPort(r.RemoteAddr)
+	if err != nil {
+		return netip.Addr{}, err
+	}
+	peer := ap.Addr().Unmap()
+	trusted := func(ip netip.Addr) bool {
+		for _, a := range tables {
+			if a == nil {
+				continue
+			}
+			for _, p := range a.trusted {
+				if p.Contains(ip) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	if !trusted(peer) {
+		return peer, nil
+	}
+	if ip, err := netip.ParseAddr(strings.TrimSpace(r.Header.Get("CF-Connecting-IP"))); err == nil {
+		return ip.Unmap(), nil
+	}
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	client := peer
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			break // unparsable hop: do not look further left
+		}
+		client = ip.Unmap()
+		if !trusted(client) {
+			break
+		}
+	}
+	return client, nil
+}
+
+// geoLookup resolves the country and ASN of a client; tests replace it.
+var geoLookup = geo.Lookup
+
+func (a *AccessCfg) hasGeo() bool { return a != nil && len(a.Geo) > 0 }
+
+type geoPolicyCtxKey struct{}
+
+// limiterPolicyFunc hands the limit rule withAccess matched to the limiter
+// (limit.WithPolicyFunc).
+func limiterPolicyFunc(r *http.Request) (limit.Policy, bool) {
+	p, ok := r.Context().Value(geoPolicyCtxKey{}).(limit.Policy)
+	return p, ok
+}
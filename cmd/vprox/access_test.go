@@ -0,0 +1,241 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/vNodesV/vProx/internal/limit"
+)
+
+func TestAccessDecide(t *testing.T) {
+	a := &AccessCfg{
+		Allow: []string{"10.20.0.0/16", "2001:db8::/32"},
+		Deny:  []string{"10.20.9.0/24", "10.20.9.7", "10.20.0.0/16"},
+	}
+	if err := a.compile("access"); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		ip         string
+		want       bool
+		wantDetail string
+	}{
+		{"10.20.1.1", false, "deny 10.20.0.0/16"}, // deny wins a tie
+		{"10.20.9.1", false, "deny 10.20.9.0/24"},
+		{"10.20.9.7", false, "deny 10.20.9.7/32"},
+		{"2001:db8::1", true, "allow 2001:db8::/32"},
+		{"192.0.2.1", false, "not in allow list"},
+	}
+	for _, tt := range tests {
+		ip, _ := parsePrefix(tt.ip)
+		ok, detail := a.decide(ip.Addr())
+		if ok != tt.want || detail != tt.wantDetail {
+			t.Errorf("decide(%s) = %v %q, want %v %q", tt.ip, ok, detail, tt.want, tt.wantDetail)
+		}
+	}
+}
+
+func TestAccessCompileErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		a       AccessCfg
+		wantErr string
+	}{
+		{"ok", AccessCfg{Allow: []string{" 10.0.0.1 "}, TrustedProxies: []string{"::ffff:10.0.0.2", "173.245.48.0/20"}}, ""},
+		{"bad allow", AccessCfg{Allow: []string{"10.0.0"}}, "access.allow: invalid CIDR or IP"},
+		{"bad trusted proxy", AccessCfg{TrustedProxies: []string{"proxy.local"}}, "access.trusted_proxies: invalid CIDR or IP"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.a.compile("access")
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Fatalf("unexpected error: %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Fatalf("error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAccessClientIP(t *testing.T) {
+	proxies := &AccessCfg{TrustedProxies: []string{"127.0.0.1", "10.0.0.0/8"}}
+	if err := proxies.compile("access"); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		remote string
+		cf     string
+		xff    []string
+		tables []*AccessCfg
+		want   string
+	}{
+		{"untrusted peer ignores headers", "198.51.100.9:5000", "203.0.113.7", []string{"203.0.113.7"}, []*AccessCfg{proxies}, "198.51.100.9"},
+		{"no trusted proxies", "127.0.0.1:5000", "203.0.113.7", nil, []*AccessCfg{{}}, "127.0.0.1"},
+		{"nil tables", "127.0.0.1:5000", "203.0.113.7", nil, []*AccessCfg{nil}, "127.0.0.1"},
+		{"cf-connecting-ip", "127.0.0.1:5000", "203.0.113.7", []string{"192.0.2.1"}, []*AccessCfg{proxies}, "203.0.113.7"},
+		{"invalid cf header falls back to xff", "127.0.0.1:5000", "garbage", []string{"192.0.2.1"}, []*AccessCfg{proxies}, "192.0.2.1"},
+		{"right-most untrusted hop", "127.0.0.1:5000", "", []string{"6.6.6.6, 192.0.2.1, 10.1.1.1"}, []*AccessCfg{proxies}, "192.0.2.1"},
+		{"hops across headers", "127.0.0.1:5000", "", []string{"6.6.6.6", "192.0.2.1", "10.1.1.1"}, []*AccessCfg{proxies}, "192.0.2.1"},
+		{"all hops trusted", "127.0.0.1:5000", "", []string{"10.2.2.2, 10.1.1.1"}, []*AccessCfg{proxies}, "10.2.2.2"},
+		{"unparsable hop stops the walk", "127.0.0.1:5000", "", []string{"6.6.6.6, junk, 10.1.1.1"}, []*AccessCfg{proxies}, "10.1.1.1"},
+		{"no headers", "127.0.0.1:5000", "", nil, []*AccessCfg{proxies}, "127.0.0.1"},
+		{"mapped peer", "[::ffff:10.0.0.3]:5000", "", []string{"::ffff:192.0.2.5"}, []*AccessCfg{proxies}, "192.0.2.5"},
+		{"chain table trusts", "127.0.0.1:5000", "203.0.113.7", nil, []*AccessCfg{{}, proxies}, "203.0.113.7"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = tt.remote
+			if tt.cf != "" {
+				r.Header.Set("CF-Connecting-IP", tt.cf)
+			}
+			for _, v := range tt.xff {
+				r.Header.Add("X-Forwarded-For", v)
+			}
+			ip, err := accessClientIP(r, tt.tables...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ip.String() != tt.want {
+				t.Errorf("client = %s, want %s", ip, tt.want)
+			}
+		})
+	}
+	r := httptest.NewRequest("GET", "/", nil)
+	r.RemoteAddr = "not-an-address"
+	if _, err := accessClientIP(r, proxies); err == nil {
+		t.Error("want an error for an unparsable peer")
+	}
+}
+
+func TestAccessDecideGeo(t *testing.T) {
+	a := &AccessCfg{Geo: []GeoRule{
+		{Name: "cloud", ASNs: []string{"as16509"}, Action: "limit", RPS: 1, Burst: 2},
+		{Name: "blocked", Countries: []string{"kp", "IR"}, Action: "deny"},
+		{Name: "home", Countries: []string{"CA"}, Action: "allow"},
+		{Name: "ca-cloud", Countries: []string{"CA"}, ASNs: []string{"16509"}, Action: "deny"},
+	}}
+	if err := a.compile("access"); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name       string
+		cc, asn    string
+		want       bool
+		wantRule   string
+		wantDetail string
+	}{
+		{"asn rule first", "CA", "AS16509", true, "cloud", "rule=cloud"},
+		{"country deny", "IR", "AS1", false, "blocked", "rule=blocked"},
+		{"allow", "CA", "AS2", true, "home", "rule=home"},
+		{"allow table refuses the rest", "FR", "AS3", false, "", "not in geo allow rules"},
+		{"unknown client", "", "", false, "", "not in geo allow rules"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ok, g, detail := a.decideGeo(tt.cc, tt.asn)
+			rule := ""
+			if g != nil {
+				rule = g.Name
+			}
+			if ok != tt.want || rule != tt.wantRule || detail != tt.wantDetail {
+				t.Errorf("decideGeo = %v %q %q, want %v %q %q", ok, rule, detail, tt.want, tt.wantRule, tt.wantDetail)
+			}
+		})
+	}
+	deny := &AccessCfg{Geo: []GeoRule{{Countries: []string{"KP"}, Action: "deny"}}}
+	if err := deny.compile("access"); err != nil {
+		t.Fatal(err)
+	}
+	if ok, g, _ := deny.decideGeo("FR", "AS3"); !ok || g != nil {
+		t.Error("a table without allow rules must pass unmatched clients")
+	}
+}
+
+func TestWithAccessGeo(t *testing.T) {
+	clients := map[string][2]string{
+		"192.0.2.1": {"US", "AS16509"},
+		"192.0.2.2": {"US", "AS7922"},
+		"192.0.2.3": {"KP", "AS131279"},
+		"192.0.2.4": {"FR", "AS3215"},
+		"192.0.2.5": {"CA", "AS577"},
+	}
+	prev := geoLookup
+	geoLookup = func(ip string) (string, string) { return clients[ip][0], clients[ip][1] }
+	t.Cleanup(func() { geoLookup = prev })
+
+	rt := useRoutes(t, `chain_name = "test"
+host = "test.example.com"
+default_ports = true
+[expose]
+path = true
+[services]
+rpc = true
+[[backends]]
+name = "a"
+ip = "127.0.0.1"
+[[access.geo]]
+name = "aws"
+asns = ["AS16509"]
+action = "limit"
+rps = 2
+burst = 4
+[[access.geo]]
+name = "na"
+countries = ["US", "CA"]
+action = "allow"
+`)
+	rt.access = &AccessCfg{Geo: []GeoRule{
+		{Name: "us", Countries: []string{"US"}, Action: "limit", RPS: 10, Burst: 20},
+		{Name: "kp", Countries: []string{"KP"}, Action: "deny"},
+		{Name: "ca", Countries: []string{"CA"}, Action: "limit", RPS: 5, Burst: 5},
+	}}
+	if err := rt.access.compile("access"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		ip         string
+		wantStatus int
+		wantPolicy *limit.Policy
+	}{
+		{"chain limit overrides global", "192.0.2.1", http.StatusOK,
+			&limit.Policy{Name: "chain:test:aws", Reason: "geo-limited", Spec: limit.RateSpec{RPS: 2, Burst: 4}}},
+		{"global limit, chain allow", "192.0.2.2", http.StatusOK,
+			&limit.Policy{Name: "global:us", Reason: "geo-limited", Spec: limit.RateSpec{RPS: 10, Burst: 20}}},
+		{"global limit survives a chain allow", "192.0.2.5", http.StatusOK,
+			&limit.Policy{Name: "global:ca", Reason: "geo-limited", Spec: limit.RateSpec{RPS: 5, Burst: 5}}},
+		{"global deny", "192.0.2.3", http.StatusForbidden, nil},
+		{"chain allow table refuses the rest", "192.0.2.4", http.StatusForbidden, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got *limit.Policy
+			reached := false
+			h := withAccess(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				reached = true
+				if p, ok := limiterPolicyFunc(r); ok {
+					got = &p
+				}
+			}))
+			r := withLogNotes(httptest.NewRequest("GET", "http://test.example.com/rpc/status", nil))
+			r.RemoteAddr = tt.ip + ":40000"
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			if w.Code != tt.wantStatus || reached != (tt.wantStatus == http.StatusOK) {
+				t.Fatalf("status = %d (reached %v), want %d", w.Code, reached, tt.wantStatus)
+			}
+			switch {
+			case (got == nil) != (tt.wantPolicy == nil):
+				t.Errorf("policy = %+v, want %+v", got, tt.wantPolicy)
+			case got != nil && *got != *tt.wantPolicy:
+				t.Errorf("policy = %+v, want %+v", *got, *tt.wantPolicy)
+			}
+		})
+	}
+}
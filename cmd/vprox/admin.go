@@ -0,0 +1,412 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/vNodesV/vProx/internal/backup"
+	"github.com/vNodesV/vProx/internal/limit"
+	applog "github.com/vNodesV/vProx/internal/logging"
+	"github.com/vNodesV/vProx/internal/metrics"
+)
+
+// --------------------- ADMIN API ---------------------
+
+// The admin API is a small JSON API on its own listener (--admin-addr):
+// loopback TCP or a Unix socket, never the public port. Every request needs
+// "Authorization: Bearer <token>".
+
+var startedAt = time.Now()
+
+// reqStats counts finished requests (the access lines) for the admin API.
+type reqStats struct {
+	mu       sync.Mutex
+	total    int64
+	byChain  map[string]int64
+	byRoute  map[string]int64
+	byStatus map[string]int64
+}
+
+var requestStats = &reqStats{
+	byChain:  make(map[string]int64),
+	byRoute:  make(map[string]int64),
+	byStatus: make(map[string]int64),
+}
+
+func (s *reqStats) record(chain, route, status string) {
+	if chain == "" {
+		chain = "-"
+	}
+	if route == "" {
+		route = "-"
+	}
+	s.mu.Lock()
+	s.total++
+	s.byChain[chain]++
+	s.byRoute[route]++
+	s.byStatus[status]++
+	s.mu.Unlock()
+}
+
+type reqStatsView struct {
+	Total    int64            `json:"total"`
+	ByChain  map[string]int64 `json:"by_chain"`
+	ByRoute  map[string]int64 `json:"by_route"`
+	ByStatus map[string]int64 `json:"by_status"`
+}
+
+func (s *reqStats) view() reqStatsView {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return reqStatsView{
+		Total:    s.total,
+		ByChain:  cloneCounts(s.byChain),
+		ByRoute:  cloneCounts(s.byRoute),
+		ByStatus: cloneCounts(s.byStatus),
+	}
+}
+
+func cloneCounts(m map[string]int64) map[string]int64 {
+	out := make(map[string]int64, len(m))
+	for k, v := range m {
+		out[k] = v
+	}
+	return out
+}
+
+// adminListen opens the admin listener: "unix:/path" for a Unix socket
+// (mode 0600), otherwise host:port on a loopback address unless
+// VPROX_ADMIN_ALLOW_REMOTE is set.
+func adminListen(addr string) (net.Listener, error) {
+	if path, ok := strings.CutPrefix(addr, "unix:"); ok {
+		if path == "" {
+			return nil, errors.New("admin: empty unix socket path")
+		}
+		if fi, err := os.Lstat(path); err == nil && fi.Mode()&os.ModeSocket != 0 {
+			_ = os.Remove(path) // stale socket from a previous run
+		}
+		// The socket is created 0600 (umask 0177), so no other user can
+		// connect to it at any point.
+		old := syscall.Umask(0o177)
+		ln, err := net.Listen("unix", path)
+		syscall.Umask(old)
+		return ln, err
+	}
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, fmt.Errorf("admin: %w", err)
+	}
+	if !isLoopbackHost(host) && !envBool("VPROX_ADMIN_ALLOW_REMOTE") {
+		return nil, fmt.Errorf("admin: %q is not a loopback address (set VPROX_ADMIN_ALLOW_REMOTE=true to allow)", addr)
+	}
+	return net.Listen("tcp", addr)
+}
+
+func isLoopbackHost(host string) bool {
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// adminToken returns VPROX_ADMIN_TOKEN, else the token in path, creating it
+// (random, mode 0600) on first start.
+func adminToken(path string) (token string, source string, err error) {
+	if v := strings.TrimSpace(os.Getenv("VPROX_ADMIN_TOKEN")); v != "" {
+		return v, "env", nil
+	}
+	if b, err := os.ReadFile(path); err == nil {
+		if t := strings.TrimSpace(string(b)); t != "" {
+			return t, path, nil
+		}
+	} else if !os.IsNotExist(err) {
+		return "", "", err
+	}
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	token = hex.EncodeToString(buf)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", "", err
+	}
+	if err := os.WriteFile(path, []byte(token+"\n"), 0o600); err != nil {
+		return "", "", err
+	}
+	return token, path, nil
+}
+
+// adminAPI serves the admin endpoints.
+type adminAPI struct {
+	token      string
+	backupOpts func() backup.Options // on-demand run (same inputs as --new-backup)
+	backupCfg  string                // backup.toml path
+	backupLast string                // backup state file
+}
+
+func (a *adminAPI) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /admin/chains", a.chains)
+	mux.HandleFunc("GET /admin/status", a.status)
+	mux.HandleFunc("GET /admin/limits", a.limits)
+	mux.HandleFunc("PUT /admin/limits/overrides/{ip}", a.setOverride)
+	mux.HandleFunc("DELETE /admin/limits/overrides/{ip}", a.deleteOverride)
+	mux.HandleFunc("GET /admin/counters", a.counters)
+	mux.HandleFunc("GET /admin/backup", a.backupStatus)
+	mux.HandleFunc("POST /admin/backup/run", a.backupRun)
+	mux.HandleFunc("POST /admin/reload", a.reload)
+	mux.Handle("GET /metrics", metrics.Handler())
+	return a.auth(mux)
+}
+
+func (a *adminAPI) auth(next http.Handler) http.Handler {
+	want := []byte("Bearer " + a.token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got := []byte(r.Header.Get("Authorization"))
+		if subtle.ConstantTimeCompare(got, want) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="vProx admin"`)
+			adminError(w, http.StatusUnauthorized, "missing or invalid token")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func adminJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(v)
+}
+
+func adminError(w http.ResponseWriter, status int, msg string) {
+	adminJSON(w, status, map[string]string{"error": msg})
+}
+
+type adminBackend struct {
+	Name     string `json:"name"`
+	IP       string `json:"ip"`
+	Weight   int    `json:"weight"`
+	Archive  bool   `json:"archive,omitempty"`
+	Health   string `json:"health"`
+	Height   int64  `json:"height,omitempty"`
+	Inflight int64  `json:"inflight"`
+	Breaker  string `json:"breaker"`
+	Reason   string `json:"breaker_reason,omitempty"`
+	Opens    uint64 `json:"breaker_opens,omitempty"`
+}
+
+type adminChain struct {
+	Name        string         `json:"name"`
+	Hosts       []string       `json:"hosts"` // every registered host (base, vhosts, aliases)
+	LoadBalance string         `json:"load_balance"`
+	Backends    []adminBackend `json:"backends"`
+}
+
+func (a *adminAPI) chains(w http.ResponseWriter, r *http.Request) {
+	rt := currentRoutes()
+	hosts := make(map[*ChainConfig][]string)
+	for h, c := range rt.chains {
+		hosts[c] = append(hosts[c], h)
+	}
+	out := []adminChain{}
+	for _, c := range rt.uniqueChains() {
+		sort.Strings(hosts[c])
+		ac := adminChain{Name: c.ChainName, Hosts: hosts[c], LoadBalance: c.LoadBalance, Backends: []adminBackend{}}
+		if c.pool != nil {
+			for _, n := range c.pool.nodes {
+				b := adminBackend{Name: n.name, IP: n.ip, Weight: n.weight, Archive: n.archive, Health: "UNCHECKED", Inflight: n.inflight.Load(), Breaker: "off"}
+				if n.down.Load() {
+					b.Health = "DOWN"
+				}
+				if healthChecker != nil && c.Health.Enabled {
+					if st, ok := healthChecker.Status(n.key); ok {
+						b.Health, b.Height = st.Reason, st.Height
+					}
+				}
+				if n.breaker != nil {
+					s := n.breaker.Snapshot()
+					b.Breaker, b.Reason, b.Opens = s.State.String(), s.Reason, s.Opens
+				}
+				ac.Backends = append(ac.Backends, b)
+			}
+		}
+		out = append(out, ac)
+	}
+	adminJSON(w, http.StatusOK, map[string]any{
+		"loaded_at": rt.loadedAt.UTC(),
+		"chains":    out,
+	})
+}
+
+type adminOverride struct {
+	IP      string    `json:"ip"`
+	RPS     float64   `json:"rps"`
+	Burst   int       `json:"burst"`
+	Auto    bool      `json:"auto"`
+	Expires time.Time `json:"expires,omitzero"`
+}
+
+// adminAPIKey describes a key without its secret.
+type adminAPIKey struct {
+	ID      string    `json:"id"`
+	RPS     float64   `json:"rps"`
+	Burst   int       `json:"burst"`
+	Chains  []string  `json:"chains"`
+	Expires time.Time `json:"expires,omitzero"`
+	Expired bool      `json:"expired"`
+}
+
+func (a *adminAPI) limits(w http.ResponseWriter, r *http.Request) {
+	if limiter == nil {
+		adminError(w, http.StatusServiceUnavailable, "limiter not running")
+		return
+	}
+	var manual, quarantined []adminOverride
+	for _, o := range limiter.Overrides() {
+		v := adminOverride{IP: o.IP, RPS: o.Spec.RPS, Burst: o.Spec.Burst, Auto: o.Auto, Expires: o.Expires}
+		if o.Auto {
+			quarantined = append(quarantined, v)
+		} else {
+			manual = append(manual, v)
+		}
+	}
+	var keys []adminAPIKey
+	now := time.Now()
+	for _, k := range currentRoutes().apiKeys.Keys() {
+		keys = append(keys, adminAPIKey{ID: k.ID, RPS: k.Spec.RPS, Burst: k.Spec.Burst,
+			Chains: nonNil(k.Chains), Expires: k.Expires, Expired: k.Expired(now)})
+	}
+	d := limiter.Defaults()
+	adminJSON(w, http.StatusOK, map[string]any{
+		"defaults":    map[string]any{"rps": d.RPS, "burst": d.Burst},
+		"overrides":   nonNil(manual),
+		"quarantined": nonNil(quarantined),
+		"api_keys":    nonNil(keys),
+	})
+}
+
+func nonNil[T any](s []T) []T {
+	if s == nil {
+		return []T{}
+	}
+	return s
+}
+
+func (a *adminAPI) setOverride(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		RPS   float64 `json:"rps"`
+		Burst int     `json:"burst"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4096)).Decode(&body); err != nil {
+		adminError(w, http.StatusBadRequest, "body must be {\"rps\": <float>, \"burst\": <int>}")
+		return
+	}
+	if body.RPS <= 0 || body.Burst < 1 {
+		adminError(w, http.StatusBadRequest, "rps must be > 0 and burst >= 1")
+		return
+	}
+	ip := r.PathValue("ip")
+	if err := limiter.SetOverride(ip, limit.RateSpec{RPS: body.RPS, Burst: body.Burst}); err != nil {
+		adminError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	applog.Print("INFO", "admin", "override_set",
+		applog.F("ip", ip),
+		applog.F("rps", body.RPS),
+		applog.F("burst", body.Burst),
+	)
+	adminJSON(w, http.StatusOK, adminOverride{IP: ip, RPS: body.RPS, Burst: body.Burst})
+}
+
+func (a *adminAPI) deleteOverride(w http.ResponseWriter, r *http.Request) {
+	ip := r.PathValue("ip")
+	if net.ParseIP(ip) == nil {
+		adminError(w, http.StatusBadRequest, "invalid ip")
+		return
+	}
+	limiter.DeleteOverride(ip)
+	applog.Print("INFO", "admin", "override_deleted", applog.F("ip", ip))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *adminAPI) counters(w http.ResponseWriter, r *http.Request) {
+	counterMutex.Lock()
+	sources := len(srcCounter)
+	counterMutex.Unlock()
+
+	out := map[string]any{
+		"uptime_sec": int64(time.Since(startedAt).Seconds()),
+		"requests":   requestStats.view(),
+		"sources":    sources,
+	}
+	inflight := map[string]int64{}
+	for _, c := range currentRoutes().uniqueChains() {
+		if c.pool == nil {
+			continue
+		}
+		for _, n := range c.pool.nodes {
+			inflight[n.key] = n.inflight.Load()
+		}
+	}
+	out["inflight"] = inflight
+	if respCache != nil {
+		s := respCache.Stats()
+		out["cache"] = map[string]any{
+			"entries": s.Entries, "bytes": s.Bytes, "max_bytes": s.MaxBytes,
+			"hits": s.Hits, "misses": s.Misses, "evictions": s.Evictions,
+		}
+	}
+	adminJSON(w, http.StatusOK, out)
+}
+
+func (a *adminAPI) backupStatus(w http.ResponseWriter, r *http.Request) {
+	adminJSON(w, http.StatusOK, readBackupStatus(a.backupCfg, a.backupLast, archiveDir))
+}
+
+// backupRun runs a backup now and answers when it is done.
+func (a *adminAPI) backupRun(w http.ResponseWriter, r *http.Request) {
+	err := backup.RunOnce(a.backupOpts())
+	switch {
+	case errors.Is(err, backup.ErrRunning):
+		adminError(w, http.StatusConflict, err.Error())
+	case err != nil:
+		adminError(w, http.StatusInternalServerError, err.Error())
+	default:
+		adminJSON(w, http.StatusOK, readBackupStatus(a.backupCfg, a.backupLast, archiveDir))
+	}
+}
+
+func (a *adminAPI) reload(w http.ResponseWriter, r *http.Request) {
+	if err := reloadConfig("admin"); err != nil {
+		adminError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	rt := currentRoutes()
+	names := []string{}
+	for _, c := range rt.uniqueChains() {
+		names = append(names, c.ChainName)
+	}
+	adminJSON(w, http.StatusOK, map[string]any{
+		"loaded_at": rt.loadedAt.UTC(),
+		"chains":    names,
+		"hosts":     len(rt.chains),
+	})
+}
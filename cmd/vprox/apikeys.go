@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/vNodesV/vProx/internal/apikey"
+	"github.com/vNodesV/vProx/internal/limit"
+	applog "github.com/vNodesV/vProx/internal/logging"
+	"github.com/vNodesV/vProx/internal/trace"
+)
+
+// --------------------- API KEYS ---------------------
+
+// API keys come from $configDir/apikeys.toml (or VPROX_API_KEYS_FILE) and are
+// part of the routing table, so they reload with it. A request presenting a
+// valid key is limited by the key's RateSpec instead of its IP; an unknown,
+// expired or out-of-scope key is refused. Requests without a key are
+// anonymous and unaffected.
+
+type (
+	apiKeyCtxKey       struct{}
+	apiKeySecretCtxKey struct{}
+)
+
+func apiKeysPath() string {
+	if v := strings.TrimSpace(os.Getenv("VPROX_API_KEYS_FILE")); v != "" {
+		return v
+	}
+	return filepath.Join(configDir, "apikeys.toml")
+}
+
+// apiKeyOf returns the key r authenticated with, or nil.
+func apiKeyOf(r *http.Request) *apikey.Key {
+	k, _ := r.Context().Value(apiKeyCtxKey{}).(*apikey.Key)
+	return k
+}
+
+// limiterKeyFunc buckets authenticated requests by key ID (limit.WithKeyFunc).
+func limiterKeyFunc(r *http.Request) (string, limit.RateSpec, bool) {
+	if k := apiKeyOf(r); k != nil {
+		return k.ID, k.Spec, true
+	}
+	return "", limit.RateSpec{}, false
+}
+
+// withAPIKeyStrip takes the key out of r (header, query parameter or path
+// segment) before anything traces, logs or refuses the request, so the secret
+// is never proxied or recorded, not even by an access-list denial. The key is
+// kept in the context for withAPIKeys.
+func withAPIKeyStrip(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if secret := currentRoutes().apiKeys.Extract(r); secret != "" {
+			r = r.WithContext(context.WithValue(r.Context(), apiKeySecretCtxKey{}, secret))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// withAPIKeys checks the key withAPIKeyStrip took from r and records it for
+// the limiter and the access line. It runs before the limiter.
+func withAPIKeys(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		secret, _ := r.Context().Value(apiKeySecretCtxKey{}).(string)
+		if secret == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		rt := currentRoutes()
+		span := trace.FromContext(r.Context())
+
+		host := normalizeHost(r.Host)
+		k := rt.apiKeys.Lookup(secret)
+		status, msg, detail := 0, "", ""
+		switch {
+		case k == nil:
+			status, msg, detail = http.StatusUnauthorized, "invalid API key", "unknown"
+		case k.Expired(time.Now()):
+			status, msg, detail = http.StatusUnauthorized, "API key expired", "expired key="+k.ID
+		default:
+			if chain, ok := rt.chains[host]; ok && !k.AllowsChain(chain.ChainName) {
+				status, msg, detail = http.StatusForbidden, "API key not valid for this chain", "chain key="+k.ID
+			}
+		}
+		if status != 0 {
+			applog.EnsureRequestID(r)
+			if k != nil {
+				addLogNote(r, applog.F("apiKey", k.ID))
+			}
+			if limiter != nil {
+				limiter.LogEvent(r, "api-key-denied", detail)
+			}
+			writeError(w, r, status, msg)
+			logRequestSummary(r, false, "apikey-denied", host, start)
+			return
+		}
+
+		span.SetAttr("vprox.api_key", k.ID)
+		addLogNote(r, applog.F("apiKey", k.ID))
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), apiKeyCtxKey{}, k)))
+	})
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// --------------------- ARCHIVE ROUTING ---------------------
+
+// ArchiveCfg sends requests for old heights to the chain's archive backends
+// ([[backends]] archive = true); everything else stays on the pruned ones.
+// A height needs the archive group when it is more than PruningHorizon blocks
+// below the pruned nodes' latest height, or within MarginBlocks of their
+// earliest_block_height (both from the [health] /status probes).
+type ArchiveCfg struct {
+	PruningHorizon int64 `toml:"pruning_horizon"` // blocks the pruned nodes keep (0 = earliest_block_height only)
+	MarginBlocks   int64 `toml:"margin_blocks"`   // safety margin above earliest_block_height (default 100)
+}
+
+func validateArchive(c *ChainConfig) error {
+	a := &c.Archive
+	if a.PruningHorizon < 0 || a.MarginBlocks < 0 {
+		return errors.New("archive: pruning_horizon and margin_blocks must be >= 0")
+	}
+	if a.MarginBlocks == 0 {
+		a.MarginBlocks = 100
+	}
+	hasArchive := false
+	for _, b := range c.Backends {
+		hasArchive = hasArchive || b.Archive
+	}
+	if hasArchive && !c.Health.Enabled {
+		return errors.New("archive backends require health.enabled (heights come from /status)")
+	}
+	return nil
+}
+
+// forHeight returns the pool that should serve height h: the archive group
+// when the pruned nodes no longer keep it, else p. Unknown heights (0,
+// latest) and nodes without a /status probe yet stay on p.
+func (p *backendPool) forHeight(a ArchiveCfg, h int64) *backendPool {
+	if p.archive == nil || h <= 0 || healthChecker == nil {
+		return p
+	}
+	var latest, earliest int64
+	for _, n := range p.serving {
+		st, ok := healthChecker.Status(n.key)
+		if !ok || st.Error != "" {
+			continue
+		}
+		latest = max(latest, st.Height)
+		earliest = max(earliest, st.EarliestHeight) // the pick may be any of them
+	}
+	if a.PruningHorizon > 0 && latest > 0 && h < latest-a.PruningHorizon ||
+		earliest > 1 && h < earliest+a.MarginBlocks {
+		return p.archive
+	}
+	return p
+}
+
+// requestHeight returns the block height r asks for, or 0 for latest/none:
+// ?height= on RPC URI calls, params.height of JSON-RPC calls (the lowest of
+// a batch), and for REST/gRPC the x-cosmos-block-height header (gRPC
+// metadata) or a /blocks/{height} path.
+func requestHeight(r *http.Request, routePrefix, upstreamPath string, calls rpcCalls) int64 {
+	if routePrefix == rpcPrefix {
+		if calls.URI {
+			return parseHeight(r.URL.Query().Get("height"))
+		}
+		var low int64
+		for _, c := range calls.Calls {
+			if h := rpcParamsHeight(c.Params); h > 0 && (low == 0 || h < low) {
+				low = h
+			}
+		}
+		return low
+	}
+	if h := parseHeight(r.Header.Get("x-cosmos-block-height")); h > 0 {
+		return h
+	}
+	if routePrefix == restPrefix || routePrefix == apiPrefix {
+		if m := restHeightPath.FindStringSubmatch(upstreamPath); m != nil {
+			return parseHeight(m[1])
+		}
+	}
+	return 0
+}
+
+// rpcParamsHeight reads "height" from named JSON-RPC params.
+func rpcParamsHeight(params json.RawMessage) int64 {
+	var p struct {
+		Height json.RawMessage `json:"height"`
+	}
+	if len(params) == 0 || params[0] != '{' || json.Unmarshal(params, &p) != nil {
+		return 0
+	}
+	return parseHeight(string(p.Height))
+}
+
+// parseHeight accepts 123 and "123"; anything else (latest, 0) is 0.
+func parseHeight(s string) int64 {
+	n, err := strconv.ParseInt(strings.Trim(strings.TrimSpace(s), `"`), 10, 64)
+	if err != nil || n < 0 {
+		return 0
+	}
+	return n
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vNodesV/vProx/internal/health"
+)
+
+func TestRequestHeight(t *testing.T) {
+	tests := []struct {
+		name   string
+		prefix string
+		method string
+		target string
+		body   string
+		header string
+		want   int64
+	}{
+		{"rpc uri", rpcPrefix, "GET", "/block?height=123", "", "", 123},
+		{"rpc uri quoted", rpcPrefix, "GET", `/block?height="45"`, "", "", 45},
+		{"rpc uri latest", rpcPrefix, "GET", "/block", "", "", 0},
+		{"rpc call", rpcPrefix, "POST", "/", `{"jsonrpc":"2.0","id":1,"method":"block","params":{"height":"50"}}`, "", 50},
+		{"rpc call number", rpcPrefix, "POST", "/", `{"jsonrpc":"2.0","id":1,"method":"block","params":{"height":51}}`, "", 51},
+		{"rpc positional params", rpcPrefix, "POST", "/", `{"jsonrpc":"2.0","id":1,"method":"block","params":["50"]}`, "", 0},
+		{"rpc batch lowest", rpcPrefix, "POST", "/", `[{"jsonrpc":"2.0","id":1,"method":"block","params":{"height":"80"}},` +
+			`{"jsonrpc":"2.0","id":2,"method":"status"},{"jsonrpc":"2.0","id":3,"method":"block","params":{"height":"40"}}]`, "", 40},
+		{"rpc ignores header", rpcPrefix, "GET", "/status", "", "9", 0},
+		{"rest header", restPrefix, "GET", "/cosmos/bank/v1beta1/balances/x", "", "77", 77},
+		{"rest blocks path", restPrefix, "GET", "/cosmos/base/tendermint/v1beta1/blocks/99", "", "", 99},
+		{"api legacy path", apiPrefix, "GET", "/blocks/98", "", "", 98},
+		{"rest latest", restPrefix, "GET", "/cosmos/base/tendermint/v1beta1/blocks/latest", "", "", 0},
+		{"grpc metadata", grpcPrefix, "POST", "/cosmos.bank.v1beta1.Query/Balance", "", "66", 66},
+		{"grpc has no paths", grpcPrefix, "POST", "/blocks/5", "", "", 0},
+		{"bad header", restPrefix, "GET", "/x", "", "-3", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+			if tt.header != "" {
+				r.Header.Set("x-cosmos-block-height", tt.header)
+			}
+			var calls rpcCalls
+			if tt.prefix == rpcPrefix {
+				var err error
+				if calls, err = inspectRPC(r, strings.TrimPrefix(r.URL.Path, "/")); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got := requestHeight(r, tt.prefix, r.URL.Path, calls); got != tt.want {
+				t.Errorf("requestHeight = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+// useHealthStatus runs a health checker over /status servers reporting the
+// given latest and earliest heights per node key, and waits for the probes.
+func useHealthStatus(t *testing.T, heights map[string][2]int64) {
+	t.Helper()
+	var targets []health.Target
+	for key, h := range heights {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			fmt.Fprintf(w, `{"result":{"sync_info":{"latest_block_height":"%d","earliest_block_height":"%d"}}}`, h[0], h[1])
+		}))
+		t.Cleanup(srv.Close)
+		targets = append(targets, health.Target{Key: key, Group: "test", StatusURL: srv.URL + "/status"})
+	}
+	prev := healthChecker
+	hc := health.New(health.Options{Targets: func() []health.Target { return targets }, Tick: 5 * time.Millisecond})
+	healthChecker = hc
+	t.Cleanup(func() {
+		hc.Close()
+		healthChecker = prev
+	})
+	deadline := time.Now().Add(5 * time.Second)
+	for key := range heights {
+		for {
+			if _, ok := hc.Status(key); ok {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("no probe result for %s", key)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+}
+
+func TestForHeight(t *testing.T) {
+	a, b := &backendNode{key: "test/a"}, &backendNode{key: "test/b"}
+	archive := &backendPool{serving: []*backendNode{{key: "test/archive"}}}
+	p := &backendPool{serving: []*backendNode{a, b}, archive: archive}
+	plain := &backendPool{serving: []*backendNode{a, b}}
+
+	if p.forHeight(ArchiveCfg{PruningHorizon: 300, MarginBlocks: 100}, 1) != p {
+		t.Fatal("without probe results every height must stay on the pruned nodes")
+	}
+	// Latest 1000 (a), earliest 500 (a): the pick may be either node.
+	useHealthStatus(t, map[string][2]int64{"test/a": {1000, 500}, "test/b": {990, 400}})
+
+	tests := []struct {
+		name string
+		pool *backendPool
+		cfg  ArchiveCfg
+		h    int64
+		want *backendPool
+	}{
+		{"latest", p, ArchiveCfg{PruningHorizon: 300, MarginBlocks: 100}, 0, p},
+		{"recent", p, ArchiveCfg{PruningHorizon: 300, MarginBlocks: 100}, 900, p},
+		{"at the horizon", p, ArchiveCfg{PruningHorizon: 300, MarginBlocks: 100}, 700, p},
+		{"past the horizon", p, ArchiveCfg{PruningHorizon: 300, MarginBlocks: 100}, 699, archive},
+		{"earliest only, above the margin", p, ArchiveCfg{MarginBlocks: 100}, 650, p},
+		{"earliest only, within the margin", p, ArchiveCfg{MarginBlocks: 100}, 599, archive},
+		{"no archive group", plain, ArchiveCfg{PruningHorizon: 300, MarginBlocks: 100}, 10, plain},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.pool.forHeight(tt.cfg, tt.h); got != tt.want {
+				t.Errorf("forHeight(%d) picked the wrong group", tt.h)
+			}
+		})
+	}
+}
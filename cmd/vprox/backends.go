@@ -0,0 +1,399 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/vNodesV/vProx/internal/breaker"
+	"github.com/vNodesV/vProx/internal/health"
+)
+
+// --------------------- BACKENDS & LOAD BALANCING ---------------------
+
+const (
+	lbRoundRobin    = "round_robin"
+	lbWeighted      = "weighted"
+	lbLeastInFlight = "least_inflight"
+)
+
+func validateLoadBalanceMode(m string) bool {
+	switch strings.ToLower(strings.TrimSpace(m)) {
+	case "", lbRoundRobin, lbWeighted, lbLeastInFlight:
+		return true
+	default:
+		return false
+	}
+}
+
+// backendNode is one upstream node of a chain with its effective ports.
+type backendNode struct {
+	key     string // "<chain>/<name>", stable across reloads
+	name    string
+	ip      string
+	ports   Ports
+	weight  int
+	archive bool // member of the archive group ([[backends]] archive = true)
+
+	upstreams map[string]*upstream // per service: timeouts + transport
+
+	breaker *breaker.Breaker // nil when [breaker] is disabled
+
+	inflight atomic.Int64
+	down     atomic.Bool // set by the health checker
+	current  int         // smooth weighted round-robin state (guarded by backendPool.mu)
+}
+
+// addr returns host:port for the given upstream port (IPv6-safe).
+func (n *backendNode) addr(port int) string {
+	return net.JoinHostPort(n.ip, strconv.Itoa(port))
+}
+
+// url builds an upstream URL for scheme/port/path on this node.
+func (n *backendNode) url(scheme string, port int, path string) string {
+	return scheme + "://" + n.addr(port) + path
+}
+
+// upstream returns the node's client for svc (rpc, rest, grpc, grpc_web, api).
+func (n *backendNode) upstream(svc string) *upstream {
+	if u, ok := n.upstreams[svc]; ok {
+		return u
+	}
+	return n.upstreams[svcREST]
+}
+
+// port returns the node's upstream port for svc.
+func (n *backendNode) port(svc string) int {
+	switch svc {
+	case svcRPC:
+		return n.ports.RPC
+	case svcGRPC:
+		return n.ports.GRPC
+	case svcGRPCWeb:
+		return n.ports.GRPCWeb
+	case svcAPI:
+		return n.ports.API
+	}
+	return n.ports.REST
+}
+
+func (n *backendNode) acquire() { n.inflight.Add(1) }
+func (n *backendNode) release() { n.inflight.Add(-1) }
+
+// usable reports whether the node may receive new traffic: healthy and its
+// breaker not open.
+func (n *backendNode) usable() bool { return !n.down.Load() && n.breaker.Ready() }
+
+// backendPool picks a node per request using the chain's load_balance mode.
+// nodes lists every backend of the chain; only serving ones are picked.
+// Archive backends are served by the archive sub-pool instead.
+type backendPool struct {
+	strategy string
+	nodes    []*backendNode
+	serving  []*backendNode
+	archive  *backendPool // nil without archive backends
+	budget   *retryBudget
+
+	rr atomic.Uint64
+	mu sync.Mutex
+}
+
+// newBackendPool resolves the effective ports of every configured backend
+// (backend [ports] > chain [ports] > ports.toml) and builds the pool.
+func newBackendPool(c *ChainConfig, defaults Ports) *backendPool {
+	base := effectivePorts(c, defaults)
+	p := &backendPool{
+		strategy: strings.ToLower(strings.TrimSpace(c.LoadBalance)),
+		budget:   newRetryBudget(c.Retry),
+	}
+	if p.strategy == "" {
+		p.strategy = lbRoundRobin
+	}
+	for _, b := range c.Backends {
+		n := &backendNode{
+			key:     c.ChainName + "/" + b.Name,
+			name:    b.Name,
+			ip:      b.IP,
+			ports:   overlayPorts(base, b.Ports),
+			weight:  b.Weight,
+			archive: b.Archive,
+
+			upstreams: newUpstreams(c, b.Name),
+			breaker:   newNodeBreaker(c, b.Name),
+		}
+		p.nodes = append(p.nodes, n)
+		if !n.archive {
+			p.serving = append(p.serving, n)
+			continue
+		}
+		if p.archive == nil {
+			p.archive = &backendPool{strategy: p.strategy, budget: p.budget}
+		}
+		p.archive.nodes = append(p.archive.nodes, n)
+		p.archive.serving = append(p.archive.serving, n)
+	}
+	return p
+}
+
+// next returns the node that should serve the next request, or nil if the
+// pool is empty. Unhealthy nodes are skipped; if every node is unhealthy the
+// pool fails open and picks among all of them rather than refusing traffic.
+func (p *backendPool) next() *backendNode {
+	return p.nextExcept(nil)
+}
+
+// nextExcept is next without the nodes in tried (failover target of a
+// retry). It returns nil once every node has been tried.
+func (p *backendPool) nextExcept(tried []*backendNode) *backendNode {
+	if p == nil || len(p.serving) == 0 {
+		return nil
+	}
+	cands := p.serving
+	if len(tried) > 0 {
+		cands = make([]*backendNode, 0, len(p.serving))
+		for _, n := range p.serving {
+			if !slices.Contains(tried, n) {
+				cands = append(cands, n)
+			}
+		}
+		if len(cands) == 0 {
+			return nil
+		}
+	}
+	all := cands
+	if !allUsable(cands) {
+		cands = usableNodes(cands)
+		if len(cands) == 0 {
+			cands = all
+		}
+	}
+	if len(cands) == 1 {
+		return cands[0]
+	}
+	switch p.strategy {
+	case lbWeighted:
+		return p.nextWeighted(cands)
+	case lbLeastInFlight:
+		return p.nextLeastInFlight(cands)
+	default:
+		return cands[int(p.rr.Add(1)-1)%len(cands)]
+	}
+}
+
+func allUsable(nodes []*backendNode) bool {
+	for _, n := range nodes {
+		if !n.usable() {
+			return false
+		}
+	}
+	return true
+}
+
+func usableNodes(nodes []*backendNode) []*backendNode {
+	out := make([]*backendNode, 0, len(nodes))
+	for _, n := range nodes {
+		if n.usable() {
+			out = append(out, n)
+		}
+	}
+	return out
+}
+
+// nextWeighted implements smooth weighted round-robin (nginx style), which
+// spreads picks of heavy nodes evenly instead of sending them in bursts.
+func (p *backendPool) nextWeighted(cands []*backendNode) *backendNode {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var best *backendNode
+	total := 0
+	for _, n := range cands {
+		n.current += n.weight
+		total += n.weight
+		if best == nil || n.current > best.current {
+			best = n
+		}
+	}
+	best.current -= total
+	return best
+}
+
+// nextLeastInFlight picks the node with the fewest in-flight requests. The
+// scan starts at a rotating offset so ties are spread across nodes.
+func (p *backendPool) nextLeastInFlight(cands []*backendNode) *backendNode {
+	start := int(p.rr.Add(1)-1) % len(cands)
+	var best *backendNode
+	var bestN int64
+	for i := range cands {
+		n := cands[(start+i)%len(cands)]
+		if cur := n.inflight.Load(); best == nil || cur < bestN {
+			best, bestN = n, cur
+		}
+	}
+	return best
+}
+
+// effectivePorts resolves the chain-level ports against ports.toml defaults.
+func effectivePorts(c *ChainConfig, defaults Ports) Ports {
+	if c.DefaultPorts {
+		return defaults
+	}
+	return overlayPorts(defaults, c.Ports)
+}
+
+// overlayPorts returns base with every non-zero port of o applied on top.
+func overlayPorts(base, o Ports) Ports {
+	if o.RPC != 0 {
+		base.RPC = o.RPC
+	}
+	if o.REST != 0 {
+		base.REST = o.REST
+	}
+	if o.GRPC != 0 {
+		base.GRPC = o.GRPC
+	}
+	if o.GRPCWeb != 0 {
+		base.GRPCWeb = o.GRPCWeb
+	}
+	if o.API != 0 {
+		base.API = o.API
+	}
+	return base
+}
+
+// normalizeBackends validates [[backends]] and folds the legacy single `ip`
+// key into a one-item backend list.
+func normalizeBackends(c *ChainConfig) error {
+	ip := strings.TrimSpace(c.IP)
+	if len(c.Backends) == 0 {
+		if net.ParseIP(ip) == nil {
+			return fmt.Errorf("invalid ip: %q", c.IP)
+		}
+		c.Backends = []BackendConfig{{Name: ip, IP: ip, Weight: 1}}
+		return nil
+	}
+	if ip != "" {
+		return fmt.Errorf("set either ip or [[backends]], not both")
+	}
+	if !validateLoadBalanceMode(c.LoadBalance) {
+		return fmt.Errorf("load_balance must be round_robin|weighted|least_inflight, got %q", c.LoadBalance)
+	}
+	seen := make(map[string]bool, len(c.Backends))
+	serving := 0
+	for i := range c.Backends {
+		b := &c.Backends[i]
+		b.IP = strings.TrimSpace(b.IP)
+		if net.ParseIP(b.IP) == nil {
+			return fmt.Errorf("backends[%d]: invalid ip: %q", i, b.IP)
+		}
+		b.Name = strings.TrimSpace(b.Name)
+		if b.Name == "" {
+			b.Name = b.IP
+		}
+		if seen[b.Name] {
+			return fmt.Errorf("backends[%d]: duplicate name %q", i, b.Name)
+		}
+		seen[b.Name] = true
+		if b.Weight < 0 {
+			return fmt.Errorf("backends[%d]: weight must be >= 0, got %d", i, b.Weight)
+		}
+		if b.Weight == 0 {
+			b.Weight = 1
+		}
+		if !b.Archive {
+			serving++
+		}
+		for _, p := range []struct {
+			label string
+			v     int
+		}{{"rpc", b.Ports.RPC}, {"rest", b.Ports.REST}, {"grpc", b.Ports.GRPC}, {"grpc_web", b.Ports.GRPCWeb}, {"api", b.Ports.API}} {
+			if p.v == 0 {
+				continue
+			}
+			if err := validatePortsLabel(p.label, p.v); err != nil {
+				return fmt.Errorf("backends[%d]: %w", i, err)
+			}
+		}
+	}
+	if serving == 0 {
+		return errors.New("backends: at least one backend must not be archive")
+	}
+	return nil
+}
+
+// backendSummary renders "name=ip" pairs for --info output.
+func backendSummary(c *ChainConfig) string {
+	parts := make([]string, 0, len(c.Backends))
+	for _, b := range c.Backends {
+		s := b.Name + "=" + b.IP
+		if b.Name == b.IP {
+			s = b.IP
+		}
+		if b.Archive {
+			s += " (archive)"
+		}
+		parts = append(parts, s)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// --------------------- HEALTH CHECKS ---------------------
+
+// healthTargets lists every backend of chains with [health] enabled.
+func healthTargets() []health.Target {
+	var out []health.Target
+	for _, c := range currentRoutes().uniqueChains() {
+		if !c.Health.Enabled || c.pool == nil {
+			continue
+		}
+		for _, n := range c.pool.nodes {
+			out = append(out, health.Target{
+				Key:       n.key,
+				Group:     c.ChainName,
+				Backend:   n.name,
+				StatusURL: n.url("http", n.ports.RPC, "/status"),
+				Interval:  time.Duration(c.Health.IntervalSec) * time.Second,
+				Timeout:   time.Duration(c.Health.TimeoutSec) * time.Second,
+				MaxLag:    c.Health.MaxLagBlocks,
+			})
+		}
+	}
+	return out
+}
+
+// onHealthChange flips the routing flag of the node behind t.
+func onHealthChange(t health.Target, s health.Status) {
+	for _, c := range currentRoutes().uniqueChains() {
+		if c.pool == nil {
+			continue
+		}
+		for _, n := range c.pool.nodes {
+			if n.key == t.Key {
+				n.down.Store(!s.Healthy)
+			}
+		}
+	}
+}
+
+// seedHealthState copies the last known health of every node into a freshly
+// loaded table, so a reload does not route to nodes already known to be down.
+func seedHealthState(rt *routeTable) {
+	if healthChecker == nil {
+		return
+	}
+	for _, c := range rt.uniqueChains() {
+		if c.pool == nil {
+			continue
+		}
+		for _, n := range c.pool.nodes {
+			if st, ok := healthChecker.Status(n.key); ok && c.Health.Enabled {
+				n.down.Store(!st.Healthy)
+			}
+		}
+	}
+}
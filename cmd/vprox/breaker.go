@@ -0,0 +1,148 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/vNodesV/vProx/internal/breaker"
+)
+
+// --------------------- CIRCUIT BREAKER ---------------------
+
+// BreakerCfg configures the per-backend circuit breaker of a chain ([breaker]).
+type BreakerCfg struct {
+	Enabled          bool `toml:"enabled"`
+	Failures         int  `toml:"failures"`           // consecutive failures that open it (default 5, -1 = off)
+	ErrorRatePercent int  `toml:"error_rate_percent"` // error rate over window_sec that opens it (0 = off)
+	MinRequests      int  `toml:"min_requests"`       // requests in the window before the rate applies (default 20)
+	WindowSec        int  `toml:"window_sec"`         // error-rate window (default 30)
+	OpenSec          int  `toml:"open_sec"`           // fail-fast period before half-open probes (default 30)
+	HalfOpenProbes   int  `toml:"half_open_probes"`   // successful probes needed to close (default 1)
+}
+
+func validateBreaker(c *ChainConfig) error {
+	b := c.Breaker
+	if b.Failures < -1 || b.ErrorRatePercent < 0 || b.ErrorRatePercent > 100 ||
+		b.MinRequests < 0 || b.WindowSec < 0 || b.OpenSec < 0 || b.HalfOpenProbes < 0 {
+		return errors.New("breaker: values out of range (failures >= -1, error_rate_percent 0-100, others >= 0)")
+	}
+	if b.Enabled && b.Failures == -1 && b.ErrorRatePercent == 0 {
+		return errors.New("breaker: failures = -1 needs error_rate_percent > 0")
+	}
+	return nil
+}
+
+// newNodeBreaker returns the breaker of one backend, or nil when disabled.
+func newNodeBreaker(c *ChainConfig, name string) *breaker.Breaker {
+	b := c.Breaker
+	if !b.Enabled {
+		return nil
+	}
+	return breaker.New(breaker.Options{
+		Group:          c.ChainName,
+		Name:           name,
+		Failures:       b.Failures,
+		ErrorRate:      b.ErrorRatePercent,
+		MinRequests:    b.MinRequests,
+		Window:         time.Duration(b.WindowSec) * time.Second,
+		OpenFor:        time.Duration(b.OpenSec) * time.Second,
+		HalfOpenProbes: b.HalfOpenProbes,
+	})
+}
+
+// admit returns n when its breaker admits a request, otherwise another node
+// (not in tried) whose breaker does. Nothing was sent to a refused node, so
+// this is safe for any method. nil means every candidate is open: fail fast.
+func (p *backendPool) admit(n *backendNode, tried []*backendNode) *backendNode {
+	skip := slices.Clone(tried)
+	for n != nil && !n.breaker.Allow() {
+		skip = append(skip, n)
+		n = p.nextExcept(skip)
+	}
+	return n
+}
+
+// observe feeds the outcome of one upstream attempt to the node's breaker:
+// transport errors and 502/503/504 are failures. When the client went away
+// there is no verdict.
+func (n *backendNode) observe(r *http.Request, resp *http.Response, err error) {
+	switch {
+	case n.breaker == nil:
+	case err != nil && r.Context().Err() != nil:
+		n.breaker.Cancel()
+	case err != nil || breakerFailureStatus(resp.StatusCode):
+		n.breaker.Failure()
+	default:
+		n.breaker.Success()
+	}
+}
+
+func breakerFailureStatus(code int) bool {
+	return code == http.StatusBadGateway || code == http.StatusServiceUnavailable || code == http.StatusGatewayTimeout
+}
+
+// carryBreakers moves breaker state from the running table into a freshly
+// loaded one for nodes whose key and [breaker] settings are unchanged, so a
+// reload does not close an open breaker.
+func carryBreakers(old, rt *routeTable) {
+	if old == nil {
+		return
+	}
+	prev := make(map[string]*backendNode)
+	cfg := make(map[string]BreakerCfg)
+	for _, c := range old.uniqueChains() {
+		if c.pool == nil {
+			continue
+		}
+		for _, n := range c.pool.nodes {
+			prev[n.key] = n
+			cfg[n.key] = c.Breaker
+		}
+	}
+	for _, c := range rt.uniqueChains() {
+		if c.pool == nil {
+			continue
+		}
+		for _, n := range c.pool.nodes {
+			if p, ok := prev[n.key]; ok && p.breaker != nil && n.breaker != nil && cfg[n.key] == c.Breaker {
+				n.breaker = p.breaker
+			}
+		}
+	}
+}
+
+// logBackendStates prints the runtime state of every backend (health and
+// breaker) in --info style. Triggered by SIGUSR1.
+func logBackendStates() {
+	log.Println("[STATE] Backends:")
+	for _, c := range currentRoutes().uniqueChains() {
+		if c.pool == nil {
+			continue
+		}
+		log.Printf("  %s:", c.ChainName)
+		for _, n := range c.pool.nodes {
+			healthState := "UNCHECKED"
+			if healthChecker != nil && c.Health.Enabled {
+				if st, ok := healthChecker.Status(n.key); ok {
+					healthState = st.Reason
+				}
+			}
+			breakerState := "off"
+			if n.breaker != nil {
+				s := n.breaker.Snapshot()
+				breakerState = s.State.String()
+				if s.Reason != "" {
+					breakerState += " (" + s.Reason + " " + time.Since(s.Since).Truncate(time.Second).String() + " ago)"
+				}
+				log.Printf("    Backend %s: ip=%s health=%s inflight=%d breaker=%s consecutive=%d window=%d/%d opens=%d",
+					n.name, n.ip, healthState, n.inflight.Load(), breakerState, s.Consecutive, s.Errors, s.Requests, s.Opens)
+				continue
+			}
+			log.Printf("    Backend %s: ip=%s health=%s inflight=%d breaker=%s",
+				n.name, n.ip, healthState, n.inflight.Load(), breakerState)
+		}
+	}
+}
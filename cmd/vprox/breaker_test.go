@@ -0,0 +1,70 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// countingBackend starts an RPC backend that counts the requests it gets and
+// returns its port.
+func countingBackend(t *testing.T, hits *atomic.Int32) string {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		_, _ = w.Write([]byte(`{"result":{}}`))
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL[strings.LastIndex(srv.URL, ":")+1:]
+}
+
+func TestHandlerBreakerSkipIsNotAnAttempt(t *testing.T) {
+	var hitsA, hitsB atomic.Int32
+	rt := useRoutes(t, `chain_name = "test"
+host = "test.example.com"
+default_ports = true
+[expose]
+path = true
+[services]
+rpc = true
+[[backends]]
+name = "a"
+ip = "127.0.0.1"
+[backends.ports]
+rpc = `+countingBackend(t, &hitsA)+`
+[[backends]]
+name = "b"
+ip = "127.0.0.1"
+[backends.ports]
+rpc = `+countingBackend(t, &hitsB)+`
+[retry]
+enabled = true
+[breaker]
+enabled = true
+failures = 1
+`)
+	pool := rt.chains["test.example.com"].pool
+	// a is open and b is marked down, so the pool fails open over both and
+	// round robin hands out a once; admit must then skip to b unsent.
+	pool.nodes[0].breaker.Failure()
+	pool.nodes[1].down.Store(true)
+
+	for i := 0; i < 2; i++ {
+		r := withLogNotes(httptest.NewRequest("GET", "http://test.example.com/rpc/status", nil))
+		w := httptest.NewRecorder()
+		handler(w, r)
+		if w.Code != http.StatusOK {
+			t.Fatalf("request %d: status = %d, body %q", i, w.Code, w.Body)
+		}
+		for _, f := range logNotesOf(r) {
+			if f.Key == "attempts" {
+				t.Errorf("request %d: attempts = %v for a single send", i, f.Value)
+			}
+		}
+	}
+	if hitsA.Load() != 0 || hitsB.Load() != 2 {
+		t.Errorf("hits a/b = %d/%d, want 0/2", hitsA.Load(), hitsB.Load())
+	}
+}
@@ -0,0 +1,441 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	applog "github.com/vNodesV/vProx/internal/logging"
+	"github.com/vNodesV/vProx/internal/metrics"
+)
+
+// --------------------- BROADCAST FAN-OUT ---------------------
+
+// BroadcastCfg sends transaction broadcasts to several nodes in parallel
+// ([broadcast]): CometBFT broadcast_tx_sync/async/commit (URI or single
+// JSON-RPC call) and REST POST /cosmos/tx/v1beta1/txs. The first successful
+// response is returned; every node's result is logged with the tx hash.
+type BroadcastCfg struct {
+	Enabled  bool            `toml:"enabled"`
+	MaxNodes int             `toml:"max_nodes"` // nodes per broadcast, backends first (0 = all)
+	Sentries []BackendConfig `toml:"sentries"`  // extra nodes that only receive broadcasts
+}
+
+const (
+	restBroadcastPath = "/cosmos/tx/v1beta1/txs"
+
+	// broadcastDefaultTimeout bounds a broadcast when the route has no total
+	// timeout (broadcast_tx_commit waits for the block).
+	broadcastDefaultTimeout = 60 * time.Second
+
+	// maxBroadcastResponse bounds a node's response held for the success check.
+	maxBroadcastResponse = 8 << 20
+)
+
+var broadcastResults = metrics.NewCounter("vprox_broadcast_results_total",
+	"Per-node results of fanned-out tx broadcasts (ok, rejected, error).", "chain", "node", "result")
+
+var rpcBroadcastMethods = []string{"broadcast_tx_sync", "broadcast_tx_async", "broadcast_tx_commit"}
+
+func validateBroadcast(c *ChainConfig) error {
+	b := &c.Broadcast
+	if b.MaxNodes < 0 {
+		return errors.New("broadcast.max_nodes must be >= 0")
+	}
+	seen := make(map[string]bool, len(b.Sentries))
+	for i := range b.Sentries {
+		s := &b.Sentries[i]
+		s.IP = strings.TrimSpace(s.IP)
+		if net.ParseIP(s.IP) == nil {
+			return fmt.Errorf("broadcast.sentries[%d]: invalid ip: %q", i, s.IP)
+		}
+		s.Name = strings.TrimSpace(s.Name)
+		if s.Name == "" {
+			s.Name = s.IP
+		}
+		if seen[s.Name] {
+			return fmt.Errorf("broadcast.sentries[%d]: duplicate name %q", i, s.Name)
+		}
+		seen[s.Name] = true
+		for _, p := range []struct {
+			label string
+			v     int
+		}{{"rpc", s.Ports.RPC}, {"rest", s.Ports.REST}, {"api", s.Ports.API}} {
+			if p.v == 0 {
+				continue
+			}
+			if err := validatePortsLabel(p.label, p.v); err != nil {
+				return fmt.Errorf("broadcast.sentries[%d]: %w", i, err)
+			}
+		}
+	}
+	return nil
+}
+
+// broadcaster is the fan-out of one chain: its backend pool plus sentries.
+type broadcaster struct {
+	chain    string
+	pool     *backendPool
+	sentries []*backendNode
+	maxNodes int
+}
+
+func newBroadcaster(c *ChainConfig, defaults Ports) *broadcaster {
+	if !c.Broadcast.Enabled {
+		return nil
+	}
+	b := &broadcaster{chain: c.ChainName, pool: c.pool, maxNodes: c.Broadcast.MaxNodes}
+	base := effectivePorts(c, defaults)
+	for _, s := range c.Broadcast.Sentries {
+		label := "sentry:" + s.Name
+		b.sentries = append(b.sentries, &backendNode{
+			key:       c.ChainName + "/" + label,
+			name:      label,
+			ip:        s.IP,
+			ports:     overlayPorts(base, s.Ports),
+			weight:    1,
+			upstreams: newUpstreams(c, label),
+		})
+	}
+	return b
+}
+
+// broadcastTx reports whether r is a broadcast to fan out.
+func broadcastTx(r *http.Request, routePrefix, upstreamPath string, calls rpcCalls) bool {
+	switch routePrefix {
+	case rpcPrefix:
+		return !calls.Batch && len(calls.Calls) == 1 && matchMethod(rpcBroadcastMethods, calls.Calls[0].Method)
+	case restPrefix, apiPrefix:
+		return r.Method == http.MethodPost && upstreamPath == restBroadcastPath
+	}
+	return false
+}
+
+// txHash returns the CometBFT hash (upper-case hex SHA-256) of the tx a
+// broadcast carries, or "" when it cannot be decoded.
+func txHash(r *http.Request, routePrefix string, calls rpcCalls, body []byte) string {
+	var raw []byte
+	switch {
+	case routePrefix != rpcPrefix:
+		var req struct {
+			TxBytes []byte `json:"tx_bytes"` // base64
+		}
+		if json.Unmarshal(body, &req) == nil {
+			raw = req.TxBytes
+		}
+	case calls.URI:
+		v := r.URL.Query().Get("tx")
+		if h, ok := strings.CutPrefix(v, "0x"); ok {
+			raw, _ = hex.DecodeString(h)
+		} else {
+			raw, _ = base64.StdEncoding.DecodeString(strings.Trim(v, `"`))
+		}
+	default:
+		var named struct {
+			Tx []byte `json:"tx"`
+		}
+		var positional [][]byte
+		p := calls.Calls[0].Params
+		if json.Unmarshal(p, &named) == nil && named.Tx != nil {
+			raw = named.Tx
+		} else if json.Unmarshal(p, &positional) == nil && len(positional) > 0 {
+			raw = positional[0]
+		}
+	}
+	if len(raw) == 0 {
+		return ""
+	}
+	sum := sha256.Sum256(raw)
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+// broadcastResult is the outcome of one node.
+type broadcastResult struct {
+	node    *backendNode
+	resp    *http.Response // body drained into body
+	body    []byte
+	err     error
+	latency time.Duration
+	code    int64 // ABCI code (0 = accepted); -1 when the response carried none
+	ok      bool
+}
+
+// targets returns first (the load-balanced pick) followed by the other
+// usable backends and the sentries, capped at max_nodes. Backends whose
+// breaker refuses are left out.
+func (b *broadcaster) targets(first *backendNode) []*backendNode {
+	var out []*backendNode
+	add := func(n *backendNode) {
+		if (b.maxNodes == 0 || len(out) < b.maxNodes) && n.breaker.Allow() {
+			out = append(out, n)
+		}
+	}
+	add(first)
+	for _, n := range usableNodes(b.pool.serving) {
+		if n != first {
+			add(n)
+		}
+	}
+	for _, n := range b.sentries {
+		add(n)
+	}
+	return out
+}
+
+// fanOut sends the broadcast in r to every target and writes the first
+// successful response (HTTP 200 and ABCI code 0), or, if none succeeds, the
+// first response received. Nodes still running when the client has its
+// answer finish and are logged in the background. It reports whether a
+// response was written from a node.
+func (b *broadcaster) fanOut(w http.ResponseWriter, r *http.Request, first *backendNode, svc, path, routePrefix string, calls rpcCalls) bool {
+	// The broadcast timeout also bounds the client connection, body
+	// included.
+	timeout := first.upstream(svc).timeouts.total
+	if timeout <= 0 {
+		timeout = broadcastDefaultTimeout
+	}
+	setRouteDeadlines(w, timeout)
+
+	var body []byte
+	if r.Method == http.MethodPost {
+		var err error
+		if body, err = bufferBody(r); err != nil {
+			switch {
+			case errors.Is(err, errBodyTooLarge):
+				renderError(w, r, http.StatusRequestEntityTooLarge, "Request body too large", calls)
+			case r.GetBody != nil:
+				renderError(w, r, http.StatusBadGateway, "Request replay error", calls)
+			default:
+				renderError(w, r, http.StatusBadRequest, "Request body read error", calls)
+			}
+			return false
+		}
+	}
+	hash := txHash(r, routePrefix, calls, body)
+	targets := b.targets(first)
+	if len(targets) == 0 {
+		renderError(w, r, http.StatusServiceUnavailable, "Backend unavailable (circuit open)", calls)
+		return false
+	}
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(r.Context()), timeout)
+	requestID := applog.RequestIDFrom(r)
+	results := make(chan broadcastResult, len(targets))
+	for _, n := range targets {
+		req, err := newUpstreamRequest(ctx, r, n, svc, path, true)
+		if err != nil {
+			n.breaker.Cancel()
+			results <- broadcastResult{node: n, err: err, code: -1}
+			continue
+		}
+		req.Header = r.Header.Clone()
+		req.Header.Set(applog.RequestIDHeader, requestID)
+		req.Header.Set("X-Forwarded-Host", normalizeHost(r.Host))
+		if req.Header.Get("X-Forwarded-For") == "" {
+			req.Header.Set("X-Forwarded-For", clientIP(r))
+		}
+		go func() { results <- b.send(req, n, svc, routePrefix == rpcPrefix) }()
+	}
+
+	var winner, fallback *broadcastResult
+	seen := 0
+	for seen < len(targets) && winner == nil {
+		res := <-results
+		seen++
+		switch {
+		case res.ok:
+			winner = &res
+			b.logResult(requestID, hash, res, true)
+		case fallback == nil && res.err == nil:
+			fallback = &res // logged once it is known whether it is returned
+		default:
+			b.logResult(requestID, hash, res, false)
+		}
+	}
+	if fallback != nil {
+		b.logResult(requestID, hash, *fallback, winner == nil)
+	}
+	pick := winner
+	if pick == nil {
+		pick = fallback
+	}
+	// The rest finish in the background and are logged as they come.
+	go func() {
+		defer cancel()
+		for ; seen < len(targets); seen++ {
+			b.logResult(requestID, hash, <-results, false)
+		}
+	}()
+
+	notes := []applog.Field{applog.F("broadcastNodes", len(targets))}
+	if hash != "" {
+		notes = append(notes, applog.F("txHash", hash))
+	}
+	if pick == nil {
+		addLogNote(r, notes...)
+		renderError(w, r, http.StatusBadGateway, "Backend error", calls)
+		return false
+	}
+	addLogNote(r, append(notes, applog.F("broadcastWinner", pick.node.name))...)
+	copyEndToEndHeaders(w.Header(), pick.resp.Header)
+	w.WriteHeader(pick.resp.StatusCode)
+	_, err := w.Write(pick.body)
+	return err == nil
+}
+
+// send performs one node's broadcast and classifies the response.
+func (b *broadcaster) send(req *http.Request, n *backendNode, svc string, rpc bool) broadcastResult {
+	n.acquire()
+	defer n.release()
+	start := time.Now()
+	resp, err := n.upstream(svc).client.Do(req)
+	n.observe(req, resp, err)
+	res := broadcastResult{node: n, resp: resp, err: err, latency: time.Since(start), code: -1}
+	if err != nil {
+		return res
+	}
+	res.body, res.err = io.ReadAll(io.LimitReader(resp.Body, maxBroadcastResponse))
+	resp.Body.Close()
+	if res.err != nil {
+		return res
+	}
+	res.code = broadcastCode(res.body, rpc)
+	res.ok = resp.StatusCode == http.StatusOK && res.code == 0
+	return res
+}
+
+// broadcastCode extracts the ABCI code of a broadcast response: result.code
+// (JSON-RPC; check_tx/tx_result for commit) or tx_response.code (REST).
+// A JSON-RPC error or an unparsable body yields -1.
+func broadcastCode(body []byte, rpc bool) int64 {
+	type txCode struct {
+		Code *int64 `json:"code"`
+	}
+	if !rpc {
+		var v struct {
+			TxResponse *txCode `json:"tx_response"`
+		}
+		if json.Unmarshal(body, &v) != nil || v.TxResponse == nil {
+			return -1
+		}
+		if v.TxResponse.Code == nil {
+			return 0
+		}
+		return *v.TxResponse.Code
+	}
+	var v struct {
+		Error  json.RawMessage `json:"error"`
+		Result *struct {
+			txCode
+			CheckTx  *txCode `json:"check_tx"`
+			TxResult *txCode `json:"tx_result"`
+		} `json:"result"`
+	}
+	if json.Unmarshal(body, &v) != nil || v.Result == nil || len(v.Error) > 0 && !bytes.Equal(v.Error, []byte("null")) {
+		return -1
+	}
+	for _, c := range []*txCode{&v.Result.txCode, v.Result.CheckTx, v.Result.TxResult} {
+		if c != nil && c.Code != nil && *c.Code != 0 {
+			return *c.Code
+		}
+	}
+	return 0
+}
+
+// logResult writes one node's result; winner marks the response the client got.
+func (b *broadcaster) logResult(requestID, hash string, res broadcastResult, winner bool) {
+	result, level := "ok", "INFO"
+	switch {
+	case res.err != nil:
+		result, level = "error", "WARN"
+	case !res.ok:
+		result, level = "rejected", "WARN"
+	}
+	broadcastResults.Inc(b.chain, res.node.name, result)
+	if hash == "" {
+		hash = "-"
+	}
+	fields := []applog.Field{
+		applog.F("request_id", requestID),
+		applog.F("chain", b.chain),
+		applog.F("txHash", hash),
+		applog.F("node", res.node.name),
+		applog.F("result", result),
+	}
+	if res.resp != nil {
+		fields = append(fields, applog.F("status", res.resp.StatusCode))
+	}
+	if res.code >= 0 {
+		fields = append(fields, applog.F("code", res.code))
+	}
+	fields = append(fields, applog.F("latency_ms", res.latency.Milliseconds()), applog.F("winner", winner))
+	if res.err != nil {
+		fields = append(fields, applog.F("error", res.err.Error()))
+	}
+	applog.Print(level, "broadcast", "result", fields...)
+}
+
+// hopHeaders describe one connection and are not forwarded from a buffered
+// response; Content-Length is recomputed on write.
+var hopHeaders = []string{
+	"Connection", "Keep-Alive", "Proxy-Connection", "Proxy-Authenticate",
+	"Te", "Trailer", "Transfer-Encoding", "Upgrade", "Content-Length",
+}
+
+// copyEndToEndHeaders copies src into dst without hop-by-hop headers,
+// including the ones src's Connection header names.
+func copyEndToEndHeaders(dst, src http.Header) {
+	drop := slices.Clone(hopHeaders)
+	for _, v := range src.Values("Connection") {
+		for _, f := range strings.Split(v, ",") {
+			if f = strings.TrimSpace(f); f != "" {
+				drop = append(drop, http.CanonicalHeaderKey(f))
+			}
+		}
+	}
+	for k, v := range src {
+		if !slices.Contains(drop, k) {
+			dst[k] = v
+		}
+	}
+}
+
+// errBodyTooLarge is returned by bufferBody for bodies over maxJSONRPCBody.
+var errBodyTooLarge = errors.New("request body too large")
+
+// bufferBody reads r's body (up to maxJSONRPCBody) and makes it replayable
+// through r.GetBody. JSON-RPC bodies are already buffered by inspectRPC.
+func bufferBody(r *http.Request) ([]byte, error) {
+	if r.GetBody != nil {
+		rc, err := r.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		defer rc.Close()
+		return io.ReadAll(rc)
+	}
+	raw, err := io.ReadAll(io.LimitReader(r.Body, maxJSONRPCBody+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) > maxJSONRPCBody {
+		return nil, errBodyTooLarge
+	}
+	_ = r.Body.Close()
+	r.Body = io.NopCloser(bytes.NewReader(raw))
+	r.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(raw)), nil
+	}
+	return raw, nil
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBroadcastCode(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		rpc  bool
+		want int64
+	}{
+		{"rpc accepted", `{"result":{"code":0,"hash":"AB"}}`, true, 0},
+		{"rpc code omitted", `{"result":{"hash":"AB"}}`, true, 0},
+		{"rpc rejected", `{"result":{"code":19}}`, true, 19},
+		{"commit check_tx", `{"result":{"check_tx":{"code":5},"tx_result":{"code":0}}}`, true, 5},
+		{"commit tx_result", `{"result":{"check_tx":{"code":0},"tx_result":{"code":11}}}`, true, 11},
+		{"rpc error", `{"error":{"code":-32603,"message":"x"}}`, true, -1},
+		{"rpc null error", `{"error":null,"result":{"code":0}}`, true, 0},
+		{"rpc unparsable", `<html>`, true, -1},
+		{"rest accepted", `{"tx_response":{"code":0}}`, false, 0},
+		{"rest code omitted", `{"tx_response":{"txhash":"AB"}}`, false, 0},
+		{"rest rejected", `{"tx_response":{"code":13}}`, false, 13},
+		{"rest error body", `{"code":3,"message":"invalid"}`, false, -1},
+	}
+	for _, tt := range tests {
+		if got := broadcastCode([]byte(tt.body), tt.rpc); got != tt.want {
+			t.Errorf("%s: broadcastCode = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestTxHash(t *testing.T) {
+	tx := []byte("signed tx bytes")
+	sum := sha256.Sum256(tx)
+	want := strings.ToUpper(hex.EncodeToString(sum[:]))
+	b64 := base64.StdEncoding.EncodeToString(tx)
+
+	tests := []struct {
+		name   string
+		url    string
+		prefix string
+		body   string
+		want   string
+	}{
+		{"uri base64", "/broadcast_tx_sync?tx=%22" + b64 + "%22", rpcPrefix, "", want},
+		{"uri hex", "/broadcast_tx_sync?tx=0x" + hex.EncodeToString(tx), rpcPrefix, "", want},
+		{"uri garbage", "/broadcast_tx_sync?tx=%%%", rpcPrefix, "", ""},
+		{"jsonrpc named", "/", rpcPrefix, `{"jsonrpc":"2.0","id":1,"method":"broadcast_tx_sync","params":{"tx":"` + b64 + `"}}`, want},
+		{"jsonrpc positional", "/", rpcPrefix, `{"jsonrpc":"2.0","id":1,"method":"broadcast_tx_sync","params":["` + b64 + `"]}`, want},
+		{"jsonrpc no tx", "/", rpcPrefix, `{"jsonrpc":"2.0","id":1,"method":"broadcast_tx_sync","params":{}}`, ""},
+		{"rest", restBroadcastPath, restPrefix, `{"tx_bytes":"` + b64 + `","mode":"BROADCAST_MODE_SYNC"}`, want},
+		{"rest not json", restBroadcastPath, restPrefix, `tx`, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			method := "GET"
+			if tt.body != "" {
+				method = "POST"
+			}
+			r := httptest.NewRequest(method, tt.url, strings.NewReader(tt.body))
+			var calls rpcCalls
+			if tt.prefix == rpcPrefix {
+				var err error
+				if calls, err = inspectRPC(r, strings.TrimPrefix(r.URL.Path, "/")); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got := txHash(r, tt.prefix, calls, []byte(tt.body)); got != tt.want {
+				t.Errorf("txHash = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// broadcastNode starts a node that answers a broadcast after delay and
+// counts the requests it gets; it returns the node's port.
+func broadcastNode(t *testing.T, delay time.Duration, status int, body string, header http.Header, hits *atomic.Int32) string {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		time.Sleep(delay)
+		for k, v := range header {
+			w.Header()[k] = v
+		}
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL[strings.LastIndex(srv.URL, ":")+1:]
+}
+
+func broadcastChain(ports ...string) string {
+	var b strings.Builder
+	b.WriteString("chain_name = \"test\"\nhost = \"test.example.com\"\ndefault_ports = true\n" +
+		"[expose]\npath = true\n[services]\nrpc = true\nrest = true\n[broadcast]\nenabled = true\n")
+	for i, p := range ports {
+		b.WriteString("[[backends]]\nname = \"" + string(rune('a'+i)) + "\"\nip = \"127.0.0.1\"\n" +
+			"[backends.ports]\nrpc = " + p + "\nrest = " + p + "\n")
+	}
+	return b.String()
+}
+
+func TestFanOut(t *testing.T) {
+	const rpcTx = `{"jsonrpc":"2.0","id":1,"method":"broadcast_tx_sync","params":{"tx":"dHg="}}`
+	rejected := `{"jsonrpc":"2.0","id":1,"result":{"code":5,"log":"insufficient funds"}}`
+	rejected2 := `{"jsonrpc":"2.0","id":1,"result":{"code":19,"log":"tx already in cache"}}`
+	accepted := `{"jsonrpc":"2.0","id":1,"result":{"code":0,"hash":"AB"}}`
+	hop := http.Header{
+		"Connection": {"X-Node-Hop"},
+		"X-Node-Hop": {"1"},
+		"Keep-Alive": {"timeout=5"},
+		"X-End":      {"kept"},
+	}
+	type node struct {
+		delay  time.Duration
+		status int
+		body   string
+	}
+	tests := []struct {
+		name       string
+		nodes      []node
+		wantStatus int
+		wantBody   string
+	}{
+		{"first success wins over an earlier rejection",
+			[]node{{0, 200, rejected}, {50 * time.Millisecond, 200, accepted}}, 200, accepted},
+		{"first response when none succeeds",
+			[]node{{0, 200, rejected}, {50 * time.Millisecond, 200, rejected2}}, 200, rejected},
+		{"non-200 is not a success",
+			[]node{{0, 503, `{"result":{"code":0}}`}, {50 * time.Millisecond, 200, accepted}}, 200, accepted},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hits := make([]atomic.Int32, len(tt.nodes))
+			var ports []string
+			for i, n := range tt.nodes {
+				ports = append(ports, broadcastNode(t, n.delay, n.status, n.body, hop, &hits[i]))
+			}
+			useRoutes(t, broadcastChain(ports...))
+
+			r := withLogNotes(httptest.NewRequest("POST", "http://test.example.com/rpc/", strings.NewReader(rpcTx)))
+			w := httptest.NewRecorder()
+			handler(w, r)
+			if w.Code != tt.wantStatus || w.Body.String() != tt.wantBody {
+				t.Fatalf("got %d %q, want %d %q", w.Code, w.Body, tt.wantStatus, tt.wantBody)
+			}
+			for i := range hits {
+				if hits[i].Load() != 1 {
+					t.Errorf("node %d got %d requests, want 1", i, hits[i].Load())
+				}
+			}
+			h := w.Header()
+			if h.Get("X-End") != "kept" {
+				t.Error("end-to-end header dropped")
+			}
+			for _, k := range []string{"Connection", "Keep-Alive", "X-Node-Hop"} {
+				if h.Get(k) != "" {
+					t.Errorf("hop-by-hop header %s forwarded", k)
+				}
+			}
+		})
+	}
+}
+
+func TestFanOutNoResponse(t *testing.T) {
+	var ports []string
+	for range 2 {
+		dead := httptest.NewServer(http.NotFoundHandler())
+		dead.Close()
+		ports = append(ports, dead.URL[strings.LastIndex(dead.URL, ":")+1:])
+	}
+	useRoutes(t, broadcastChain(ports...))
+
+	r := withLogNotes(httptest.NewRequest("POST", "http://test.example.com/rpc/",
+		strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"broadcast_tx_sync","params":{"tx":"dHg="}}`)))
+	w := httptest.NewRecorder()
+	handler(w, r)
+	if w.Code != http.StatusBadGateway {
+		t.Fatalf("status = %d, want 502", w.Code)
+	}
+}
+
+type errReader struct{}
+
+func (errReader) Read([]byte) (int, error) { return 0, errors.New("client went away") }
+
+func TestFanOutBodyErrors(t *testing.T) {
+	var hits atomic.Int32
+	useRoutes(t, broadcastChain(broadcastNode(t, 0, 200, `{"tx_response":{"code":0}}`, nil, &hits)))
+	tests := []struct {
+		name string
+		body func() *http.Request
+		want int
+	}{
+		{"too large", func() *http.Request {
+			return httptest.NewRequest("POST", "http://test.example.com/rest"+restBroadcastPath,
+				strings.NewReader(strings.Repeat("x", maxJSONRPCBody+1)))
+		}, http.StatusRequestEntityTooLarge},
+		{"read error", func() *http.Request {
+			return httptest.NewRequest("POST", "http://test.example.com/rest"+restBroadcastPath, errReader{})
+		}, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handler(w, withLogNotes(tt.body()))
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+	if hits.Load() != 0 {
+		t.Errorf("node got %d requests for bodies that were not read", hits.Load())
+	}
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vNodesV/vProx/internal/cache"
+)
+
+// --------------------- RESPONSE CACHE ---------------------
+
+// CacheRule overrides the TTL for upstream paths under Path on a service.
+type CacheRule struct {
+	Route  string `toml:"route"`   // rpc | rest | "" (both)
+	Path   string `toml:"path"`    // upstream path prefix, e.g. "/validators"
+	TTLSec int    `toml:"ttl_sec"` // 0 = never cache
+}
+
+// CacheCfg configures the per-chain response cache ([cache]).
+type CacheCfg struct {
+	Enabled      bool        `toml:"enabled"`
+	HeightTTLSec int         `toml:"height_ttl_sec"` // explicit height queries (default 3600)
+	LatestTTLSec int         `toml:"latest_ttl_sec"` // /status, latest, height-less (default 2)
+	MaxEntryKB   int         `toml:"max_entry_kb"`   // larger bodies are not cached (default 1024)
+	Rules        []CacheRule `toml:"rules"`
+}
+
+// respCache is shared by all chains; keys are prefixed with the chain name.
+// nil when VPROX_CACHE_MAX_MB=0.
+var respCache *cache.Cache
+
+func validateCache(c *ChainConfig) error {
+	cc := &c.Cache
+	if cc.HeightTTLSec <= 0 {
+		cc.HeightTTLSec = 3600
+	}
+	if cc.LatestTTLSec < 0 {
+		return errors.New("cache.latest_ttl_sec must be >= 0")
+	}
+	if cc.LatestTTLSec == 0 {
+		cc.LatestTTLSec = 2
+	}
+	if cc.MaxEntryKB <= 0 {
+		cc.MaxEntryKB = 1024
+	}
+	for i := range cc.Rules {
+		rule := &cc.Rules[i]
+		rule.Route = strings.ToLower(strings.TrimSpace(rule.Route))
+		switch rule.Route {
+		case "", "rpc", "rest":
+		default:
+			return fmt.Errorf("cache.rules[%d].route must be rpc|rest, got %q", i, rule.Route)
+		}
+		if !strings.HasPrefix(rule.Path, "/") {
+			return fmt.Errorf("cache.rules[%d].path must start with /, got %q", i, rule.Path)
+		}
+		if rule.TTLSec < 0 {
+			return fmt.Errorf("cache.rules[%d].ttl_sec must be >= 0", i)
+		}
+	}
+	return nil
+}
+
+// rpcHeightPaths are CometBFT endpoints taking an optional ?height=.
+// Without it they return the latest state.
+var rpcHeightPaths = map[string]bool{
+	"/block":            true,
+	"/block_results":    true,
+	"/commit":           true,
+	"/header":           true,
+	"/validators":       true,
+	"/consensus_params": true,
+}
+
+var restHeightPath = regexp.MustCompile(`^(?:/cosmos/base/tendermint/v1beta1)?/(?:blocks|validatorsets)/(\d+|latest)$`)
+
+// cacheService maps a route prefix to the cache service name ("" = uncached).
+func cacheService(routePrefix string) string {
+	switch routePrefix {
+	case rpcPrefix:
+		return "rpc"
+	case restPrefix, apiPrefix:
+		return "rest"
+	}
+	return ""
+}
+
+// cacheTTLFor classifies an upstream request: explicit past heights get the
+// long TTL, /status and latest/height-less queries the short one, and rules
+// override both. Zero means not cacheable.
+func cacheTTLFor(cc *CacheCfg, svc, path string, r *http.Request) time.Duration {
+	for _, rule := range cc.Rules {
+		if (rule.Route == "" || rule.Route == svc) && strings.HasPrefix(path, rule.Path) {
+			return time.Duration(rule.TTLSec) * time.Second
+		}
+	}
+	long := time.Duration(cc.HeightTTLSec) * time.Second
+	short := time.Duration(cc.LatestTTLSec) * time.Second
+
+	switch svc {
+	case "rpc":
+		if path == "/status" || path == "/abci_info" {
+			return short
+		}
+		if rpcHeightPaths[path] {
+			if h := r.URL.Query().Get("height"); h != "" {
+				if n, err := strconv.ParseInt(strings.Trim(h, `"`), 10, 64); err == nil && n > 0 {
+					return long
+				}
+				return 0
+			}
+			return short
+		}
+	case "rest":
+		if h := r.Header.Get("x-cosmos-block-height"); h != "" {
+			if n, err := strconv.ParseInt(h, 10, 64); err == nil && n > 0 {
+				return long
+			}
+			return 0
+		}
+		if m := restHeightPath.FindStringSubmatch(path); m != nil {
+			if m[1] == "latest" {
+				return short
+			}
+			return long
+		}
+	}
+	return 0
+}
+
+// cacheKeyFor returns the cache key and TTL for r, or "" when the request is
+// not cacheable (method, auth, client no-store, chain disabled, no rule).
+func cacheKeyFor(chain *ChainConfig, routePrefix, upstreamPath string, r *http.Request) (string, time.Duration) {
+	if respCache == nil || !chain.Cache.Enabled || r.Method != http.MethodGet {
+		return "", 0
+	}
+	svc := cacheService(routePrefix)
+	if svc == "" || r.Header.Get("Authorization") != "" {
+		return "", 0
+	}
+	if cacheControlHas(r.Header, "no-store") {
+		return "", 0
+	}
+	ttl := cacheTTLFor(&chain.Cache, svc, upstreamPath, r)
+	if ttl <= 0 {
+		return "", 0
+	}
+	enc := "identity"
+	if acceptsGzip(r) {
+		enc = "gzip"
+	}
+	key := chain.ChainName + "|" + svc + "|" + upstreamPath + "?" + r.URL.RawQuery +
+		"|h=" + r.Header.Get("x-cosmos-block-height") + "|" + enc
+	return key, ttl
+}
+
+// cacheControlHas reports whether Cache-Control in h carries directive.
+func cacheControlHas(h http.Header, directive string) bool {
+	_, ok := cacheControlDirective(h, directive)
+	return ok
+}
+
+func cacheControlDirective(h http.Header, directive string) (string, bool) {
+	for _, line := range h.Values("Cache-Control") {
+		for _, part := range strings.Split(line, ",") {
+			name, val, _ := strings.Cut(strings.TrimSpace(part), "=")
+			if strings.EqualFold(name, directive) {
+				return strings.Trim(val, `"`), true
+			}
+		}
+	}
+	return "", false
+}
+
+// storableTTL caps ttl by the upstream response's Cache-Control and returns
+// 0 when the response must not be stored.
+func storableTTL(resp *http.Response, ttl time.Duration) time.Duration {
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Set-Cookie") != "" || resp.Header.Get("Vary") == "*" {
+		return 0
+	}
+	for _, d := range []string{"no-store", "no-cache", "private"} {
+		if cacheControlHas(resp.Header, d) {
+			return 0
+		}
+	}
+	for _, d := range []string{"s-maxage", "max-age"} {
+		if v, ok := cacheControlDirective(resp.Header, d); ok {
+			if n, err := strconv.Atoi(v); err == nil {
+				if age := time.Duration(n) * time.Second; age < ttl {
+					ttl = age
+				}
+			}
+			break
+		}
+	}
+	return ttl
+}
+
+// captureBuffer collects up to max bytes of a streamed body. It never fails
+// the write, so it is safe inside io.MultiWriter next to the client.
+type captureBuffer struct {
+	buf      bytes.Buffer
+	max      int
+	overflow bool
+}
+
+func (c *captureBuffer) Write(p []byte) (int, error) {
+	if !c.overflow {
+		if c.buf.Len()+len(p) > c.max {
+			c.overflow = true
+			c.buf = bytes.Buffer{}
+		} else {
+			c.buf.Write(p)
+		}
+	}
+	return len(p), nil
+}
+
+// storeCached saves a fully streamed upstream response.
+func storeCached(key string, ttl time.Duration, svc string, resp *http.Response, body []byte) {
+	if svc == "rpc" && isJSONRPCError(body, resp.Header.Get("Content-Encoding")) {
+		return
+	}
+	h := resp.Header.Clone()
+	for _, k := range []string{"Content-Length", "Connection", "Keep-Alive", "Transfer-Encoding", "Date", "Age"} {
+		h.Del(k)
+	}
+	now := time.Now()
+	respCache.Set(key, &cache.Entry{
+		Status:  resp.StatusCode,
+		Header:  h,
+		Body:    body,
+		Stored:  now,
+		Expires: now.Add(ttl),
+	})
+}
+
+// isJSONRPCError reports whether body is a JSON-RPC error reply; CometBFT
+// returns those with HTTP 200 on some versions. Only the head is inspected.
+func isJSONRPCError(body []byte, encoding string) bool {
+	head := body
+	if strings.EqualFold(encoding, "gzip") {
+		zr, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return true
+		}
+		head, _ = io.ReadAll(io.LimitReader(zr, 128))
+	}
+	if len(head) > 128 {
+		head = head[:128]
+	}
+	return bytes.Contains(head, []byte(`"error"`))
+}
+
+// serveCached writes a cached entry with X-Cache: HIT and Age.
+func serveCached(w http.ResponseWriter, e *cache.Entry) {
+	for k, vv := range e.Header {
+		for _, v := range vv {
+			w.Header().Add(k, v)
+		}
+	}
+	w.Header().Set("X-Cache", "HIT")
+	w.Header().Set("Age", strconv.Itoa(int(time.Since(e.Stored)/time.Second)))
+	w.WriteHeader(e.Status)
+	_, _ = w.Write(e.Body)
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// --------------------- CORS ---------------------
+
+// CORSCfg is the per-chain CORS policy ([cors]). When enabled, vProx answers
+// preflights itself and replaces whatever CORS headers the backend sends.
+type CORSCfg struct {
+	Enabled          bool     `toml:"enabled"`
+	AllowOrigins     []string `toml:"allow_origins"`  // "*", exact origins, or "https://*.example.com"
+	AllowMethods     []string `toml:"allow_methods"`  // default GET, HEAD, POST, OPTIONS
+	AllowHeaders     []string `toml:"allow_headers"`  // request headers; "*" = any
+	ExposeHeaders    []string `toml:"expose_headers"` // response headers readable by scripts
+	MaxAgeSec        int      `toml:"max_age_sec"`    // preflight cache (default 600)
+	AllowCredentials bool     `toml:"allow_credentials"`
+}
+
+var (
+	defaultCORSMethods = []string{"GET", "HEAD", "POST", "OPTIONS"}
+	defaultCORSHeaders = []string{"Content-Type", "Authorization", "X-Requested-With", "X-Cosmos-Block-Height",
+		"X-Grpc-Web", "X-User-Agent", "Grpc-Timeout", "X-API-Key"}
+	defaultCORSExpose = []string{"X-Request-ID", "X-Cache", "Retry-After", "Grpc-Status", "Grpc-Message"}
+)
+
+func validateCORS(c *ChainConfig) error {
+	cc := &c.CORS
+	if !cc.Enabled {
+		return nil
+	}
+	if len(cc.AllowOrigins) == 0 {
+		cc.AllowOrigins = []string{"*"}
+	}
+	for i, o := range cc.AllowOrigins {
+		o = strings.ToLower(strings.TrimRight(strings.TrimSpace(o), "/"))
+		if o == "" || o != "*" && (!strings.Contains(o, "://") || strings.Count(o, "*") > 1) {
+			return fmt.Errorf("cors.allow_origins: invalid origin %q", cc.AllowOrigins[i])
+		}
+		cc.AllowOrigins[i] = o
+	}
+	if cc.AllowCredentials && slices.Contains(cc.AllowOrigins, "*") {
+		return errors.New(`cors: allow_credentials needs explicit allow_origins, not "*"`)
+	}
+	if len(cc.AllowMethods) == 0 {
+		cc.AllowMethods = defaultCORSMethods
+	}
+	for i, m := range cc.AllowMethods {
+		cc.AllowMethods[i] = strings.ToUpper(strings.TrimSpace(m))
+	}
+	if len(cc.AllowHeaders) == 0 {
+		cc.AllowHeaders = defaultCORSHeaders
+	}
+	if len(cc.ExposeHeaders) == 0 {
+		cc.ExposeHeaders = defaultCORSExpose
+	}
+	if cc.MaxAgeSec < 0 {
+		return fmt.Errorf("cors.max_age_sec must be >= 0")
+	}
+	if cc.MaxAgeSec == 0 {
+		cc.MaxAgeSec = 600
+	}
+	return nil
+}
+
+// allowsOrigin matches origin against allow_origins ("*" and one "*"
+// wildcard per entry, e.g. "https://*.example.com").
+func (cc *CORSCfg) allowsOrigin(origin string) bool {
+	origin = strings.ToLower(origin)
+	for _, o := range cc.AllowOrigins {
+		if o == "*" || o == origin {
+			return true
+		}
+		if pre, suf, ok := strings.Cut(o, "*"); ok && len(origin) > len(pre)+len(suf) &&
+			strings.HasPrefix(origin, pre) && strings.HasSuffix(origin, suf) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowOriginValue is the Access-Control-Allow-Origin for an allowed origin:
+// "*" for a public policy, the origin itself otherwise. validateCORS refuses
+// "*" together with credentials.
+func (cc *CORSCfg) allowOriginValue(origin string) string {
+	if slices.Contains(cc.AllowOrigins, "*") {
+		return "*"
+	}
+	return origin
+}
+
+// allowsHeaders reports whether every header of an
+// Access-Control-Request-Headers list is allowed.
+func (cc *CORSCfg) allowsHeaders(list string) bool {
+	if slices.Contains(cc.AllowHeaders, "*") {
+		return true
+	}
+	for _, h := range strings.Split(list, ",") {
+		h = strings.TrimSpace(h)
+		if h == "" {
+			continue
+		}
+		if !slices.ContainsFunc(cc.AllowHeaders, func(a string) bool { return strings.EqualFold(a, h) }) {
+			return false
+		}
+	}
+	return true
+}
+
+// isPreflight reports whether r is a CORS preflight request.
+func isPreflight(r *http.Request) bool {
+	return r.Method == http.MethodOptions && r.Header.Get("Origin") != "" &&
+		r.Header.Get("Access-Control-Request-Method") != ""
+}
+
+// handlePreflight answers a preflight from the chain's policy: 204 with the
+// allow headers, or 403 when origin, method or headers are not allowed.
+func handlePreflight(w http.ResponseWriter, r *http.Request, cc *CORSCfg) bool {
+	origin := r.Header.Get("Origin")
+	method := strings.ToUpper(r.Header.Get("Access-Control-Request-Method"))
+	reqHeaders := r.Header.Get("Access-Control-Request-Headers")
+
+	h := w.Header()
+	h.Add("Vary", "Origin, Access-Control-Request-Method, Access-Control-Request-Headers")
+	if !cc.allowsOrigin(origin) || !slices.Contains(cc.AllowMethods, method) || !cc.allowsHeaders(reqHeaders) {
+		writeError(w, r, http.StatusForbidden, "CORS preflight rejected")
+		return false
+	}
+	h.Set("Access-Control-Allow-Origin", cc.allowOriginValue(origin))
+	h.Set("Access-Control-Allow-Methods", strings.Join(cc.AllowMethods, ", "))
+	if reqHeaders != "" {
+		if slices.Contains(cc.AllowHeaders, "*") {
+			h.Set("Access-Control-Allow-Headers", reqHeaders)
+		} else {
+			h.Set("Access-Control-Allow-Headers", strings.Join(cc.AllowHeaders, ", "))
+		}
+	}
+	if cc.AllowCredentials {
+		h.Set("Access-Control-Allow-Credentials", "true")
+	}
+	h.Set("Access-Control-Max-Age", strconv.Itoa(cc.MaxAgeSec))
+	w.WriteHeader(http.StatusNoContent)
+	return true
+}
+
+// applyCORS replaces any Access-Control-* headers in h (e.g. relayed from
+// the backend) with the chain policy for origin.
+func applyCORS(h http.Header, cc *CORSCfg, origin string) {
+	for k := range h {
+		if strings.HasPrefix(k, "Access-Control-") {
+			delete(h, k)
+		}
+	}
+	if !slices.Contains(h.Values("Vary"), "Origin") {
+		h.Add("Vary", "Origin")
+	}
+	if origin == "" || !cc.allowsOrigin(origin) {
+		return
+	}
+	h.Set("Access-Control-Allow-Origin", cc.allowOriginValue(origin))
+	if cc.AllowCredentials {
+		h.Set("Access-Control-Allow-Credentials", "true")
+	}
+	if len(cc.ExposeHeaders) > 0 {
+		h.Set("Access-Control-Expose-Headers", strings.Join(cc.ExposeHeaders, ", "))
+	}
+}
+
+// withCORS applies the CORS policy of the request's chain to the response,
+// including the 401/403/429 answered by access lists, API keys and the
+// limiter before the handler runs, so browsers can read those errors.
+// Preflights are answered by the handler, after those checks; native gRPC
+// is left alone.
+func withCORS(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if chain := currentRoutes().chains[normalizeHost(r.Host)]; chain != nil && chain.CORS.Enabled && !isPreflight(r) && !isGRPCRequest(r) {
+			w = newCORSWriter(w, r, &chain.CORS)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// corsWriter applies the CORS policy to the response headers right before
+// they are written, whatever path (proxied, cached, error) produced them.
+type corsWriter struct {
+	http.ResponseWriter
+	cc      *CORSCfg
+	origin  string
+	applied bool
+}
+
+func newCORSWriter(w http.ResponseWriter, r *http.Request, cc *CORSCfg) *corsWriter {
+	return &corsWriter{ResponseWriter: w, cc: cc, origin: r.Header.Get("Origin")}
+}
+
+func (c *corsWriter) WriteHeader(code int) {
+	if !c.applied {
+		c.applied = true
+		applyCORS(c.Header(), c.cc, c.origin)
+	}
+	c.ResponseWriter.WriteHeader(code)
+}
+
+func (c *corsWriter) Write(b []byte) (int, error) {
+	if !c.applied {
+		c.WriteHeader(http.StatusOK)
+	}
+	return c.ResponseWriter.Write(b)
+}
+
+// Flush keeps streaming responses streaming through the wrapper.
+func (c *corsWriter) Flush() {
+	if !c.applied {
+		c.WriteHeader(http.StatusOK)
+	}
+	_ = http.NewResponseController(c.ResponseWriter).Flush()
+}
+
+// Hijack is used by the WebSocket upgrade (gorilla asserts http.Hijacker).
+func (c *corsWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := c.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("hijack not supported")
+	}
+	return h.Hijack()
+}
+
+// Unwrap lets http.ResponseController reach the connection (deadlines).
+func (c *corsWriter) Unwrap() http.ResponseWriter { return c.ResponseWriter }
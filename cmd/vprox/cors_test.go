@@ -0,0 +1,186 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestValidateCORS(t *testing.T) {
+	tests := []struct {
+		name        string
+		cors        CORSCfg
+		wantErr     string
+		wantOrigins string
+	}{
+		{"disabled is not checked", CORSCfg{AllowOrigins: []string{"bad"}}, "", "bad"},
+		{"default origins", CORSCfg{Enabled: true}, "", "*"},
+		{"normalized", CORSCfg{Enabled: true, AllowOrigins: []string{" HTTPS://App.Example.com/ ", "https://*.example.com"}}, "",
+			"https://app.example.com,https://*.example.com"},
+		{"no scheme", CORSCfg{Enabled: true, AllowOrigins: []string{"example.com"}}, "invalid origin", ""},
+		{"two wildcards", CORSCfg{Enabled: true, AllowOrigins: []string{"https://*.*.example.com"}}, "invalid origin", ""},
+		{"empty origin", CORSCfg{Enabled: true, AllowOrigins: []string{" "}}, "invalid origin", ""},
+		{"credentials with explicit origins", CORSCfg{Enabled: true, AllowCredentials: true, AllowOrigins: []string{"https://app.test"}}, "", "https://app.test"},
+		{"credentials with any origin", CORSCfg{Enabled: true, AllowCredentials: true}, "allow_credentials", ""},
+		{"credentials with listed star", CORSCfg{Enabled: true, AllowCredentials: true, AllowOrigins: []string{"https://app.test", "*"}}, "allow_credentials", ""},
+		{"negative max age", CORSCfg{Enabled: true, MaxAgeSec: -1}, "max_age_sec", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &ChainConfig{CORS: tt.cors}
+			err := validateCORS(c)
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Fatalf("unexpected error: %v", err)
+			case tt.wantErr != "":
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if got := strings.Join(c.CORS.AllowOrigins, ","); got != tt.wantOrigins {
+				t.Errorf("origins = %q, want %q", got, tt.wantOrigins)
+			}
+			if c.CORS.Enabled && (len(c.CORS.AllowMethods) == 0 || c.CORS.MaxAgeSec != 600) {
+				t.Errorf("defaults not applied: %+v", c.CORS)
+			}
+		})
+	}
+}
+
+func TestCORSAllowsOrigin(t *testing.T) {
+	cc := &CORSCfg{AllowOrigins: []string{"https://app.test", "https://*.example.com", "http://localhost:*"}}
+	tests := []struct {
+		origin string
+		want   bool
+	}{
+		{"https://app.test", true},
+		{"HTTPS://APP.TEST", true},
+		{"http://app.test", false},
+		{"https://app.test.evil.com", false},
+		{"https://wallet.example.com", true},
+		{"https://a.b.example.com", true},
+		{"https://.example.com", false}, // the wildcard must match something
+		{"https://example.com", false},
+		{"https://evilexample.com", false},
+		{"https://example.com.evil.com", false},
+		{"http://localhost:3000", true},
+		{"http://localhost:", false},
+		{"", false},
+		{"null", false},
+	}
+	for _, tt := range tests {
+		if got := cc.allowsOrigin(tt.origin); got != tt.want {
+			t.Errorf("allowsOrigin(%q) = %v, want %v", tt.origin, got, tt.want)
+		}
+	}
+	if !(&CORSCfg{AllowOrigins: []string{"*"}}).allowsOrigin("https://anything.test") {
+		t.Error(`"*" must allow every origin`)
+	}
+}
+
+func TestCORSAllowsHeaders(t *testing.T) {
+	cc := &CORSCfg{AllowHeaders: []string{"Content-Type", "X-API-Key"}}
+	tests := []struct {
+		list string
+		want bool
+	}{
+		{"", true},
+		{"content-type", true},
+		{"Content-Type, x-api-key", true},
+		{"content-type,,", true},
+		{"content-type, x-evil", false},
+	}
+	for _, tt := range tests {
+		if got := cc.allowsHeaders(tt.list); got != tt.want {
+			t.Errorf("allowsHeaders(%q) = %v, want %v", tt.list, got, tt.want)
+		}
+	}
+	if !(&CORSCfg{AllowHeaders: []string{"*"}}).allowsHeaders("x-anything") {
+		t.Error(`"*" must allow every header`)
+	}
+}
+
+func TestApplyCORS(t *testing.T) {
+	public := &CORSCfg{AllowOrigins: []string{"*"}, ExposeHeaders: []string{"X-Request-ID"}}
+	creds := &CORSCfg{AllowOrigins: []string{"https://app.test"}, AllowCredentials: true}
+	tests := []struct {
+		name      string
+		cc        *CORSCfg
+		origin    string
+		wantAllow string
+		wantCreds string
+	}{
+		{"public", public, "https://x.test", "*", ""},
+		{"credentials echo the origin", creds, "https://app.test", "https://app.test", "true"},
+		{"origin not allowed", creds, "https://evil.test", "", ""},
+		{"no origin", public, "", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := http.Header{
+				"Access-Control-Allow-Origin":  {"https://backend.test"},
+				"Access-Control-Allow-Methods": {"PUT"},
+			}
+			applyCORS(h, tt.cc, tt.origin)
+			if got := h.Get("Access-Control-Allow-Origin"); got != tt.wantAllow {
+				t.Errorf("Allow-Origin = %q, want %q", got, tt.wantAllow)
+			}
+			if got := h.Get("Access-Control-Allow-Credentials"); got != tt.wantCreds {
+				t.Errorf("Allow-Credentials = %q, want %q", got, tt.wantCreds)
+			}
+			if h.Get("Access-Control-Allow-Methods") != "" {
+				t.Error("backend CORS headers must be removed")
+			}
+			if h.Get("Vary") != "Origin" {
+				t.Errorf("Vary = %q, want Origin", h.Get("Vary"))
+			}
+		})
+	}
+}
+
+func TestHandlePreflight(t *testing.T) {
+	c := &ChainConfig{CORS: CORSCfg{Enabled: true, AllowOrigins: []string{"https://app.test"}, AllowCredentials: true}}
+	if err := validateCORS(c); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		origin  string
+		method  string
+		headers string
+		want    int
+	}{
+		{"allowed", "https://app.test", "POST", "content-type", http.StatusNoContent},
+		{"lowercase method", "https://app.test", "post", "", http.StatusNoContent},
+		{"origin refused", "https://evil.test", "POST", "", http.StatusForbidden},
+		{"method refused", "https://app.test", "DELETE", "", http.StatusForbidden},
+		{"header refused", "https://app.test", "POST", "x-evil", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodOptions, "/rpc/", nil)
+			r.Header.Set("Origin", tt.origin)
+			r.Header.Set("Access-Control-Request-Method", tt.method)
+			if tt.headers != "" {
+				r.Header.Set("Access-Control-Request-Headers", tt.headers)
+			}
+			if !isPreflight(r) {
+				t.Fatal("not detected as a preflight")
+			}
+			w := httptest.NewRecorder()
+			ok := handlePreflight(w, r, &c.CORS)
+			if w.Code != tt.want || ok != (tt.want == http.StatusNoContent) {
+				t.Fatalf("status = %d (ok %v), want %d", w.Code, ok, tt.want)
+			}
+			if ok {
+				h := w.Header()
+				if h.Get("Access-Control-Allow-Origin") != tt.origin || h.Get("Access-Control-Allow-Credentials") != "true" ||
+					h.Get("Access-Control-Max-Age") != "600" {
+					t.Errorf("preflight headers = %v", h)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/http"
+	"strings"
+
+	applog "github.com/vNodesV/vProx/internal/logging"
+)
+
+// --------------------- ERROR RESPONSES ---------------------
+
+// errorStyle is the body format of an error generated by vProx itself.
+type errorStyle int
+
+const (
+	errText    errorStyle = iota // text/plain
+	errHTML                      // small HTML page (browsers)
+	errJSONRPC                   // JSON-RPC 2.0 error object (CometBFT RPC clients)
+	errCosmos                    // grpc-gateway {code,message,details} (Cosmos REST clients)
+)
+
+// errorStyleFor picks the format the client of r can parse: JSON-RPC on RPC
+// POSTs and URI calls, Cosmos JSON on REST/API routes and for JSON clients,
+// HTML for browsers, else text.
+func errorStyleFor(r *http.Request) errorStyle {
+	host := normalizeHost(r.Host)
+	var isRPC, isREST bool
+	if chain, ok := currentRoutes().chains[host]; ok {
+		isRPC, isREST = vhostRoute(chain, host)
+	}
+	path := r.URL.Path
+	isRPC = isRPC || hasRoutePrefix(path, rpcPrefix)
+	isREST = isREST || hasRoutePrefix(path, restPrefix) || hasRoutePrefix(path, apiPrefix)
+
+	accept := strings.ToLower(r.Header.Get("Accept"))
+	browser := strings.Contains(accept, "text/html")
+	switch {
+	case isRPC && r.Method == http.MethodPost:
+		return errJSONRPC
+	case isRPC && !browser && strings.Trim(strings.TrimPrefix(path, rpcPrefix), "/") != "":
+		return errJSONRPC
+	case isREST && !browser, strings.Contains(accept, "application/json"):
+		return errCosmos
+	case browser:
+		return errHTML
+	}
+	return errText
+}
+
+// writeError renders an error generated by vProx (not relayed from a
+// backend) in the format the client expects. The request ID is set as
+// X-Request-ID and repeated in the body.
+func writeError(w http.ResponseWriter, r *http.Request, status int, msg string) {
+	renderError(w, r, status, msg, rpcCalls{})
+}
+
+// renderError is writeError with the parsed JSON-RPC calls of r, so JSON-RPC
+// errors echo the request ids (one error per call for batches).
+func renderError(w http.ResponseWriter, r *http.Request, status int, msg string, calls rpcCalls) {
+	id := applog.EnsureRequestID(r)
+	applog.SetResponseRequestID(w, id)
+	h := w.Header()
+	h.Del("Content-Length")
+	h.Del("Content-Encoding")
+	h.Set("X-Content-Type-Options", "nosniff")
+
+	switch errorStyleFor(r) {
+	case errJSONRPC:
+		code := jsonrpcCodeFor(status) // writeRPCError adds the request ID as data
+		if len(calls.Calls) == 0 {
+			rid := json.RawMessage(nil)
+			if calls.URI || r.Method != http.MethodPost {
+				rid = json.RawMessage("-1") // CometBFT's id for URI calls
+			}
+			writeRPCError(w, r, status, newRPCError(rid, code, msg, ""))
+			return
+		}
+		out := make([]rpcError, 0, len(calls.Calls))
+		for _, c := range calls.Calls {
+			out = append(out, newRPCError(c.ID, code, msg, ""))
+		}
+		if calls.Batch {
+			writeRPCError(w, r, status, out)
+		} else {
+			writeRPCError(w, r, status, out[0])
+		}
+
+	case errCosmos:
+		body := cosmosError{
+			Code:    cosmosCodeFor(status),
+			Message: msg,
+			Details: []cosmosErrorDetail{{Type: "type.googleapis.com/google.rpc.RequestInfo", RequestID: id}},
+		}
+		h.Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_ = json.NewEncoder(w).Encode(body)
+
+	case errHTML:
+		h.Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(status)
+		fmt.Fprintf(w, "<!DOCTYPE html>\n<html><head><title>%d %s</title></head><body>\n<h1>%d %s</h1>\n<p>%s</p>\n<p><small>Request ID: <code>%s</code></small></p>\n</body></html>\n",
+			status, http.StatusText(status), status, http.StatusText(status), html.EscapeString(msg), html.EscapeString(id))
+
+	default:
+		h.Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(status)
+		fmt.Fprintf(w, "%s\nrequest_id: %s\n", msg, id)
+	}
+}
+
+// cosmosError is the grpc-gateway error body served by Cosmos SDK REST.
+type cosmosError struct {
+	Code    int                 `json:"code"`
+	Message string              `json:"message"`
+	Details []cosmosErrorDetail `json:"details"`
+}
+
+type cosmosErrorDetail struct {
+	Type      string `json:"@type"`
+	RequestID string `json:"request_id"`
+}
+
+// cosmosCodeFor maps an HTTP status to the gRPC code grpc-gateway would have
+// answered it with.
+func cosmosCodeFor(status int) int {
+	switch status {
+	case http.StatusBadRequest:
+		return 3 // INVALID_ARGUMENT
+	case http.StatusUnauthorized:
+		return 16 // UNAUTHENTICATED
+	case http.StatusForbidden:
+		return 7 // PERMISSION_DENIED
+	case http.StatusNotFound:
+		return 5 // NOT_FOUND
+	case http.StatusTooManyRequests, http.StatusRequestEntityTooLarge:
+		return 8 // RESOURCE_EXHAUSTED
+	case http.StatusNotImplemented:
+		return grpcCodeUnimplemented
+	case http.StatusBadGateway, http.StatusServiceUnavailable:
+		return grpcCodeUnavailable
+	case http.StatusGatewayTimeout:
+		return 4 // DEADLINE_EXCEEDED
+	case http.StatusInternalServerError:
+		return grpcCodeInternal
+	}
+	return grpcCodeUnknown
+}
+
+// jsonrpcCodeFor maps an HTTP status to a JSON-RPC error code.
+func jsonrpcCodeFor(status int) int {
+	switch status {
+	case http.StatusBadRequest:
+		return jsonrpcInvalidRequest
+	case http.StatusNotFound:
+		return jsonrpcMethodNotFound
+	}
+	return jsonrpcServerError
+}
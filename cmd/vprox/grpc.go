@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	applog "github.com/vNodesV/vProx/internal/logging"
+)
+
+// --------------------- NATIVE gRPC (HTTP/2) ---------------------
+
+// gRPC status codes used by the proxy itself (google.golang.org/grpc/codes).
+const (
+	grpcCodeUnimplemented = 12
+	grpcCodeUnavailable   = 14
+)
+
+// serverProtocols returns the protocol set for vProx listeners: HTTP/1.1 plus
+// HTTP/2 over cleartext (prior knowledge), so gRPC clients can talk to vProx
+// directly without TLS.
+func serverProtocols() *http.Protocols {
+	var protos http.Protocols
+	protos.SetHTTP1(true)
+	protos.SetUnencryptedHTTP2(true)
+	return &protos
+}
+
+// isGRPCRequest reports whether r is a native gRPC call (not gRPC-Web, which
+// is plain HTTP/1.1-compatible and keeps using the regular proxy path).
+func isGRPCRequest(r *http.Request) bool {
+	if r.ProtoMajor != 2 {
+		return false
+	}
+	ct := strings.ToLower(r.Header.Get("Content-Type"))
+	return strings.HasPrefix(ct, "application/grpc") && !strings.HasPrefix(ct, "application/grpc-web")
+}
+
+// grpcHandler proxies a native gRPC call to the chain's gRPC port with full-
+// duplex streaming and trailer forwarding. The method path is forwarded as-is;
+// a leading /grpc prefix (path-based exposure) is stripped.
+func grpcHandler(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	requestID := applog.EnsureRequestID(r)
+	applog.SetResponseRequestID(w, requestID)
+	host := normalizeHost(r.Host)
+
+	chain, ok := currentRoutes().chains[host]
+	if !ok {
+		writeGRPCError(w, grpcCodeUnavailable, "unknown host")
+		logRequestSummary(r, false, "grpc", host, start)
+		return
+	}
+	if !chain.Services.GRPC {
+		writeGRPCError(w, grpcCodeUnimplemented, "grpc service disabled")
+		logRequestSummary(r, false, "grpc", host, start)
+		return
+	}
+	// Old heights (x-cosmos-block-height metadata) go to the archive group.
+	height := parseHeight(r.Header.Get("x-cosmos-block-height"))
+	pool := chain.pool.forHeight(chain.Archive, height)
+	node := pool.admit(pool.next(), nil)
+	if node == nil {
+		writeGRPCError(w, grpcCodeUnavailable, "no backend available")
+		logRequestSummary(r, false, "grpc", host, start)
+		return
+	}
+	node.acquire()
+	defer node.release()
+	if pool != chain.pool {
+		addLogNote(r, applog.F("archive", height))
+	}
+
+	// Backend gRPC port speaks h2c (Cosmos SDK 9090). Streams have no total
+	// timeout unless [timeouts.grpc] total_sec sets one.
+	up := node.upstream(svcGRPC)
+	setRouteDeadlines(w, up.timeouts.total)
+	if up.timeouts.total > 0 {
+		ctx, cancel := context.WithTimeout(r.Context(), up.timeouts.total)
+		defer cancel()
+		r = r.WithContext(ctx)
+	}
+
+	path := r.URL.Path
+	if p := strings.TrimPrefix(path, grpcPrefix); p != path && strings.HasPrefix(p, "/") {
+		path = p
+	}
+	target := &url.URL{Scheme: "http", Host: node.addr(node.ports.GRPC), Path: path}
+
+	failed := false
+	rp := &httputil.ReverseProxy{
+		Transport:     up.rt,
+		FlushInterval: -1,
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.Out.URL = target
+			pr.Out.Host = target.Host
+			pr.Out.Header.Set(applog.RequestIDHeader, requestID)
+			pr.Out.Header.Set("X-Forwarded-Host", host)
+			if pr.In.Header.Get("X-Forwarded-For") == "" {
+				pr.Out.Header.Set("X-Forwarded-For", clientIP(pr.In))
+			}
+		},
+		ModifyResponse: func(resp *http.Response) error {
+			node.observe(r, resp, nil)
+			return nil
+		},
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			failed = true
+			node.observe(r, nil, err)
+			writeGRPCError(w, grpcCodeUnavailable, "backend error")
+		},
+	}
+	rp.ServeHTTP(w, r)
+	logRequestSummary(r, !failed, "grpc", host, start)
+}
+
+// writeGRPCError sends a gRPC "Trailers-Only" error response: HTTP 200 with
+// grpc-status / grpc-message in the headers and no body.
+func writeGRPCError(w http.ResponseWriter, code int, msg string) {
+	h := w.Header()
+	h.Set("Content-Type", "application/grpc")
+	h.Set("Grpc-Status", strconv.Itoa(code))
+	h.Set("Grpc-Message", grpcPercentEncode(msg))
+	w.WriteHeader(http.StatusOK)
+}
+
+// grpcPercentEncode encodes a grpc-message value: printable ASCII except '%'
+// is sent as-is, everything else as %XX (per the gRPC HTTP/2 spec).
+func grpcPercentEncode(msg string) string {
+	const hex = "0123456789ABCDEF"
+	var b strings.Builder
+	for i := 0; i < len(msg); i++ {
+		c := msg[i]
+		if c >= 0x20 && c <= 0x7e && c != '%' {
+			b.WriteByte(c)
+			continue
+		}
+		b.WriteByte('%')
+		b.WriteByte(hex[c>>4])
+		b.WriteByte(hex[c&0x0f])
+	}
+	return b.String()
+}
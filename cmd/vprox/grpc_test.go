@@ -0,0 +1,99 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// useRoutes loads ports.toml plus one chain config from a temporary config
+// directory and installs the table for the test.
+func useRoutes(t *testing.T, chainTOML string) *routeTable {
+	t.Helper()
+	dir := t.TempDir()
+	prev := [...]string{configDir, chainsConfigDir, chainsDir, logsDir}
+	t.Cleanup(func() {
+		configDir, chainsConfigDir, chainsDir, logsDir = prev[0], prev[1], prev[2], prev[3]
+		routes.Store(nil)
+	})
+	configDir, chainsConfigDir, chainsDir, logsDir = dir, filepath.Join(dir, "chains"), filepath.Join(dir, "none"), dir
+	if err := os.MkdirAll(chainsConfigDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "ports.toml"), []byte("rpc = 26657\nrest = 1317\ngrpc = 9090\napi = 1317\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(chainsConfigDir, "test.toml"), []byte(chainTOML), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	rt, err := loadRouteTable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	routes.Store(rt)
+	return rt
+}
+
+// TestGRPCStreamOutlivesServerTimeouts runs a stream through a listener
+// whose Read/WriteTimeout is shorter than the stream: grpcHandler must lift
+// them for the call.
+func TestGRPCStreamOutlivesServerTimeouts(t *testing.T) {
+	backend := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/grpc")
+		w.Header().Set("Trailer", "Grpc-Status")
+		w.WriteHeader(http.StatusOK)
+		for i := range 3 {
+			_, _ = w.Write([]byte{0, 0, 0, 0, 1, byte(i)})
+			w.(http.Flusher).Flush()
+			time.Sleep(150 * time.Millisecond)
+		}
+		w.Header().Set("Grpc-Status", "0")
+	}))
+	backend.Config.Protocols = new(http.Protocols)
+	backend.Config.Protocols.SetUnencryptedHTTP2(true)
+	backend.Start()
+	t.Cleanup(backend.Close)
+
+	useRoutes(t, `chain_name = "test"
+host = "test.example.com"
+default_ports = true
+[services]
+grpc = true
+[[backends]]
+name = "a"
+ip = "127.0.0.1"
+[backends.ports]
+grpc = `+backend.URL[strings.LastIndex(backend.URL, ":")+1:]+`
+`)
+	front := httptest.NewUnstartedServer(http.HandlerFunc(handler))
+	front.Config.Protocols = serverProtocols()
+	front.Config.ReadTimeout = 100 * time.Millisecond
+	front.Config.WriteTimeout = 100 * time.Millisecond
+	front.Start()
+	t.Cleanup(front.Close)
+
+	tr := &http.Transport{Protocols: new(http.Protocols)}
+	tr.Protocols.SetUnencryptedHTTP2(true)
+	t.Cleanup(tr.CloseIdleConnections)
+	req, _ := http.NewRequest("POST", front.URL+"/pkg.Svc/Stream", strings.NewReader(""))
+	req.Host = "test.example.com"
+	req.Header.Set("Content-Type", "application/grpc")
+	req.Header.Set("Te", "trailers")
+	resp, err := tr.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("stream cut after %d bytes: %v", len(body), err)
+	}
+	if len(body) != 18 || resp.Trailer.Get("Grpc-Status") != "0" {
+		t.Errorf("body = %x, trailer = %v", body, resp.Trailer)
+	}
+}
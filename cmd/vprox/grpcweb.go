@@ -0,0 +1,265 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// --------------------- gRPC-Web → NATIVE gRPC ---------------------
+
+const (
+	grpcWebContentType     = "application/grpc-web"
+	grpcWebTextContentType = "application/grpc-web-text"
+
+	// maxGRPCWebRequest bounds the buffered request body (gRPC-Web has no
+	// client streaming, so the whole request is one or a few messages).
+	maxGRPCWebRequest = 8 << 20
+
+	grpcCodeUnknown  = 2
+	grpcCodeInternal = 13
+)
+
+// grpcWebHopHeaders are never forwarded between the gRPC-Web and gRPC legs.
+var grpcWebHopHeaders = map[string]bool{
+	"connection":        true,
+	"keep-alive":        true,
+	"proxy-connection":  true,
+	"transfer-encoding": true,
+	"upgrade":           true,
+	"te":                true,
+	"trailer":           true,
+	"content-length":    true,
+	"content-type":      true,
+	"accept-encoding":   true,
+	"x-grpc-web":        true,
+}
+
+// isGRPCWebRequest reports whether r carries a gRPC-Web payload.
+func isGRPCWebRequest(r *http.Request) bool {
+	return strings.HasPrefix(strings.ToLower(r.Header.Get("Content-Type")), grpcWebContentType)
+}
+
+// grpcWebTranslate serves a gRPC-Web call (binary or base64 "-text") by
+// issuing the equivalent native gRPC call to the node's gRPC port over h2c and
+// re-framing the response: data frames are passed through and the HTTP/2
+// trailers are appended as a gRPC-Web trailer frame (flag 0x80).
+func grpcWebTranslate(w http.ResponseWriter, r *http.Request, node *backendNode, path string) error {
+	ct := strings.ToLower(r.Header.Get("Content-Type"))
+	isText := strings.HasPrefix(ct, grpcWebTextContentType)
+	respCT := grpcWebContentType + "+proto"
+	if isText {
+		respCT = grpcWebTextContentType + "+proto"
+	}
+
+	if r.Method != http.MethodPost {
+		writeGRPCWebError(w, respCT, grpcCodeUnimplemented, "grpc-web requires POST")
+		return nil
+	}
+
+	// Same upstream (h2c transport, timeouts) as native gRPC; its deadline
+	// covers reading the request too.
+	up := node.upstream(svcGRPC)
+	setRouteDeadlines(w, up.timeouts.total)
+
+	raw, err := io.ReadAll(io.LimitReader(r.Body, maxGRPCWebRequest+1))
+	if err != nil {
+		writeGRPCWebError(w, respCT, grpcCodeInternal, "read request")
+		return err
+	}
+	if len(raw) > maxGRPCWebRequest {
+		writeGRPCWebError(w, respCT, grpcCodeInternal, "request too large")
+		return nil
+	}
+	if isText {
+		if raw, err = decodeGRPCWebText(raw); err != nil {
+			writeGRPCWebError(w, respCT, grpcCodeInternal, "invalid grpc-web-text body")
+			return nil
+		}
+	}
+
+	ctx := r.Context()
+	if up.timeouts.total > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, up.timeouts.total)
+		defer cancel()
+	}
+	rc := http.NewResponseController(w)
+
+	target := &url.URL{Scheme: "http", Host: node.addr(node.ports.GRPC), Path: path}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target.String(), bytes.NewReader(raw))
+	if err != nil {
+		writeGRPCWebError(w, respCT, grpcCodeInternal, "request build error")
+		return err
+	}
+	for k, vv := range r.Header {
+		if grpcWebHopHeaders[strings.ToLower(k)] {
+			continue
+		}
+		for _, v := range vv {
+			req.Header.Add(k, v)
+		}
+	}
+	// application/grpc-web(-text)[+proto] → application/grpc[+proto]
+	upCT := "application/grpc"
+	if i := strings.IndexByte(ct, '+'); i >= 0 {
+		upCT += ct[i:]
+	}
+	req.Header.Set("Content-Type", upCT)
+	req.Header.Set("Te", "trailers")
+	if ua := r.Header.Get("X-User-Agent"); ua != "" {
+		req.Header.Set("User-Agent", ua)
+	}
+	req.ContentLength = int64(len(raw))
+
+	if !node.breaker.Allow() {
+		writeGRPCWebError(w, respCT, grpcCodeUnavailable, "backend unavailable (circuit open)")
+		return errors.New("circuit open")
+	}
+	resp, err := up.rt.RoundTrip(req)
+	node.observe(r, resp, err)
+	if err != nil {
+		writeGRPCWebError(w, respCT, grpcCodeUnavailable, "backend error")
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		writeGRPCWebError(w, respCT, grpcCodeFromHTTP(resp.StatusCode), "backend returned HTTP "+strconv.Itoa(resp.StatusCode))
+		return nil
+	}
+
+	// Headers (incl. grpc-status on Trailers-Only responses) pass through.
+	for k, vv := range resp.Header {
+		if grpcWebHopHeaders[strings.ToLower(k)] {
+			continue
+		}
+		for _, v := range vv {
+			w.Header().Add(k, v)
+		}
+	}
+	w.Header().Set("Content-Type", respCT)
+	w.WriteHeader(http.StatusOK)
+
+	var out io.Writer = w
+	var enc io.WriteCloser
+	if isText {
+		enc = base64.NewEncoder(base64.StdEncoding, w)
+		out = enc
+	}
+
+	// Pass data frames through as they arrive so server streaming works.
+	buf := make([]byte, 32<<10)
+	for {
+		n, rerr := resp.Body.Read(buf)
+		if n > 0 {
+			if _, werr := out.Write(buf[:n]); werr != nil {
+				return werr
+			}
+			_ = rc.Flush()
+		}
+		if rerr == io.EOF {
+			break
+		}
+		if rerr != nil {
+			// Stream broke mid-way: terminate with a trailer frame.
+			resp.Trailer = http.Header{"Grpc-Status": {strconv.Itoa(grpcCodeUnavailable)}, "Grpc-Message": {"backend stream error"}}
+			err = rerr
+			break
+		}
+	}
+
+	if len(resp.Trailer) > 0 {
+		if _, werr := out.Write(grpcWebTrailerFrame(resp.Trailer)); werr != nil {
+			return werr
+		}
+	}
+	if enc != nil {
+		_ = enc.Close()
+	}
+	_ = rc.Flush()
+	return err
+}
+
+// decodeGRPCWebText decodes a grpc-web-text body. Clients may send several
+// independently padded base64 chunks back to back, so decode per 4-char group.
+func decodeGRPCWebText(b []byte) ([]byte, error) {
+	b = bytes.Map(func(r rune) rune {
+		if r == '\r' || r == '\n' || r == ' ' || r == '\t' {
+			return -1
+		}
+		return r
+	}, b)
+	if len(b)%4 != 0 {
+		return nil, errors.New("base64 length not a multiple of 4")
+	}
+	out := make([]byte, 0, len(b)/4*3)
+	var tmp [3]byte
+	for i := 0; i < len(b); i += 4 {
+		n, err := base64.StdEncoding.Decode(tmp[:], b[i:i+4])
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, tmp[:n]...)
+	}
+	return out, nil
+}
+
+// grpcWebTrailerFrame renders trailers as a gRPC-Web trailer frame: flag 0x80,
+// 4-byte big-endian length, then "key: value\r\n" lines with lowercase keys.
+func grpcWebTrailerFrame(tr http.Header) []byte {
+	keys := make([]string, 0, len(tr))
+	for k := range tr {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var payload bytes.Buffer
+	for _, k := range keys {
+		for _, v := range tr[k] {
+			payload.WriteString(strings.ToLower(k))
+			payload.WriteString(": ")
+			payload.WriteString(v)
+			payload.WriteString("\r\n")
+		}
+	}
+	frame := make([]byte, 5, 5+payload.Len())
+	frame[0] = 0x80
+	binary.BigEndian.PutUint32(frame[1:], uint32(payload.Len()))
+	return append(frame, payload.Bytes()...)
+}
+
+// writeGRPCWebError sends a Trailers-Only gRPC-Web error (status in headers).
+func writeGRPCWebError(w http.ResponseWriter, contentType string, code int, msg string) {
+	h := w.Header()
+	h.Set("Content-Type", contentType)
+	h.Set("Grpc-Status", strconv.Itoa(code))
+	h.Set("Grpc-Message", grpcPercentEncode(msg))
+	w.WriteHeader(http.StatusOK)
+}
+
+// grpcCodeFromHTTP maps a non-200 HTTP status from the backend to a gRPC code
+// (same table as grpc-go's HTTP fallback).
+func grpcCodeFromHTTP(status int) int {
+	switch status {
+	case http.StatusBadRequest:
+		return grpcCodeInternal
+	case http.StatusUnauthorized:
+		return 16 // UNAUTHENTICATED
+	case http.StatusForbidden:
+		return 7 // PERMISSION_DENIED
+	case http.StatusNotFound:
+		return grpcCodeUnimplemented
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return grpcCodeUnavailable
+	default:
+		return grpcCodeUnknown
+	}
+}
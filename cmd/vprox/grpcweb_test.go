@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestDecodeGRPCWebText(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		want    string // hex
+		wantErr bool
+	}{
+		{"empty", "", "", false},
+		{"one chunk", base64.StdEncoding.EncodeToString([]byte{0, 0, 0, 0, 2, 8, 1}), "00000000020801", false},
+		{"padded chunks back to back", "AA==" + "AAE=" + "AAEC", "00" + "0001" + "000102", false},
+		{"line breaks and spaces", "AAAA\r\nAA\tAA AA==\n", "000000" + "000000" + "00", false},
+		{"bad length", "AAA", "", true},
+		{"bad alphabet", "AA*A", "", true},
+		{"padding mid-group", "A=AA", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeGRPCWebText([]byte(tt.in))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if err == nil && hex.EncodeToString(got) != tt.want {
+				t.Errorf("got %x, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGRPCWebTrailerFrame(t *testing.T) {
+	tests := []struct {
+		name    string
+		trailer http.Header
+		payload string
+	}{
+		{"empty", http.Header{}, ""},
+		{"status", http.Header{"Grpc-Status": {"0"}}, "grpc-status: 0\r\n"},
+		{"sorted and lowercased", http.Header{"Grpc-Status": {"5"}, "Grpc-Message": {"not found"}, "X-Extra": {"a", "b"}},
+			"grpc-message: not found\r\ngrpc-status: 5\r\nx-extra: a\r\nx-extra: b\r\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := grpcWebTrailerFrame(tt.trailer)
+			if len(f) < 5 || f[0] != 0x80 {
+				t.Fatalf("frame header = %x", f)
+			}
+			if n := int(f[1])<<24 | int(f[2])<<16 | int(f[3])<<8 | int(f[4]); n != len(tt.payload) {
+				t.Errorf("length = %d, want %d", n, len(tt.payload))
+			}
+			if got := string(f[5:]); got != tt.payload {
+				t.Errorf("payload = %q, want %q", got, tt.payload)
+			}
+		})
+	}
+}
+
+func TestGRPCCodeFromHTTP(t *testing.T) {
+	tests := []struct{ status, want int }{
+		{http.StatusBadRequest, grpcCodeInternal},
+		{http.StatusUnauthorized, 16},
+		{http.StatusForbidden, 7},
+		{http.StatusNotFound, grpcCodeUnimplemented},
+		{http.StatusTooManyRequests, grpcCodeUnavailable},
+		{http.StatusBadGateway, grpcCodeUnavailable},
+		{http.StatusServiceUnavailable, grpcCodeUnavailable},
+		{http.StatusGatewayTimeout, grpcCodeUnavailable},
+		{http.StatusInternalServerError, grpcCodeUnknown},
+		{http.StatusTeapot, grpcCodeUnknown},
+	}
+	for _, tt := range tests {
+		if got := grpcCodeFromHTTP(tt.status); got != tt.want {
+			t.Errorf("grpcCodeFromHTTP(%d) = %d, want %d", tt.status, got, tt.want)
+		}
+	}
+}
+
+func TestGRPCPercentEncode(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"not found", "not found"},
+		{"100%", "100%25"},
+		{"line\nbreak", "line%0Abreak"},
+		{"héllo", "h%C3%A9llo"},
+	}
+	for _, tt := range tests {
+		if got := grpcPercentEncode(tt.in); got != tt.want {
+			t.Errorf("grpcPercentEncode(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+// grpcEchoNode starts an h2c gRPC backend that echoes the request frames and
+// ends with the given trailers, and returns a node pointing at it.
+func grpcEchoNode(t *testing.T, trailer http.Header) *backendNode {
+	t.Helper()
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != "application/grpc+proto" || r.Header.Get("Te") != "trailers" {
+			w.WriteHeader(http.StatusUnsupportedMediaType)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		for k := range trailer {
+			w.Header().Add("Trailer", k)
+		}
+		w.Header().Set("Content-Type", "application/grpc")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write(body)
+		for k, vv := range trailer {
+			w.Header()[k] = vv
+		}
+	}))
+	srv.Config.Protocols = new(http.Protocols)
+	srv.Config.Protocols.SetUnencryptedHTTP2(true)
+	srv.Start()
+	t.Cleanup(srv.Close)
+
+	host, port, _ := net.SplitHostPort(srv.Listener.Addr().String())
+	p, _ := strconv.Atoi(port)
+	tr := newUpstreamTransport(upstreamTimeouts{connect: time.Second, header: 5 * time.Second, idle: time.Second}, true)
+	t.Cleanup(tr.CloseIdleConnections)
+	return &backendNode{
+		ip:        host,
+		ports:     Ports{GRPC: p},
+		upstreams: map[string]*upstream{svcGRPC: {rt: tr}},
+	}
+}
+
+func TestGRPCWebTranslateFraming(t *testing.T) {
+	msg := []byte{0, 0, 0, 0, 3, 0x08, 0x96, 0x01} // one data frame
+	trailer := http.Header{"Grpc-Status": {"0"}, "Grpc-Message": {"ok"}}
+	wantBinary := append(append([]byte{}, msg...), grpcWebTrailerFrame(trailer)...)
+
+	tests := []struct {
+		name      string
+		ct        string
+		body      []byte
+		wantCT    string
+		wantBody  []byte
+		wantGrpc  string // Grpc-Status header of a Trailers-Only error
+		reqMethod string
+	}{
+		{"binary", "application/grpc-web+proto", msg, "application/grpc-web+proto", wantBinary, "", "POST"},
+		{"text", "application/grpc-web-text+proto", []byte(base64.StdEncoding.EncodeToString(msg)),
+			"application/grpc-web-text+proto", []byte(base64.StdEncoding.EncodeToString(wantBinary)), "", "POST"},
+		{"bad text", "application/grpc-web-text", []byte("A*=="), "application/grpc-web-text+proto", nil, strconv.Itoa(grpcCodeInternal), "POST"},
+		{"not post", "application/grpc-web", nil, "application/grpc-web+proto", nil, strconv.Itoa(grpcCodeUnimplemented), "GET"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := grpcEchoNode(t, trailer)
+			r := httptest.NewRequest(tt.reqMethod, "/cosmos.bank.v1beta1.Query/Balance", bytes.NewReader(tt.body))
+			r.Header.Set("Content-Type", tt.ct)
+			w := httptest.NewRecorder()
+			if err := grpcWebTranslate(w, r, node, r.URL.Path); err != nil {
+				t.Fatalf("grpcWebTranslate: %v", err)
+			}
+			res := w.Result()
+			if got := res.Header.Get("Content-Type"); got != tt.wantCT {
+				t.Errorf("Content-Type = %q, want %q", got, tt.wantCT)
+			}
+			if got := res.Header.Get("Grpc-Status"); got != tt.wantGrpc {
+				t.Errorf("Grpc-Status header = %q, want %q", got, tt.wantGrpc)
+			}
+			if got := w.Body.Bytes(); !bytes.Equal(got, tt.wantBody) {
+				t.Errorf("body = %q, want %q", got, tt.wantBody)
+			}
+		})
+	}
+}
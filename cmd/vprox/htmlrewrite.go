@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// --------------------- STREAMING HTML REWRITE ---------------------
+
+const (
+	// htmlChunkSize is the read size of the rewrite pipeline.
+	htmlChunkSize = 32 << 10
+	// htmlMaxPending bounds the carry-over buffer when no safe cut point is
+	// found (e.g. a long minified <script> body with no whitespace).
+	htmlMaxPending = 64 << 10
+)
+
+// htmlRewriter is a bounded-memory io.WriteCloser that applies rewriteLinks
+// and the one-shot banner injection to a streamed HTML body.
+//
+// Every pattern it looks for (scheme-relative node URLs, href="/..., src="/...,
+// <body>) is free of whitespace and only "<body>" contains '<', at its start.
+// So input is processed up to the last '<' or whitespace byte and the rest is
+// carried into the next chunk; no match can straddle a cut.
+type htmlRewriter struct {
+	dst     io.Writer
+	rewrite func(string) string
+	banner  string // injected after the first <body>; "" = done/none
+	pending []byte
+}
+
+func newHTMLRewriter(dst io.Writer, rewrite func(string) string, banner string) *htmlRewriter {
+	return &htmlRewriter{dst: dst, rewrite: rewrite, banner: banner}
+}
+
+func (h *htmlRewriter) Write(p []byte) (int, error) {
+	h.pending = append(h.pending, p...)
+	cut := safeCut(h.pending)
+	if cut <= 0 && len(h.pending) > htmlMaxPending {
+		cut = len(h.pending)
+	}
+	if cut > 0 {
+		if err := h.emit(h.pending[:cut]); err != nil {
+			return 0, err
+		}
+		h.pending = append(h.pending[:0], h.pending[cut:]...)
+	}
+	return len(p), nil
+}
+
+// Close flushes the carried-over tail. It does not close dst.
+func (h *htmlRewriter) Close() error {
+	if len(h.pending) == 0 {
+		return nil
+	}
+	err := h.emit(h.pending)
+	h.pending = h.pending[:0]
+	return err
+}
+
+func (h *htmlRewriter) emit(b []byte) error {
+	s := h.rewrite(string(b))
+	if h.banner != "" && strings.Contains(s, "<body>") {
+		s = injectBannerFromString(s, h.banner)
+		h.banner = ""
+	}
+	_, err := io.WriteString(h.dst, s)
+	return err
+}
+
+// safeCut returns the index of the last '<' or ASCII whitespace in b (the
+// carried tail starts there), or -1.
+func safeCut(b []byte) int {
+	return bytes.LastIndexAny(b, "< \t\r\n")
+}
+
+// bannerText resolves the banner: config message first, then the msg file.
+func bannerText(msg, path string) string {
+	if strings.TrimSpace(msg) != "" {
+		return msg
+	}
+	if path == "" {
+		return ""
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
+
+// acceptsGzip reports whether the client's Accept-Encoding allows gzip.
+func acceptsGzip(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		name, params, _ := strings.Cut(part, ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name != "gzip" && name != "*" {
+			continue
+		}
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if q, err := strconv.ParseFloat(v, 64); err == nil && q == 0 {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestSafeCut(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int
+	}{
+		{"", -1},
+		{"abcdef", -1},
+		{"a b", 1},
+		{"<a href", 2},
+		{"x<body", 1},
+		{"a\tb\nc\rd", 5},
+		{"text <", 5},
+	}
+	for _, tt := range tests {
+		if got := safeCut([]byte(tt.in)); got != tt.want {
+			t.Errorf("safeCut(%q) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
+
+func rewriteTestLinks(s string) string {
+	s = strings.ReplaceAll(s, `href="/`, `href="/rpc/`)
+	return strings.ReplaceAll(s, "//10.0.0.1:26657/", "//rpc.example.com/")
+}
+
+// TestHTMLRewriterChunking feeds the same page in every chunk size and
+// expects the output of a one-shot rewrite.
+func TestHTMLRewriterChunking(t *testing.T) {
+	page := `<html><head><link href="/style.css"></head><body>` +
+		`<a href="/status">status</a> <a href="//10.0.0.1:26657/net_info">peers</a>` +
+		"\n<p>again <body> and href=\"/x\"</p></body></html>"
+	want := injectBannerFromString(rewriteTestLinks(page), "HELLO")
+
+	for size := 1; size <= len(page); size++ {
+		var out bytes.Buffer
+		h := newHTMLRewriter(&out, rewriteTestLinks, "HELLO")
+		for i := 0; i < len(page); i += size {
+			if n, err := h.Write([]byte(page[i:min(i+size, len(page))])); err != nil || n != min(size, len(page)-i) {
+				t.Fatalf("size %d: Write = %d, %v", size, n, err)
+			}
+		}
+		if err := h.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if out.String() != want {
+			t.Fatalf("size %d:\n got %q\nwant %q", size, out.String(), want)
+		}
+	}
+}
+
+func TestHTMLRewriterPendingCap(t *testing.T) {
+	var out bytes.Buffer
+	h := newHTMLRewriter(&out, rewriteTestLinks, "")
+	blob := bytes.Repeat([]byte("x"), htmlChunkSize)
+
+	// No cut point: the tail is carried until it passes the cap.
+	for written := 0; written <= htmlMaxPending; written += len(blob) {
+		if out.Len() != 0 {
+			t.Fatalf("flushed after %d bytes, before the %d byte cap", written, htmlMaxPending)
+		}
+		if _, err := h.Write(blob); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(h.pending) != 0 || out.Len() != htmlMaxPending+htmlChunkSize {
+		t.Fatalf("pending = %d, written = %d after passing the cap", len(h.pending), out.Len())
+	}
+
+	// With a cut point only the tail after it is carried.
+	if _, err := h.Write([]byte("abc def")); err != nil {
+		t.Fatal(err)
+	}
+	if string(h.pending) != " def" {
+		t.Errorf("pending = %q, want %q", h.pending, " def")
+	}
+	if err := h.Close(); err != nil || !strings.HasSuffix(out.String(), "abc def") {
+		t.Errorf("Close did not flush the tail: %v", err)
+	}
+}
@@ -0,0 +1,411 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	applog "github.com/vNodesV/vProx/internal/logging"
+)
+
+// --------------------- JSON-RPC INSPECTION & POLICY ---------------------
+
+const (
+	// maxJSONRPCBody bounds the POST body buffered for inspection.
+	maxJSONRPCBody = 8 << 20
+
+	jsonrpcParseError     = -32700
+	jsonrpcInvalidRequest = -32600
+	jsonrpcMethodNotFound = -32601
+	jsonrpcServerError    = -32000
+)
+
+// RPCPolicy restricts which CometBFT JSON-RPC methods a chain exposes and
+// how they are charged ([rpc_policy]). Method entries are exact names or
+// prefixes ending in "*" (e.g. "unsafe_*"). Deny wins over allow; an empty
+// allow list allows all.
+type RPCPolicy struct {
+	Allow []string `toml:"allow"`
+	Deny  []string `toml:"deny"`
+
+	MaxBatchSize int            `toml:"max_batch_size"` // 0 = unlimited
+	Weights      map[string]int `toml:"weights"`        // method or prefix* -> limiter tokens (default 1)
+}
+
+func (p RPCPolicy) active() bool {
+	return len(p.Allow) > 0 || len(p.Deny) > 0
+}
+
+// permits reports whether method may be called.
+func (p RPCPolicy) permits(method string) bool {
+	if matchMethod(p.Deny, method) {
+		return false
+	}
+	return len(p.Allow) == 0 || matchMethod(p.Allow, method)
+}
+
+// weightOf returns the limiter cost of one call: an exact weight first, then
+// the longest matching prefix* entry, else 1.
+func (p RPCPolicy) weightOf(method string) int {
+	if w, ok := p.Weights[method]; ok {
+		return w
+	}
+	best, bestLen := 1, -1
+	for pat, w := range p.Weights {
+		if pre, ok := strings.CutSuffix(pat, "*"); ok && strings.HasPrefix(method, pre) && len(pre) > bestLen {
+			best, bestLen = w, len(pre)
+		}
+	}
+	return best
+}
+
+func matchMethod(patterns []string, method string) bool {
+	for _, p := range patterns {
+		if pre, ok := strings.CutSuffix(p, "*"); ok {
+			if strings.HasPrefix(method, pre) {
+				return true
+			}
+		} else if p == method {
+			return true
+		}
+	}
+	return false
+}
+
+func validateRPCPolicy(c *ChainConfig) error {
+	p := &c.RPCPolicy
+	for _, list := range []*[]string{&p.Allow, &p.Deny} {
+		for i, m := range *list {
+			m = strings.TrimSpace(m)
+			if m == "" || strings.Contains(strings.TrimSuffix(m, "*"), "*") {
+				return fmt.Errorf("rpc_policy: invalid method pattern %q", (*list)[i])
+			}
+			(*list)[i] = m
+		}
+	}
+	if p.active() && !c.Services.RPC {
+		return errors.New("rpc_policy requires services.rpc to be enabled")
+	}
+	if p.MaxBatchSize < 0 {
+		return errors.New("rpc_policy.max_batch_size must be >= 0")
+	}
+	for m, w := range p.Weights {
+		if w < 0 || strings.Contains(strings.TrimSuffix(m, "*"), "*") {
+			return fmt.Errorf("rpc_policy.weights: invalid entry %q = %d", m, w)
+		}
+	}
+	return nil
+}
+
+// rpcCall is one JSON-RPC call of a request.
+type rpcCall struct {
+	ID     json.RawMessage `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+}
+
+// rpcCalls describes the JSON-RPC calls carried by an RPC request, in either
+// URI form (GET /<method>?args) or POST body form (single object or batch).
+type rpcCalls struct {
+	Calls []rpcCall
+	Batch bool
+	URI   bool
+}
+
+// inspectRPC extracts the calls of an RPC-route request. upstreamPath is the
+// path sent to the node (route prefix already stripped). It follows CometBFT's
+// routing: /<method> is a URI call whatever the HTTP method (the node ignores
+// the body there), and a body sent to "/" is JSON-RPC whatever the method.
+// Such bodies are buffered and r.Body is replaced so the request can still be
+// forwarded; r.GetBody replays the buffer for retries.
+// The index ("/") without a body carries no call.
+func inspectRPC(r *http.Request, upstreamPath string) (rpcCalls, error) {
+	if m := strings.Trim(upstreamPath, "/"); m != "" {
+		if strings.Contains(m, "/") {
+			return rpcCalls{URI: true}, nil
+		}
+		return rpcCalls{URI: true, Calls: []rpcCall{{ID: json.RawMessage("-1"), Method: m}}}, nil
+	}
+	if r.Body == nil || r.Body == http.NoBody {
+		return rpcCalls{URI: true}, nil
+	}
+
+	body := r.Body
+	raw, err := io.ReadAll(io.LimitReader(body, maxJSONRPCBody+1))
+	if err != nil {
+		return rpcCalls{}, err
+	}
+	if len(raw) > maxJSONRPCBody {
+		// Too large to inspect: hand the rest of the stream on unchanged.
+		r.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(raw), body), body}
+		return rpcCalls{}, errors.New("request body too large")
+	}
+	_ = body.Close()
+	r.Body = io.NopCloser(bytes.NewReader(raw))
+	r.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(raw)), nil
+	}
+	if len(bytes.TrimSpace(raw)) == 0 && r.Method != http.MethodPost {
+		return rpcCalls{URI: true}, nil // the node lists its endpoints
+	}
+	return parseRPCBody(raw)
+}
+
+// parseRPCBody parses a JSON-RPC request body: a single call or a batch.
+func parseRPCBody(raw []byte) (rpcCalls, error) {
+	trimmed := bytes.TrimLeft(raw, " \t\r\n")
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		var calls []rpcCall
+		if err := json.Unmarshal(trimmed, &calls); err != nil {
+			return rpcCalls{}, err
+		}
+		if len(calls) == 0 {
+			return rpcCalls{}, errors.New("empty batch")
+		}
+		return rpcCalls{Calls: calls, Batch: true}, nil
+	}
+	var call rpcCall
+	if err := json.Unmarshal(trimmed, &call); err != nil {
+		return rpcCalls{}, err
+	}
+	return rpcCalls{Calls: []rpcCall{call}}, nil
+}
+
+// checkRPCRequest inspects an RPC-route request before it is proxied: method
+// policy, batch size, then one limiter charge per call (weighted), and notes
+// the per-method counts for the access line. It returns the parsed calls, and
+// false after writing the response when the request must not be forwarded.
+//
+// Bodies that do not parse are forwarded untouched (the node answers with its
+// own parse error) unless a method policy is configured.
+func checkRPCRequest(w http.ResponseWriter, r *http.Request, chain *ChainConfig, upstreamPath string) (rpcCalls, bool) {
+	p := chain.RPCPolicy
+	rc, err := inspectRPC(r, upstreamPath)
+	if err != nil {
+		if !p.active() {
+			return rpcCalls{}, true
+		}
+		writeRPCError(w, r, http.StatusBadRequest, newRPCError(nil, jsonrpcParseError, "Parse error", err.Error()))
+		return rpcCalls{}, false
+	}
+	if len(rc.Calls) == 0 {
+		return rc, true
+	}
+	addLogNote(r, rpcMethodNotes(rc)...)
+
+	if p.active() && !enforceRPCPolicy(w, r, p, rc) {
+		return rc, false
+	}
+
+	if rc.Batch && p.MaxBatchSize > 0 && len(rc.Calls) > p.MaxBatchSize {
+		if limiter != nil {
+			limiter.LogEvent(r, "rpc-batch-too-large", strconv.Itoa(len(rc.Calls)))
+		}
+		writeRPCError(w, r, http.StatusRequestEntityTooLarge, newRPCError(nil, jsonrpcInvalidRequest, "Batch too large",
+			fmt.Sprintf("batch of %d calls exceeds the limit of %d", len(rc.Calls), p.MaxBatchSize)))
+		return rc, false
+	}
+
+	// The limiter middleware already took one token for the request.
+	cost := 0
+	for _, c := range rc.Calls {
+		cost += p.weightOf(c.Method)
+	}
+	if limiter != nil && !limiter.Charge(r, cost-1) {
+		w.Header().Set("Retry-After", "1")
+		w.Header().Set("X-RateLimit-Status", "blocked")
+		if rc.Batch {
+			out := make([]rpcError, 0, len(rc.Calls))
+			for _, c := range rc.Calls {
+				out = append(out, newRPCError(c.ID, jsonrpcServerError, "Rate limit exceeded", ""))
+			}
+			writeRPCError(w, r, http.StatusTooManyRequests, out)
+		} else {
+			writeRPCError(w, r, http.StatusTooManyRequests, newRPCError(rc.Calls[0].ID, jsonrpcServerError, "Rate limit exceeded", ""))
+		}
+		return rc, false
+	}
+	return rc, true
+}
+
+// rpcMethodNotes renders "rpcCalls=N rpcMethods=block:3,status:1".
+func rpcMethodNotes(rc rpcCalls) []applog.Field {
+	counts := make(map[string]int, len(rc.Calls))
+	for _, c := range rc.Calls {
+		counts[logSafeMethod(c.Method)]++
+	}
+	names := make([]string, 0, len(counts))
+	for m := range counts {
+		names = append(names, m)
+	}
+	sort.Strings(names)
+	parts := make([]string, len(names))
+	for i, m := range names {
+		parts[i] = m + ":" + strconv.Itoa(counts[m])
+	}
+	return []applog.Field{
+		applog.F("rpcCalls", len(rc.Calls)),
+		applog.F("rpcMethods", strings.Join(parts, ",")),
+	}
+}
+
+// logSafeMethod keeps client-supplied method names log-safe: [A-Za-z0-9_.-]
+// only, at most 64 bytes.
+func logSafeMethod(m string) string {
+	if m == "" {
+		return "-"
+	}
+	if len(m) > 64 {
+		m = m[:64]
+	}
+	return strings.Map(func(r rune) rune {
+		if r == '_' || r == '.' || r == '-' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' {
+			return r
+		}
+		return '?'
+	}, m)
+}
+
+// rpcError is a JSON-RPC 2.0 error response.
+type rpcError struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Error   rpcErrorBody    `json:"error"`
+}
+
+type rpcErrorBody struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Data    string `json:"data,omitempty"`
+}
+
+func newRPCError(id json.RawMessage, code int, msg, data string) rpcError {
+	if len(id) == 0 {
+		id = json.RawMessage("null")
+	}
+	return rpcError{JSONRPC: "2.0", ID: id, Error: rpcErrorBody{Code: code, Message: msg, Data: data}}
+}
+
+// writeRPCError writes v (an rpcError or a batch of them) as JSON, with the
+// request ID of r in error.data like every other vProx error.
+func writeRPCError(w http.ResponseWriter, r *http.Request, status int, v any) {
+	v = withRequestID(v, applog.EnsureRequestID(r))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// withRequestID appends "request_id=<id>" to error.data of v (an rpcError or
+// a batch of them).
+func withRequestID(v any, id string) any {
+	stamp := func(e *rpcError) {
+		if e.Error.Data == "" {
+			e.Error.Data = "request_id=" + id
+		} else {
+			e.Error.Data += "; request_id=" + id
+		}
+	}
+	switch t := v.(type) {
+	case rpcError:
+		stamp(&t)
+		return t
+	case []rpcError:
+		for i := range t {
+			stamp(&t[i])
+		}
+	}
+	return v
+}
+
+// enforceRPCPolicy answers the request itself when any call is denied and
+// returns false.
+func enforceRPCPolicy(w http.ResponseWriter, r *http.Request, p RPCPolicy, rc rpcCalls) bool {
+	v, denied := p.reject(rc)
+	if v == nil {
+		return true
+	}
+	if limiter != nil {
+		limiter.LogEvent(r, "rpc-method-denied", strings.Join(denied, ","))
+	}
+	writeRPCError(w, r, http.StatusForbidden, v)
+	return false
+}
+
+// reject returns the JSON-RPC error response for rc when any call is denied
+// (nil when all are allowed) and the denied methods, log-safe. A batch with a
+// denied call is rejected as a whole: denied calls get "method not allowed",
+// the rest "batch rejected".
+func (p RPCPolicy) reject(rc rpcCalls) (any, []string) {
+	var denied []string
+	for _, c := range rc.Calls {
+		if !p.permits(c.Method) {
+			denied = append(denied, logSafeMethod(c.Method))
+		}
+	}
+	if len(denied) == 0 {
+		return nil, nil
+	}
+	if !rc.Batch {
+		c := rc.Calls[0]
+		return newRPCError(c.ID, jsonrpcMethodNotFound, "Method not allowed", c.Method+" is disabled on this endpoint"), denied
+	}
+	out := make([]rpcError, 0, len(rc.Calls))
+	for _, c := range rc.Calls {
+		if p.permits(c.Method) {
+			out = append(out, newRPCError(c.ID, jsonrpcInvalidRequest, "Batch rejected", "batch contains a method that is not allowed"))
+		} else {
+			out = append(out, newRPCError(c.ID, jsonrpcMethodNotFound, "Method not allowed", c.Method+" is disabled on this endpoint"))
+		}
+	}
+	return out, denied
+}
+
+// wsCheckMessage applies the chain's [rpc_policy] to a JSON-RPC frame sent
+// over /websocket (ws.Deps.CheckMessage): denied methods, oversized batches
+// and, with a method policy, unparsable frames get a JSON-RPC error instead
+// of reaching the node. Limiter weights are not charged per frame.
+func wsCheckMessage(r *http.Request, p []byte) []byte {
+	chain := currentRoutes().chains[normalizeHost(r.Host)]
+	if chain == nil {
+		return nil
+	}
+	pol := chain.RPCPolicy
+	if !pol.active() && pol.MaxBatchSize == 0 {
+		return nil
+	}
+	var v any
+	rc, err := parseRPCBody(p)
+	switch {
+	case err != nil:
+		if !pol.active() {
+			return nil
+		}
+		v = newRPCError(nil, jsonrpcParseError, "Parse error", err.Error())
+	case pol.active():
+		var denied []string
+		if v, denied = pol.reject(rc); v != nil && limiter != nil {
+			limiter.LogEvent(r, "rpc-method-denied", strings.Join(denied, ","))
+		}
+	}
+	if v == nil && rc.Batch && pol.MaxBatchSize > 0 && len(rc.Calls) > pol.MaxBatchSize {
+		if limiter != nil {
+			limiter.LogEvent(r, "rpc-batch-too-large", strconv.Itoa(len(rc.Calls)))
+		}
+		v = newRPCError(nil, jsonrpcInvalidRequest, "Batch too large",
+			fmt.Sprintf("batch of %d calls exceeds the limit of %d", len(rc.Calls), pol.MaxBatchSize))
+	}
+	if v == nil {
+		return nil
+	}
+	b, _ := json.Marshal(withRequestID(v, applog.RequestIDFrom(r)))
+	return b
+}
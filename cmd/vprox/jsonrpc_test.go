@@ -0,0 +1,251 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMatchMethod(t *testing.T) {
+	tests := []struct {
+		patterns []string
+		method   string
+		want     bool
+	}{
+		{[]string{"status"}, "status", true},
+		{[]string{"status"}, "status2", false},
+		{[]string{"status"}, "Status", false},
+		{[]string{"unsafe_*"}, "unsafe_flush_mempool", true},
+		{[]string{"unsafe_*"}, "unsafe_", true},
+		{[]string{"unsafe_*"}, "unsafe", false},
+		{[]string{"*"}, "anything", true},
+		{[]string{"*"}, "", true},
+		{[]string{"block", "tx_*"}, "tx_search", true},
+		{[]string{"block", "tx_*"}, "block_results", false},
+		{nil, "status", false},
+	}
+	for _, tt := range tests {
+		if got := matchMethod(tt.patterns, tt.method); got != tt.want {
+			t.Errorf("matchMethod(%q, %q) = %v, want %v", tt.patterns, tt.method, got, tt.want)
+		}
+	}
+}
+
+func TestRPCPolicyPermits(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy RPCPolicy
+		method string
+		want   bool
+	}{
+		{"no lists", RPCPolicy{}, "dial_seeds", true},
+		{"denied", RPCPolicy{Deny: []string{"dial_*"}}, "dial_seeds", false},
+		{"not denied", RPCPolicy{Deny: []string{"dial_*"}}, "status", true},
+		{"allowed", RPCPolicy{Allow: []string{"status", "block*"}}, "block_results", true},
+		{"not in allow list", RPCPolicy{Allow: []string{"status"}}, "genesis", false},
+		{"deny wins", RPCPolicy{Allow: []string{"*"}, Deny: []string{"genesis"}}, "genesis", false},
+		{"empty method", RPCPolicy{Allow: []string{"status"}}, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.permits(tt.method); got != tt.want {
+				t.Errorf("permits(%q) = %v, want %v", tt.method, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRPCPolicyWeightOf(t *testing.T) {
+	p := RPCPolicy{Weights: map[string]int{
+		"block_*":       3,
+		"block_search":  10,
+		"block_res*":    5,
+		"abci_query":    2,
+		"tx*":           0,
+		"unused_method": 7,
+	}}
+	tests := []struct {
+		method string
+		want   int
+	}{
+		{"block_search", 10}, // exact beats prefix
+		{"block_results", 5}, // longest prefix
+		{"block_header", 3},
+		{"block", 1}, // "block_*" needs the underscore
+		{"abci_query", 2},
+		{"abci_info", 1},
+		{"tx_search", 0}, // free
+		{"status", 1},
+		{"", 1},
+	}
+	for _, tt := range tests {
+		if got := p.weightOf(tt.method); got != tt.want {
+			t.Errorf("weightOf(%q) = %d, want %d", tt.method, got, tt.want)
+		}
+	}
+	if got := (RPCPolicy{}).weightOf("status"); got != 1 {
+		t.Errorf("weightOf without weights = %d, want 1", got)
+	}
+}
+
+func TestValidateRPCPolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  RPCPolicy
+		rpc     bool
+		wantErr string
+	}{
+		{"ok", RPCPolicy{Allow: []string{" status ", "block_*"}, Weights: map[string]int{"tx*": 2}}, true, ""},
+		{"empty pattern", RPCPolicy{Deny: []string{" "}}, true, "invalid method pattern"},
+		{"inner wildcard", RPCPolicy{Deny: []string{"un*safe"}}, true, "invalid method pattern"},
+		{"needs rpc", RPCPolicy{Deny: []string{"genesis"}}, false, "requires services.rpc"},
+		{"negative batch", RPCPolicy{MaxBatchSize: -1}, true, "max_batch_size"},
+		{"negative weight", RPCPolicy{Weights: map[string]int{"status": -1}}, true, "rpc_policy.weights"},
+		{"bad weight pattern", RPCPolicy{Weights: map[string]int{"*x": 1}}, true, "rpc_policy.weights"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &ChainConfig{RPCPolicy: tt.policy}
+			c.Services.RPC = tt.rpc
+			err := validateRPCPolicy(c)
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Fatalf("unexpected error: %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Fatalf("error = %v, want %q", err, tt.wantErr)
+			}
+			if err == nil && len(c.RPCPolicy.Allow) > 0 && c.RPCPolicy.Allow[0] != "status" {
+				t.Errorf("patterns not trimmed: %q", c.RPCPolicy.Allow)
+			}
+		})
+	}
+}
+
+func TestInspectRPC(t *testing.T) {
+	tests := []struct {
+		name        string
+		method      string
+		path        string
+		body        string
+		wantErr     bool
+		wantURI     bool
+		wantBatch   bool
+		wantMethods string
+	}{
+		{"uri call", "GET", "/status", "", false, true, false, "status"},
+		{"uri call ignores body", "POST", "/status", `{"method":"dial_seeds"}`, false, true, false, "status"},
+		{"nested path", "GET", "/a/b", "", false, true, false, ""},
+		{"index", "GET", "/", "", false, true, false, ""},
+		{"index empty body", "GET", "/", "  ", false, true, false, ""},
+		{"post single", "POST", "/", `{"jsonrpc":"2.0","id":1,"method":"block"}`, false, false, false, "block"},
+		{"get with body", "GET", "", `{"method":"dial_seeds"}`, false, false, false, "dial_seeds"},
+		{"put with body", "PUT", "/", `{"method":"dial_seeds"}`, false, false, false, "dial_seeds"},
+		{"batch", "POST", "/", ` [{"id":1,"method":"status"},{"id":2,"method":"block"}]`, false, false, true, "status,block"},
+		{"empty batch", "POST", "/", `[]`, true, false, false, ""},
+		{"post without body", "POST", "/", ``, false, true, false, ""},
+		{"garbage", "POST", "/", `{"method":`, true, false, false, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body io.Reader
+			if tt.body != "" {
+				body = strings.NewReader(tt.body)
+			}
+			r := httptest.NewRequest(tt.method, "/rpc"+tt.path, body)
+			rc, err := inspectRPC(r, tt.path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			var methods []string
+			for _, c := range rc.Calls {
+				methods = append(methods, c.Method)
+			}
+			if rc.URI != tt.wantURI || rc.Batch != tt.wantBatch || strings.Join(methods, ",") != tt.wantMethods {
+				t.Errorf("got URI=%v Batch=%v methods=%q, want %v %v %q",
+					rc.URI, rc.Batch, methods, tt.wantURI, tt.wantBatch, tt.wantMethods)
+			}
+			// The body is still forwarded, and replayable once buffered.
+			if b, _ := io.ReadAll(r.Body); string(b) != tt.body {
+				t.Errorf("body after inspection = %q, want %q", b, tt.body)
+			}
+			if r.GetBody != nil {
+				rb, _ := r.GetBody()
+				if b, _ := io.ReadAll(rb); string(b) != tt.body {
+					t.Errorf("GetBody = %q, want %q", b, tt.body)
+				}
+			}
+		})
+	}
+}
+
+func TestInspectRPCTooLarge(t *testing.T) {
+	big := strings.Repeat(" ", maxJSONRPCBody+10)
+	r := httptest.NewRequest("POST", "/rpc/", strings.NewReader(big))
+	if _, err := inspectRPC(r, "/"); err == nil {
+		t.Fatal("want an error for an oversized body")
+	}
+	if b, _ := io.ReadAll(r.Body); len(b) != len(big) {
+		t.Errorf("forwarded body has %d bytes, want %d", len(b), len(big))
+	}
+}
+
+func TestRPCPolicyReject(t *testing.T) {
+	p := RPCPolicy{Deny: []string{"dial_*"}}
+	call := func(id, m string) rpcCall { return rpcCall{ID: json.RawMessage(id), Method: m} }
+	tests := []struct {
+		name       string
+		rc         rpcCalls
+		wantDenied string
+		want       string // JSON of the response, "" when allowed
+	}{
+		{"allowed", rpcCalls{Calls: []rpcCall{call("1", "status")}}, "", ""},
+		{"no calls", rpcCalls{URI: true}, "", ""},
+		{"single denied", rpcCalls{Calls: []rpcCall{call("7", "dial_seeds")}}, "dial_seeds",
+			`{"jsonrpc":"2.0","id":7,"error":{"code":-32601,"message":"Method not allowed","data":"dial_seeds is disabled on this endpoint"}}`},
+		{"missing id", rpcCalls{Calls: []rpcCall{call("", "dial_peers")}}, "dial_peers",
+			`{"jsonrpc":"2.0","id":null,"error":{"code":-32601,"message":"Method not allowed","data":"dial_peers is disabled on this endpoint"}}`},
+		{"batch rejected whole", rpcCalls{Batch: true, Calls: []rpcCall{call("1", "status"), call(`"b"`, "dial_seeds")}}, "dial_seeds",
+			`[{"jsonrpc":"2.0","id":1,"error":{"code":-32600,"message":"Batch rejected","data":"batch contains a method that is not allowed"}},` +
+				`{"jsonrpc":"2.0","id":"b","error":{"code":-32601,"message":"Method not allowed","data":"dial_seeds is disabled on this endpoint"}}]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, denied := p.reject(tt.rc)
+			if strings.Join(denied, ",") != tt.wantDenied {
+				t.Errorf("denied = %q, want %q", denied, tt.wantDenied)
+			}
+			if tt.want == "" {
+				if v != nil {
+					t.Errorf("response = %v, want none", v)
+				}
+				return
+			}
+			b, err := json.Marshal(v)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(b) != tt.want {
+				t.Errorf("response =\n%s\nwant\n%s", b, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithRequestID(t *testing.T) {
+	single := withRequestID(newRPCError(nil, jsonrpcParseError, "Parse error", ""), "abc").(rpcError)
+	if single.Error.Data != "request_id=abc" {
+		t.Errorf("single data = %q", single.Error.Data)
+	}
+	batch := withRequestID([]rpcError{
+		newRPCError(json.RawMessage("1"), jsonrpcMethodNotFound, "Method not allowed", "x is disabled"),
+		newRPCError(json.RawMessage("2"), jsonrpcInvalidRequest, "Batch rejected", ""),
+	}, "abc").([]rpcError)
+	if batch[0].Error.Data != "x is disabled; request_id=abc" || batch[1].Error.Data != "request_id=abc" {
+		t.Errorf("batch data = %q, %q", batch[0].Error.Data, batch[1].Error.Data)
+	}
+}
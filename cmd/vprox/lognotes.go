@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sync"
+
+	applog "github.com/vNodesV/vProx/internal/logging"
+)
+
+// --------------------- ACCESS LOG NOTES ---------------------
+
+// logNotes collects extra fields for a request's access line. Handlers add
+// them as they learn things (JSON-RPC methods, retries, ...) and
+// logRequestSummary appends them. It also keeps the route of that line for
+// the request metrics.
+type logNotes struct {
+	mu     sync.Mutex
+	fields []applog.Field
+	route  string
+}
+
+type logNotesKey struct{}
+
+// withLogNotes attaches an empty note set to r (no-op if already present).
+func withLogNotes(r *http.Request) *http.Request {
+	if _, ok := r.Context().Value(logNotesKey{}).(*logNotes); ok {
+		return r
+	}
+	return r.WithContext(context.WithValue(r.Context(), logNotesKey{}, &logNotes{}))
+}
+
+// addLogNote records fields for r's access line.
+func addLogNote(r *http.Request, fields ...applog.Field) {
+	if n, ok := r.Context().Value(logNotesKey{}).(*logNotes); ok {
+		n.mu.Lock()
+		n.fields = append(n.fields, fields...)
+		n.mu.Unlock()
+	}
+}
+
+func logNotesOf(r *http.Request) []applog.Field {
+	n, ok := r.Context().Value(logNotesKey{}).(*logNotes)
+	if !ok {
+		return nil
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return append([]applog.Field(nil), n.fields...)
+}
+
+// setLogRoute records the route r was logged under.
+func setLogRoute(r *http.Request, route string) {
+	if n, ok := r.Context().Value(logNotesKey{}).(*logNotes); ok {
+		n.mu.Lock()
+		n.route = route
+		n.mu.Unlock()
+	}
+}
+
+func logRouteOf(r *http.Request) string {
+	if n, ok := r.Context().Value(logNotesKey{}).(*logNotes); ok {
+		n.mu.Lock()
+		defer n.mu.Unlock()
+		return n.route
+	}
+	return ""
+}
@@ -11,6 +11,7 @@ import (
 	"log"
 	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"os/exec"
 	"os/signal"
@@ -24,9 +25,13 @@ import (
 
 	toml "github.com/pelletier/go-toml/v2"
 	backup "github.com/vNodesV/vProx/internal/backup"
+	"github.com/vNodesV/vProx/internal/cache"
 	"github.com/vNodesV/vProx/internal/geo"
+	"github.com/vNodesV/vProx/internal/health"
 	"github.com/vNodesV/vProx/internal/limit"
 	applog "github.com/vNodesV/vProx/internal/logging"
+	"github.com/vNodesV/vProx/internal/metrics"
+	"github.com/vNodesV/vProx/internal/trace"
 	ws "github.com/vNodesV/vProx/internal/ws"
 )
 
@@ -58,6 +63,10 @@ type Services struct {
 	GRPC      bool `toml:"grpc"`
 	GRPCWeb   bool `toml:"grpc_web"`
 	APIAlias  bool `toml:"api_alias"`
+
+	// GRPCWebTranslate serves gRPC-Web on /grpc-web by translating to native
+	// gRPC against the gRPC port (no gRPC-Web port / Envoy needed).
+	GRPCWebTranslate bool `toml:"grpc_web_translate"`
 }
 
 type Features struct {
@@ -87,31 +96,65 @@ type WSConfig struct {
 	MaxLifetimeSec int `toml:"max_lifetime_sec"` // 0 = no hard cap
 }
 
+// BackendConfig is one upstream node in a chain's [[backends]] list.
+// Zero-valued ports inherit the chain's effective ports.
+type BackendConfig struct {
+	Name    string `toml:"name"`
+	IP      string `toml:"ip"`
+	Weight  int    `toml:"weight"` // default 1 (used by load_balance = "weighted")
+	Ports   Ports  `toml:"ports"`
+	Archive bool   `toml:"archive"` // serves only heights the pruned nodes no longer keep ([archive])
+}
+
+// HealthCfg controls active CometBFT /status checks for a chain's backends.
+type HealthCfg struct {
+	Enabled      bool  `toml:"enabled"`
+	IntervalSec  int   `toml:"interval_sec"`   // default 10
+	TimeoutSec   int   `toml:"timeout_sec"`    // default 3
+	MaxLagBlocks int64 `toml:"max_lag_blocks"` // default 10
+}
+
 type ChainConfig struct {
 	SchemaVersion int    `toml:"schema_version"`
 	ChainName     string `toml:"chain_name"`
 	Host          string `toml:"host"`
-	IP            string `toml:"ip"`
-
-	Aliases  Aliases    `toml:"aliases"`
-	Expose   Expose     `toml:"expose"`
-	Services Services   `toml:"services"`
-	Ports    Ports      `toml:"ports"`
-	WS       WSConfig   `toml:"ws"`
-	Features Features   `toml:"features"`
-	Logging  LoggingCfg `toml:"logging"`
-	Message  Message    `toml:"message"`
+	IP            string `toml:"ip"` // legacy single backend; same as a one-item [[backends]]
+
+	LoadBalance string          `toml:"load_balance"` // round_robin | weighted | least_inflight
+	Backends    []BackendConfig `toml:"backends"`
+
+	Aliases   Aliases      `toml:"aliases"`
+	Expose    Expose       `toml:"expose"`
+	Services  Services     `toml:"services"`
+	Ports     Ports        `toml:"ports"`
+	WS        WSConfig     `toml:"ws"`
+	Health    HealthCfg    `toml:"health"`
+	TLS       TLSCfg       `toml:"tls"`
+	Cache     CacheCfg     `toml:"cache"`
+	RPCPolicy RPCPolicy    `toml:"rpc_policy"`
+	Timeouts  TimeoutsCfg  `toml:"timeouts"`
+	Retry     RetryCfg     `toml:"retry"`
+	Breaker   BreakerCfg   `toml:"breaker"`
+	CORS      CORSCfg      `toml:"cors"`
+	Access    AccessCfg    `toml:"access"`
+	Mirror    MirrorCfg    `toml:"mirror"`
+	Archive   ArchiveCfg   `toml:"archive"`
+	Broadcast BroadcastCfg `toml:"broadcast"`
+	Features  Features     `toml:"features"`
+	Logging   LoggingCfg   `toml:"logging"`
+	Message   Message      `toml:"message"`
 
 	DefaultPorts bool `toml:"default_ports"`
 	Msg          bool `toml:"msg"`
+
+	pool   *backendPool  // built by loadChains from Backends
+	mirror *mirrorTarget // built by loadChains from Mirror; nil when off
+	fanout *broadcaster  // built by loadChains from Broadcast; nil when off
 }
 
 // --------------------- GLOBALS ---------------------
 
 var (
-	chains       = make(map[string]*ChainConfig)
-	defaultPorts Ports
-
 	vproxHome  string
 	configDir  string
 	chainsDir  string
@@ -125,14 +168,8 @@ var (
 
 	accessCountsPath string
 
-	httpClient = &http.Client{
-		Timeout: 5 * time.Second,
-		Transport: &http.Transport{
-			MaxIdleConns:       100,
-			IdleConnTimeout:    90 * time.Second,
-			DisableCompression: false,
-		},
-	}
+	healthChecker *health.Checker
+	limiter       *limit.IPLimiter // set in main; policy checks log through it
 
 	// logger state
 	srcCounter   = make(map[string]int64)
@@ -210,13 +247,13 @@ func validateConfig(c *ChainConfig) error {
 		c.SchemaVersion = 1
 	}
 
-	// Host/IP
+	// Host/backends
 	c.Host = strings.ToLower(strings.TrimSpace(c.Host))
 	if !isValidHostname(c.Host) {
 		return fmt.Errorf("invalid host: %q", c.Host)
 	}
-	if net.ParseIP(strings.TrimSpace(c.IP)) == nil {
-		return fmt.Errorf("invalid ip: %q", c.IP)
+	if err := normalizeBackends(c); err != nil {
+		return err
 	}
 
 	// Expose / prefixes
@@ -237,7 +274,7 @@ func validateConfig(c *ChainConfig) error {
 		if err := validatePortsLabel("rest", c.Ports.REST); err != nil {
 			return err
 		}
-		if c.Services.GRPC {
+		if c.Services.GRPC || c.Services.GRPCWebTranslate {
 			if err := validatePortsLabel("grpc", c.Ports.GRPC); err != nil {
 				return err
 			}
@@ -272,7 +309,7 @@ func validateConfig(c *ChainConfig) error {
 	}
 
 	// Services sanity: at least one service enabled
-	if !(c.Services.RPC || c.Services.REST || c.Services.GRPC || c.Services.GRPCWeb || c.Services.APIAlias || c.Services.WebSocket) {
+	if !(c.Services.RPC || c.Services.REST || c.Services.GRPC || c.Services.GRPCWeb || c.Services.GRPCWebTranslate || c.Services.APIAlias || c.Services.WebSocket) {
 		return errors.New("no services enabled; enable at least one in [services]")
 	}
 
@@ -289,6 +326,75 @@ func validateConfig(c *ChainConfig) error {
 		c.WS.MaxLifetimeSec = 0
 	}
 
+	// Upstream timeouts
+	if err := validateTimeouts(c); err != nil {
+		return err
+	}
+
+	// Retry/failover defaults
+	if err := validateRetry(c); err != nil {
+		return err
+	}
+
+	// Circuit breaker thresholds
+	if err := validateBreaker(c); err != nil {
+		return err
+	}
+
+	// CORS policy defaults/origins
+	if err := validateCORS(c); err != nil {
+		return err
+	}
+
+	// JSON-RPC method policy
+	if err := validateRPCPolicy(c); err != nil {
+		return err
+	}
+
+	// CIDR allow/deny lists
+	if err := validateAccess(c); err != nil {
+		return err
+	}
+
+	// Shadow traffic
+	if err := validateMirror(c); err != nil {
+		return err
+	}
+
+	// Broadcast fan-out sentries
+	if err := validateBroadcast(c); err != nil {
+		return err
+	}
+
+	// Response cache defaults/rules
+	if err := validateCache(c); err != nil {
+		return err
+	}
+
+	// TLS certificates (checked here so a bad pair fails the reload)
+	if err := validateTLS(c); err != nil {
+		return err
+	}
+
+	// Health checks probe RPC /status
+	if c.Health.Enabled && !c.Services.RPC {
+		return errors.New("health.enabled requires services.rpc to be enabled")
+	}
+
+	// Archive routing reads heights from the health probes
+	if err := validateArchive(c); err != nil {
+		return err
+	}
+	if c.Health.IntervalSec <= 0 {
+		c.Health.IntervalSec = 10
+	}
+	if c.Health.TimeoutSec <= 0 {
+		c.Health.TimeoutSec = 3
+	}
+	if c.Health.MaxLagBlocks <= 0 {
+		c.Health.MaxLagBlocks = 10
+	}
+
 	return nil
 }
 
@@ -329,20 +435,23 @@ func loadPorts(path string) (Ports, error) {
 	return p, nil
 }
 
-func registerHost(host string, c *ChainConfig) error {
+func (rt *routeTable) registerHost(host string, c *ChainConfig) error {
 	if host == "" {
 		return nil
 	}
-	if existing, ok := chains[host]; ok {
+	if existing, ok := rt.chains[host]; ok {
 		if existing.ChainName != c.ChainName {
 			return fmt.Errorf("duplicate host %q in chain %q conflicts with %q", host, c.ChainName, existing.ChainName)
 		}
 	}
-	chains[host] = c
+	rt.chains[host] = c
+	if cert, key, ok := c.TLS.pairFor(host); ok {
+		rt.tlsHosts[strings.ToLower(host)] = tlsFiles{cert: cert, key: key}
+	}
 	return nil
 }
 
-func loadChains(dir string) error {
+func (rt *routeTable) loadChains(dir string) error {
 	entries, err := os.ReadDir(dir)
 	if err != nil {
 		return err
@@ -367,6 +476,9 @@ func loadChains(dir string) error {
 		if err := validateConfig(&c); err != nil {
 			return fmt.Errorf("%s: %w", entry.Name(), err)
 		}
+		c.pool = newBackendPool(&c, rt.defaultPorts)
+		c.mirror = newMirrorTarget(&c, rt.defaultPorts)
+		c.fanout = newBroadcaster(&c, rt.defaultPorts)
 
 		base := c.Host // already normalized
 		// normalize alias lists
@@ -381,7 +493,7 @@ func loadChains(dir string) error {
 		}
 
 		// register base host
-		if err := registerHost(base, &c); err != nil {
+		if err := rt.registerHost(base, &c); err != nil {
 			return fmt.Errorf("%s: %w", entry.Name(), err)
 		}
 
@@ -389,10 +501,10 @@ func loadChains(dir string) error {
 		if c.Expose.VHost {
 			rp := c.Expose.VHostPrefix.RPC
 			ap := c.Expose.VHostPrefix.REST
-			if err := registerHost(rp+"."+base, &c); err != nil {
+			if err := rt.registerHost(rp+"."+base, &c); err != nil {
 				return fmt.Errorf("%s: %w", entry.Name(), err)
 			}
-			if err := registerHost(ap+"."+base, &c); err != nil {
+			if err := rt.registerHost(ap+"."+base, &c); err != nil {
 				return fmt.Errorf("%s: %w", entry.Name(), err)
 			}
 		}
@@ -400,27 +512,27 @@ func loadChains(dir string) error {
 		// register explicit alias hosts
 		for _, h := range c.Aliases.RPC {
 			if h != "" {
-				if err := registerHost(h, &c); err != nil {
+				if err := rt.registerHost(h, &c); err != nil {
 					return fmt.Errorf("%s: %w", entry.Name(), err)
 				}
 			}
 		}
 		for _, h := range c.Aliases.REST {
 			if h != "" {
-				if err := registerHost(h, &c); err != nil {
+				if err := rt.registerHost(h, &c); err != nil {
 					return fmt.Errorf("%s: %w", entry.Name(), err)
 				}
 			}
 		}
 		for _, h := range c.Aliases.API {
 			if h != "" {
-				if err := registerHost(h, &c); err != nil {
+				if err := rt.registerHost(h, &c); err != nil {
 					return fmt.Errorf("%s: %w", entry.Name(), err)
 				}
 			}
 		}
 	}
-	if len(chains) == 0 {
+	if len(rt.chains) == 0 {
 		return errors.New("no chain configs found in " + dir)
 	}
 	return nil
@@ -512,14 +624,6 @@ func injectBannerFromString(html, banner string) string {
 	return strings.Replace(html, "<body>", "<body>\n<div class=\"banner\">\n"+banner+"\n</div>\n", 1)
 }
 
-func injectBannerFile(html, bannerPath string) (string, error) {
-	b, err := os.ReadFile(bannerPath)
-	if err != nil {
-		return "", err
-	}
-	return strings.Replace(html, "<body>", "<body>\n<div class=\"banner\">\n"+string(b)+"\n</div>\n", 1), nil
-}
-
 func bannerPath(chain, routePrefix string) string {
 	chain = strings.ToLower(chain)
 	switch routePrefix {
@@ -587,6 +691,8 @@ func logRequestSummary(r *http.Request, proxied bool, route string, host string,
 	switch {
 	case strings.HasPrefix(route, "ws") || strings.HasPrefix(route, "websocket"):
 		logID = applog.EnsureRequestID(r) // WSS{hex} set by ws.go
+	case route == "grpc":
+		logID = applog.NewTypedID("GRP")
 	default:
 		logID = applog.NewTypedID(pathPrefix(dst))
 	}
@@ -601,13 +707,13 @@ func logRequestSummary(r *http.Request, proxied bool, route string, host string,
 	switch route {
 	case "websocket":
 		status = "CONNECTED"
-	case "ws-deny":
+	case "ws-deny", "apikey-denied", "access-denied":
 		status = "DENIED"
 	case "ws-upgrade-fail", "ws-backend-fail":
 		status = "FAILED"
 	}
 
-	line := applog.LineLifecycle("NEW", "vProx",
+	fields := []applog.Field{
 		applog.F("ID", logID),
 		applog.F("status", status),
 		applog.F("method", r.Method),
@@ -618,13 +724,22 @@ func logRequestSummary(r *http.Request, proxied bool, route string, host string,
 		applog.F("latency", fmt.Sprintf("%dms", durMS)),
 		applog.F("userAgent", ua),
 		applog.F("country", country),
-	)
+	}
+	fields = append(fields, logNotesOf(r)...)
+	if id := trace.FromContext(r.Context()).TraceID(); id != "" {
+		fields = append(fields, applog.F("traceID", id))
+	}
+	line := applog.LineLifecycle("NEW", "vProx", fields...)
 	log.Println(line)
-	if ch, ok := chains[hostNorm]; ok {
+	chainName := ""
+	if ch, ok := currentRoutes().chains[hostNorm]; ok {
+		chainName = ch.ChainName
 		if cl := getChainLogger(ch); cl != nil {
 			cl.Println(line)
 		}
 	}
+	requestStats.record(chainName, route, status)
+	setLogRoute(r, route)
 }
 
 // pathPrefix returns a 3-letter log ID prefix based on the request path.
@@ -978,6 +1093,42 @@ func envBytes(key string) int64 {
 	return parseBytes(v)
 }
 
+// firstEnv returns the first non-empty of the given environment variables.
+func firstEnv(keys ...string) string {
+	for _, k := range keys {
+		if v := strings.TrimSpace(os.Getenv(k)); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// otlpProtocolName is the protocol trace.Init uses for p (http by default).
+func otlpProtocolName(p string) string {
+	if p = strings.ToLower(strings.TrimSpace(p)); p == "" || p == "http/protobuf" {
+		return "http"
+	}
+	return p
+}
+
+// parseHeaderList parses "k=v,k2=v2" (the OTEL_EXPORTER_OTLP_HEADERS format,
+// values URL-encoded).
+func parseHeaderList(v string) map[string]string {
+	out := map[string]string{}
+	for _, kv := range strings.Split(v, ",") {
+		k, val, ok := strings.Cut(kv, "=")
+		k = strings.TrimSpace(k)
+		if !ok || k == "" {
+			continue
+		}
+		if dec, err := url.QueryUnescape(strings.TrimSpace(val)); err == nil {
+			val = dec
+		}
+		out[k] = strings.TrimSpace(val)
+	}
+	return out
+}
+
 func parseBytes(s string) int64 {
 	s = strings.TrimSpace(strings.ToUpper(s))
 	if s == "" {
@@ -1099,95 +1250,144 @@ func disableBackupInConfig(path string) error {
 
 // printBackupStatus prints backup automation state, trigger conditions, and
 // the ETA until the next scheduled backup.
-func printBackupStatus(cfgPath, statePath, archiveDir string) {
+// backupStatus is the scheduler state derived from backup.toml, the state
+// file and the archive directory (--backup-status and the admin API).
+type backupStatus struct {
+	Automation       bool      `json:"automation"`
+	ConfigSource     string    `json:"config_source"`
+	IntervalDays     int       `json:"interval_days"`
+	MaxSizeMB        int64     `json:"max_size_mb"`
+	CheckIntervalMin int       `json:"check_interval_min"`
+	LastRun          time.Time `json:"last_run,omitzero"`
+	NextRun          time.Time `json:"next_run,omitzero"` // zero when not scheduled by interval
+	Due              bool      `json:"due"`
+	ArchiveDir       string    `json:"archive_dir"`
+	Archives         int       `json:"archives"`
+}
+
+func readBackupStatus(cfgPath, statePath, archiveDir string) backupStatus {
 	cfg, loaded, _ := backup.LoadConfig(cfgPath)
 	b := cfg.Backup
+	st := backupStatus{
+		Automation:       b.Automation,
+		ConfigSource:     "defaults",
+		IntervalDays:     b.IntervalDays,
+		MaxSizeMB:        b.MaxSizeMB,
+		CheckIntervalMin: b.CheckIntervalMin,
+		ArchiveDir:       archiveDir,
+		Archives:         -1,
+	}
+	if loaded {
+		st.ConfigSource = cfgPath
+	}
+
+	// Read last run time from state file.
+	if data, err := os.ReadFile(statePath); err == nil {
+		if sec, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64); err == nil {
+			st.LastRun = time.Unix(sec, 0).UTC()
+		}
+	}
+	if st.Automation && b.IntervalDays > 0 {
+		if st.LastRun.IsZero() {
+			st.Due = true
+		} else {
+			st.NextRun = st.LastRun.Add(time.Duration(b.IntervalDays) * 24 * time.Hour)
+			st.Due = !time.Now().Before(st.NextRun)
+		}
+	}
+
+	// Count archives.
+	if entries, err := os.ReadDir(archiveDir); err == nil {
+		st.Archives = 0
+		for _, e := range entries {
+			if !e.IsDir() && strings.HasSuffix(e.Name(), ".tar.gz") {
+				st.Archives++
+			}
+		}
+	}
+	return st
+}
+
+func printBackupStatus(cfgPath, statePath, archiveDir string) {
+	st := readBackupStatus(cfgPath, statePath, archiveDir)
 
 	fmt.Println("vProx Backup Status")
 	fmt.Println("")
 
 	automationLabel := "disabled"
-	if b.Automation {
-		automationLabel = "enabled"
-	}
-	effectivelyActive := b.Automation
 	activeLabel := "inactive"
-	if effectivelyActive {
+	if st.Automation {
+		automationLabel = "enabled"
 		activeLabel = "active"
 	}
-
-	cfgSource := "defaults"
-	if loaded {
-		cfgSource = cfgPath
-	}
-	fmt.Printf("  Automation:       %s  (source: %s)\n", automationLabel, cfgSource)
+	fmt.Printf("  Automation:       %s  (source: %s)\n", automationLabel, st.ConfigSource)
 	fmt.Printf("  Scheduler:        %s\n", activeLabel)
 	fmt.Println("")
 
-	if b.IntervalDays > 0 {
-		fmt.Printf("  Trigger interval: every %d day(s)\n", b.IntervalDays)
+	if st.IntervalDays > 0 {
+		fmt.Printf("  Trigger interval: every %d day(s)\n", st.IntervalDays)
 	} else {
 		fmt.Println("  Trigger interval: disabled (interval_days = 0)")
 	}
-	if b.MaxSizeMB > 0 {
-		fmt.Printf("  Trigger max size: %d MB\n", b.MaxSizeMB)
+	if st.MaxSizeMB > 0 {
+		fmt.Printf("  Trigger max size: %d MB\n", st.MaxSizeMB)
 	} else {
 		fmt.Println("  Trigger max size: disabled (max_size_mb = 0)")
 	}
-	fmt.Printf("  Check interval:   every %d min\n", b.CheckIntervalMin)
+	fmt.Printf("  Check interval:   every %d min\n", st.CheckIntervalMin)
 	fmt.Println("")
 
-	// Read last run time from state file.
-	var lastRun time.Time
-	if data, err := os.ReadFile(statePath); err == nil {
-		if sec, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64); err == nil {
-			lastRun = time.Unix(sec, 0).UTC()
-		}
-	}
-
-	if lastRun.IsZero() {
+	if st.LastRun.IsZero() {
 		fmt.Println("  Last backup:      never")
 	} else {
-		ago := time.Since(lastRun).Truncate(time.Minute)
-		fmt.Printf("  Last backup:      %s  (%s ago)\n", lastRun.Format("2006-01-02 15:04:05 UTC"), ago)
+		ago := time.Since(st.LastRun).Truncate(time.Minute)
+		fmt.Printf("  Last backup:      %s  (%s ago)\n", st.LastRun.Format("2006-01-02 15:04:05 UTC"), ago)
 	}
 
-	if !effectivelyActive {
+	switch {
+	case !st.Automation:
 		fmt.Println("  Next backup ETA:  n/a (scheduler is inactive)")
-	} else if b.IntervalDays > 0 && !lastRun.IsZero() {
-		nextRun := lastRun.Add(time.Duration(b.IntervalDays) * 24 * time.Hour)
-		eta := time.Until(nextRun).Truncate(time.Minute)
-		if eta <= 0 {
-			fmt.Println("  Next backup ETA:  due now (trigger condition met)")
-		} else {
-			fmt.Printf("  Next backup ETA:  %s  (in %s)\n", nextRun.Format("2006-01-02 15:04:05 UTC"), eta)
-		}
-	} else if b.IntervalDays > 0 && lastRun.IsZero() {
+	case st.IntervalDays > 0 && st.LastRun.IsZero():
 		fmt.Println("  Next backup ETA:  due now (no previous backup recorded)")
-	} else {
+	case st.IntervalDays > 0 && st.Due:
+		fmt.Println("  Next backup ETA:  due now (trigger condition met)")
+	case st.IntervalDays > 0:
+		eta := time.Until(st.NextRun).Truncate(time.Minute)
+		fmt.Printf("  Next backup ETA:  %s  (in %s)\n", st.NextRun.Format("2006-01-02 15:04:05 UTC"), eta)
+	default:
 		fmt.Println("  Next backup ETA:  n/a (interval trigger disabled)")
 	}
 	fmt.Println("")
 
-	// Count archives.
-	entries, err := os.ReadDir(archiveDir)
-	if err == nil {
-		count := 0
-		for _, e := range entries {
-			if !e.IsDir() && strings.HasSuffix(e.Name(), ".tar.gz") {
-				count++
-			}
-		}
-		fmt.Printf("  Archive dir:      %s\n", archiveDir)
-		fmt.Printf("  Archives:         %d file(s)\n", count)
+	if st.Archives >= 0 {
+		fmt.Printf("  Archive dir:      %s\n", st.ArchiveDir)
+		fmt.Printf("  Archives:         %d file(s)\n", st.Archives)
 	}
 
-	if !b.Automation {
+	if !st.Automation {
 		fmt.Println("")
 		fmt.Println("  Automation is disabled. Use 'vProx --new-backup' to create a backup manually.")
 	}
 }
 
+// manualBackupOptions are the options of an on-demand backup run
+// (--new-backup and the admin API); backup.toml is re-read on each call.
+func manualBackupOptions(mainLogPath string) backup.Options {
+	bupCfg, bupLoaded, _ := backup.LoadConfig(resolveBackupConfigPath(configDir))
+	listSrc := "default"
+	if bupLoaded {
+		listSrc = "loaded"
+	}
+	return backup.Options{
+		LogPath:    mainLogPath,
+		ArchiveDir: archiveDir,
+		StatePath:  filepath.Join(dataDir, "backup.last"),
+		Method:     "MANUAL",
+		ExtraFiles: resolveBackupExtraFiles(bupCfg, dataDir, logsDir, configDir, mainLogPath),
+		ListSource: listSrc,
+	}
+}
+
 // resolveBackupExtraFiles builds the list of additional (non-LogPath) absolute
 // paths to include in a backup archive from backup.toml config.
 // main.log is always handled via Options.LogPath; it is excluded here.
@@ -1248,7 +1448,7 @@ func isChainTOML(name string) bool {
 	if strings.HasSuffix(name, ".sample.toml") {
 		return false
 	}
-	skip := []string{"ports.toml", "backup.toml"}
+	skip := []string{"ports.toml", "backup.toml", "apikeys.toml", "access.toml"}
 	for _, s := range skip {
 		if strings.EqualFold(name, s) {
 			return false
@@ -1257,6 +1457,31 @@ func isChainTOML(name string) bool {
 	return true
 }
 
+// hasRoutePrefix reports whether path is prefix itself or lies below it
+// ("/grpc" matches "/grpc" and "/grpc/x" but not "/grpc-web").
+func hasRoutePrefix(path, prefix string) bool {
+	return path == prefix || strings.HasPrefix(path, prefix+"/")
+}
+
+// vhostRoute reports whether host is the chain's RPC or REST vhost
+// (rpc.<host> / api.<host> by default, or an explicit alias).
+func vhostRoute(chain *ChainConfig, host string) (isRPC, isREST bool) {
+	if !chain.Expose.VHost {
+		return false, false
+	}
+	rp := chain.Expose.VHostPrefix.RPC
+	ap := chain.Expose.VHostPrefix.REST
+	if rp == "" {
+		rp = "rpc"
+	}
+	if ap == "" {
+		ap = "api"
+	}
+	isRPC = strings.HasPrefix(host, rp+".") || inList(chain.Aliases.RPC, host)
+	isREST = strings.HasPrefix(host, ap+".") || inList(chain.Aliases.REST, host) || inList(chain.Aliases.API, host)
+	return isRPC, isREST
+}
+
 func inList(list []string, needle string) bool {
 	needle = strings.ToLower(strings.TrimSpace(needle))
 	for _, s := range list {
@@ -1270,65 +1495,93 @@ func inList(list []string, needle string) bool {
 // --------------------- CORE HANDLER ---------------------
 
 func handler(w http.ResponseWriter, r *http.Request) {
+	// Native gRPC (HTTP/2, application/grpc) takes the dedicated h2c path.
+	if isGRPCRequest(r) {
+		grpcHandler(w, r)
+		return
+	}
+
 	start := time.Now()
+	r = withLogNotes(r)
 	requestID := applog.EnsureRequestID(r)
 	applog.SetResponseRequestID(w, requestID)
 	host := normalizeHost(r.Host)
 
-	chain, ok := chains[host]
+	chain, ok := currentRoutes().chains[host]
 	if !ok {
-		http.Error(w, "Unknown host", http.StatusBadRequest)
+		writeError(w, r, http.StatusBadRequest, "Unknown host")
 		logRequestSummary(r, false, "direct", host, start)
 		return
 	}
+	// CORS: answer preflights here; withCORS normalizes headers on
+	// everything else.
+	if chain.CORS.Enabled && isPreflight(r) {
+		ok := handlePreflight(w, r, &chain.CORS)
+		logRequestSummary(r, ok, "preflight", host, start)
+		return
+	}
+	if maybeRedirectHTTPS(w, r, chain, host) {
+		logRequestSummary(r, true, "redirect", host, start)
+		return
+	}
+	// Built-in chain status (from the health probes; no backend is involved).
+	if r.URL.Path == statusPath && (r.Method == http.MethodGet || r.Method == http.MethodHead) {
+		serveStatus(w, chain)
+		logRequestSummary(r, true, "status", host, start)
+		return
+	}
 
-	// Resolve effective ports
-	eff := defaultPorts
-	if !chain.DefaultPorts {
-		if chain.Ports.RPC != 0 {
-			eff.RPC = chain.Ports.RPC
-		}
-		if chain.Ports.REST != 0 {
-			eff.REST = chain.Ports.REST
-		}
-		if chain.Ports.GRPC != 0 {
-			eff.GRPC = chain.Ports.GRPC
-		}
-		if chain.Ports.GRPCWeb != 0 {
-			eff.GRPCWeb = chain.Ports.GRPCWeb
+	// Pick a backend node; its ports already include chain/default fallbacks.
+	node := chain.pool.next()
+	if node == nil {
+		writeError(w, r, http.StatusServiceUnavailable, "No backend available")
+		logRequestSummary(r, false, "direct", host, start)
+		return
+	}
+	// node may change on failover; release whichever served last.
+	node.acquire()
+	defer func() { node.release() }()
+	tried := []*backendNode{node}
+	switchTo := func(n *backendNode) {
+		if n == node {
+			return
 		}
-		if chain.Ports.API != 0 {
-			eff.API = chain.Ports.API
+		node.release()
+		node = n
+		node.acquire()
+		tried = append(tried, node)
+	}
+	// Old heights move the request to the archive group ([archive]); retries
+	// then stay within that group.
+	pool := chain.pool
+	routeByHeight := func(h int64) {
+		if p := chain.pool.forHeight(chain.Archive, h); p != pool {
+			if n := p.next(); n != nil {
+				pool = p
+				switchTo(n)
+				tried = []*backendNode{n}
+				addLogNote(r, applog.F("archive", h))
+			}
 		}
 	}
 
 	// Detect vhost (rpc.<host> / api|rest.<host>) and explicit aliases
-	isRPCvhost, isRESTvhost := false, false
-	if chain.Expose.VHost {
-		rp := chain.Expose.VHostPrefix.RPC
-		ap := chain.Expose.VHostPrefix.REST
-		if rp == "" {
-			rp = "rpc"
-		}
-		if ap == "" {
-			ap = "api"
-		}
-		isRPCvhost = strings.HasPrefix(host, rp+".") || inList(chain.Aliases.RPC, host)
-		isRESTvhost = strings.HasPrefix(host, ap+".") || inList(chain.Aliases.REST, host) || inList(chain.Aliases.API, host)
-	}
+	isRPCvhost, isRESTvhost := vhostRoute(chain, host)
 
 	var (
-		targetURL   string
-		bannerFile  string
-		bannerHTML  string
-		injectHTML  bool
-		routePrefix string
-		route       string
+		upstreamPath string // path on the node, route prefix stripped
+		bannerFile   string
+		bannerHTML   string
+		injectHTML   bool
+		routePrefix  string
+		route        string
+		svc          string // upstream service: timeouts + transport
 	)
 
 	// 1) VHOST routing if enabled and matched
 	if isRPCvhost && chain.Services.RPC {
-		targetURL = fmt.Sprintf("http://%s:%d%s", chain.IP, eff.RPC, r.URL.Path)
+		upstreamPath = r.URL.Path
+		svc = svcRPC
 		route = "direct"
 		routePrefix = rpcPrefix
 		if chain.Features.InjectRPCIndex && (r.URL.Path == "/" || r.URL.Path == "") {
@@ -1337,7 +1590,8 @@ func handler(w http.ResponseWriter, r *http.Request) {
 			injectHTML = true
 		}
 	} else if isRESTvhost && chain.Services.REST {
-		targetURL = fmt.Sprintf("http://%s:%d%s", chain.IP, eff.REST, r.URL.Path)
+		upstreamPath = r.URL.Path
+		svc = svcREST
 		route = "direct"
 		routePrefix = restPrefix
 		if chain.Features.InjectRestSwagger && r.URL.Path == "/swagger/" {
@@ -1351,7 +1605,8 @@ func handler(w http.ResponseWriter, r *http.Request) {
 		if chain.Expose.Path {
 			switch {
 			case strings.HasPrefix(r.URL.Path, rpcPrefix) && chain.Services.RPC:
-				targetURL = fmt.Sprintf("http://%s:%d%s", chain.IP, eff.RPC, strings.TrimPrefix(r.URL.Path, rpcPrefix))
+				upstreamPath = strings.TrimPrefix(r.URL.Path, rpcPrefix)
+				svc = svcRPC
 				route = "rpc"
 				routePrefix = rpcPrefix
 				if chain.Features.InjectRPCIndex && (r.URL.Path == "/rpc" || r.URL.Path == "/rpc/") {
@@ -1361,7 +1616,8 @@ func handler(w http.ResponseWriter, r *http.Request) {
 				}
 
 			case strings.HasPrefix(r.URL.Path, restPrefix) && chain.Services.REST:
-				targetURL = fmt.Sprintf("http://%s:%d%s", chain.IP, eff.REST, strings.TrimPrefix(r.URL.Path, restPrefix))
+				upstreamPath = strings.TrimPrefix(r.URL.Path, restPrefix)
+				svc = svcREST
 				route = "rest"
 				routePrefix = restPrefix
 				if chain.Features.InjectRestSwagger && r.URL.Path == "/rest/swagger/" {
@@ -1370,86 +1626,224 @@ func handler(w http.ResponseWriter, r *http.Request) {
 					injectHTML = true
 				}
 
-			case strings.HasPrefix(r.URL.Path, grpcPrefix) && chain.Services.GRPC:
-				targetURL = fmt.Sprintf("http://%s:%d%s", chain.IP, eff.GRPC, strings.TrimPrefix(r.URL.Path, grpcPrefix))
+			// /grpc-web and /grpc share a prefix; match on a segment boundary.
+			case hasRoutePrefix(r.URL.Path, grpcWebPrefix) && chain.Services.GRPCWebTranslate && isGRPCWebRequest(r):
+				routeByHeight(parseHeight(r.Header.Get("x-cosmos-block-height")))
+				err := grpcWebTranslate(w, r, node, strings.TrimPrefix(r.URL.Path, grpcWebPrefix))
+				logRequestSummary(r, err == nil, "grpc-web", host, start)
+				return
+
+			case hasRoutePrefix(r.URL.Path, grpcWebPrefix) && chain.Services.GRPCWeb:
+				upstreamPath = strings.TrimPrefix(r.URL.Path, grpcWebPrefix)
+				svc = svcGRPCWeb
 				route = "rest"
 
-			case strings.HasPrefix(r.URL.Path, grpcWebPrefix) && chain.Services.GRPCWeb:
-				targetURL = fmt.Sprintf("http://%s:%d%s", chain.IP, eff.GRPCWeb, strings.TrimPrefix(r.URL.Path, grpcWebPrefix))
+			case hasRoutePrefix(r.URL.Path, grpcPrefix) && chain.Services.GRPC:
+				upstreamPath = strings.TrimPrefix(r.URL.Path, grpcPrefix)
+				svc = svcGRPC
 				route = "rest"
 
 			case strings.HasPrefix(r.URL.Path, apiPrefix) && chain.Services.APIAlias:
-				targetURL = fmt.Sprintf("http://%s:%d%s", chain.IP, eff.API, strings.TrimPrefix(r.URL.Path, apiPrefix))
+				upstreamPath = strings.TrimPrefix(r.URL.Path, apiPrefix)
+				svc = svcAPI
 				route = "rest"
 				routePrefix = apiPrefix
 
 			case (r.URL.Path == "/" || r.URL.Path == "") && chain.Services.REST:
-				targetURL = fmt.Sprintf("http://%s:%d/", chain.IP, eff.REST)
+				upstreamPath = "/"
+				svc = svcREST
 				route = "rest"
 			}
 		}
 	}
 
-	if targetURL == "" {
-		http.Error(w, "Not Found or service disabled", http.StatusNotFound)
+	if svc == "" {
+		writeError(w, r, http.StatusNotFound, "Not Found or service disabled")
 		logRequestSummary(r, false, "direct", host, start)
 		return
 	}
 
-	if r.URL.RawQuery != "" {
-		targetURL += "?" + r.URL.RawQuery
+	// JSON-RPC: method policy, batch size, per-call limiter charge.
+	var calls rpcCalls
+	if routePrefix == rpcPrefix {
+		var ok bool
+		if calls, ok = checkRPCRequest(w, r, chain, upstreamPath); !ok {
+			logRequestSummary(r, false, route, host, start)
+			return
+		}
 	}
 
-	// Build upstream request (preserve method/body/headers)
-	req, err := http.NewRequest(r.Method, targetURL, r.Body)
-	if err != nil {
-		http.Error(w, "Request build error", http.StatusInternalServerError)
-		logRequestSummary(r, false, route, host, start)
+	// [broadcast]: transactions go to several nodes at once, first success wins.
+	if chain.fanout != nil && broadcastTx(r, routePrefix, upstreamPath, calls) {
+		ok := chain.fanout.fanOut(w, r, node, svc, upstreamPath, routePrefix, calls)
+		logRequestSummary(r, ok, route, host, start)
 		return
 	}
-	req.Header = r.Header.Clone()
-	// Ensure correlation id is forwarded to upstream.
-	if requestID != "" {
-		req.Header.Set(applog.RequestIDHeader, requestID)
+
+	// Response cache: serve HITs without touching the backend.
+	var cacheKey string
+	var cacheTTL time.Duration
+	if !injectHTML {
+		cacheKey, cacheTTL = cacheKeyFor(chain, routePrefix, upstreamPath, r)
+	}
+	if cacheKey != "" && !cacheControlHas(r.Header, "no-cache") {
+		if e, ok := respCache.Get(cacheKey); ok {
+			serveCached(w, e)
+			logRequestSummary(r, true, route, host, start)
+			return
+		}
+	}
+
+	routeByHeight(requestHeight(r, routePrefix, upstreamPath, calls))
+
+	// Per-service upstream client; the total timeout bounds the whole
+	// exchange (retries and body streaming included) and the client
+	// connection gets a matching deadline.
+	total := node.upstream(svc).timeouts.total
+	setRouteDeadlines(w, total)
+	ctx := r.Context()
+	if total > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, total)
+		defer cancel()
 	}
 
-	// Propagate forwarding info
-	req.Header.Set("X-Forwarded-Host", host)
-	if xf := req.Header.Get("X-Forwarded-For"); xf == "" {
-		req.Header.Set("X-Forwarded-For", clientIP(r))
+	// Idempotent requests may fail over to another backend ([retry]).
+	// The retry budget is a share of all proxied requests of the chain.
+	maxAttempts := 1
+	if chain.Retry.Enabled {
+		chain.pool.budget.request()
+		if len(pool.serving) > 1 && retryable(r, calls) {
+			maxAttempts = chain.Retry.MaxAttempts
+		}
 	}
 
-	// Proxy
-	resp, err := httpClient.Do(req)
-	if err != nil {
-		http.Error(w, "Backend error", http.StatusBadGateway)
+	// [mirror]: a sampled copy goes to the shadow node in the background and
+	// is compared with the response of the last attempt.
+	mirror := chain.mirror.start(r, svc, upstreamPath, calls)
+	var primary mirrorResult
+	defer func() { mirror.done(primary) }()
+
+	// attempt counts requests actually sent; breaker skips are not attempts.
+	var resp *http.Response
+	var attempt int
+	for attempt = 1; ; attempt++ {
+		// An open breaker fails over before anything is sent, or fails fast.
+		n := pool.admit(node, tried)
+		if n == nil {
+			addLogNote(r, applog.F("breaker", "OPEN"))
+			renderError(w, r, http.StatusServiceUnavailable, "Backend unavailable (circuit open)", calls)
+			logRequestSummary(r, false, route, host, start)
+			return
+		}
+		switchTo(n)
+
+		req, err := newUpstreamRequest(ctx, r, node, svc, upstreamPath, attempt > 1)
+		if err != nil {
+			node.breaker.Cancel()
+			renderError(w, r, http.StatusInternalServerError, "Request build error", calls)
+			logRequestSummary(r, false, route, host, start)
+			return
+		}
+		req.Header = r.Header.Clone()
+		// Ensure correlation id is forwarded to upstream.
+		if requestID != "" {
+			req.Header.Set(applog.RequestIDHeader, requestID)
+		}
+
+		// Pages we may rewrite are requested as gzip or identity only, so the
+		// body is always decodable by the rewrite pipeline. Cached responses
+		// are normalized the same way so the cache key only needs the gzip flag.
+		if injectHTML || cacheKey != "" {
+			if acceptsGzip(r) {
+				req.Header.Set("Accept-Encoding", "gzip")
+			} else {
+				req.Header.Set("Accept-Encoding", "identity")
+			}
+		}
+
+		// Propagate forwarding info
+		req.Header.Set("X-Forwarded-Host", host)
+		if xf := req.Header.Get("X-Forwarded-For"); xf == "" {
+			req.Header.Set("X-Forwarded-For", clientIP(r))
+		}
+
+		// Proxy
+		sent := time.Now()
+		resp, err = node.upstream(svc).client.Do(req)
+		primary = mirrorResult{latency: time.Since(sent), err: err}
+		if err == nil {
+			primary.status = resp.StatusCode
+		}
+		node.observe(r, resp, err)
+		again := attempt < maxAttempts && ctx.Err() == nil
+		if err == nil && !(again && chain.Retry.retryStatus(resp.StatusCode)) {
+			break
+		}
+		if err == nil || again && retryError(err) {
+			if next := pool.nextExcept(tried); next != nil && pool.budget.take() {
+				if resp != nil {
+					_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+					resp.Body.Close()
+				}
+				switchTo(next)
+				continue
+			}
+			if err == nil {
+				break // no other node or budget spent: forward this response
+			}
+		}
+		if attempt > 1 {
+			addLogNote(r, applog.F("attempts", attempt))
+		}
+		if isTimeout(err) {
+			renderError(w, r, http.StatusGatewayTimeout, "Backend timeout", calls)
+		} else {
+			renderError(w, r, http.StatusBadGateway, "Backend error", calls)
+		}
 		logRequestSummary(r, false, route, host, start)
 		return
 	}
+	if attempt > 1 {
+		addLogNote(r, applog.F("attempts", attempt))
+	}
 	defer resp.Body.Close()
 
 	ctype := resp.Header.Get("Content-Type")
-	willModify := injectHTML && strings.HasPrefix(ctype, "text/html")
+	cenc := strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding")))
+	gzipped := cenc == "gzip"
+	willModify := injectHTML && strings.HasPrefix(ctype, "text/html") &&
+		(cenc == "" || cenc == "identity" || gzipped)
 
-	// Forward headers
+	// Forward headers (Content-Encoding is kept: gzip is re-encoded below)
 	for k, v := range resp.Header {
-		lk := strings.ToLower(k)
 		// Always drop Content-Length; Go will recalc
-		if lk == "content-length" {
-			continue
-		}
-		// Only drop Content-Encoding if we will modify (decompress/rewrite)
-		if willModify && lk == "content-encoding" {
+		if strings.EqualFold(k, "Content-Length") {
 			continue
 		}
 		for _, vv := range v {
 			w.Header().Add(k, vv)
 		}
 	}
-	// If not modifying, stream raw (keep original encoding)
+	// If not modifying, stream raw (keep original encoding); cacheable
+	// responses are captured on the way through.
 	if !willModify {
+		var capture *captureBuffer
+		if cacheKey != "" {
+			w.Header().Set("X-Cache", "MISS")
+			if cacheTTL = storableTTL(resp, cacheTTL); cacheTTL > 0 {
+				capture = &captureBuffer{max: chain.Cache.MaxEntryKB << 10}
+			}
+		}
 		w.WriteHeader(resp.StatusCode)
-		_, _ = io.Copy(w, resp.Body)
+		var dst io.Writer = w
+		if capture != nil {
+			dst = io.MultiWriter(w, capture)
+		}
+		_, err := io.Copy(dst, resp.Body)
+		if capture != nil && err == nil && !capture.overflow {
+			storeCached(cacheKey, cacheTTL, cacheService(routePrefix), resp, capture.buf.Bytes())
+		}
 		logRequestSummary(r, true, route, host, start)
 		return
 	}
@@ -1457,10 +1851,10 @@ func handler(w http.ResponseWriter, r *http.Request) {
 	// If modifying HTML, transparently handle gzip — set up reader
 	// before committing the status code so error paths can still send 500.
 	var reader io.Reader = resp.Body
-	if strings.Contains(resp.Header.Get("Content-Encoding"), "gzip") {
+	if gzipped {
 		gzReader, err := gzip.NewReader(resp.Body)
 		if err != nil {
-			http.Error(w, "Gzip error", http.StatusInternalServerError)
+			renderError(w, r, http.StatusInternalServerError, "Gzip error", calls)
 			logRequestSummary(r, false, route, host, start)
 			return
 		}
@@ -1484,25 +1878,49 @@ func handler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	// Read, rewrite, inject, respond (limit to 10 MB to prevent OOM)
-	rawHTML, _ := io.ReadAll(io.LimitReader(reader, 10<<20))
-	html := string(rawHTML)
+	// Stream: decode → rewrite links + inject banner (prefer config message,
+	// fallback to file banner) → re-encode in the original encoding.
+	var out io.Writer = w
+	var gzWriter *gzip.Writer
+	if gzipped {
+		gzWriter = gzip.NewWriter(w)
+		out = gzWriter
+	}
+	rw := newHTMLRewriter(out, func(html string) string {
+		return rewriteLinks(html, routePrefix, node.ip, chain.Host, absoluteHost, isRPCvhost)
+	}, bannerText(bannerHTML, bannerFile))
 
-	html = rewriteLinks(html, routePrefix, chain.IP, chain.Host, absoluteHost, isRPCvhost)
+	_, err := io.CopyBuffer(rw, reader, make([]byte, htmlChunkSize))
+	if cerr := rw.Close(); err == nil {
+		err = cerr
+	}
+	if gzWriter != nil {
+		if cerr := gzWriter.Close(); err == nil {
+			err = cerr
+		}
+	}
+	logRequestSummary(r, err == nil, route, host, start)
+}
 
-	if injectHTML {
-		// prefer config message; fallback to file banner
-		if strings.TrimSpace(bannerHTML) != "" {
-			html = injectBannerFromString(html, bannerHTML)
-		} else if bannerFile != "" {
-			if mod, err := injectBannerFile(html, bannerFile); err == nil {
-				html = mod
+// newUpstreamRequest builds the request for one attempt on node n. Retries
+// replay the buffered body (r.GetBody) or send none.
+func newUpstreamRequest(ctx context.Context, r *http.Request, n *backendNode, svc, path string, retry bool) (*http.Request, error) {
+	target := n.url("http", n.port(svc), path)
+	if r.URL.RawQuery != "" {
+		target += "?" + r.URL.RawQuery
+	}
+	body := r.Body
+	if retry {
+		body = http.NoBody
+		if r.GetBody != nil {
+			b, err := r.GetBody()
+			if err != nil {
+				return nil, err
 			}
+			body = b
 		}
 	}
-
-	_, _ = w.Write([]byte(html))
-	logRequestSummary(r, true, route, host, start)
+	return http.NewRequestWithContext(ctx, r.Method, target, body)
 }
 
 // --------------------- BACKUP -------------------
@@ -1512,6 +1930,7 @@ func main() {
 	startMode := false
 	restartSubcmd := false
 	stopSubcmd := false
+	reloadSubcmd := false
 
 	// printHelp is defined early so it can be used before flag.Parse().
 	printHelp := func() {
@@ -1522,9 +1941,11 @@ func main() {
 		fmt.Fprintln(out, "  start                   run in foreground, emit logs to stdout (journalctl friendly)")
 		fmt.Fprintln(out, "  stop                    stop the vProx.service daemon")
 		fmt.Fprintln(out, "  restart                 restart the vProx.service daemon")
+		fmt.Fprintln(out, "  reload                  reload chain configs and ports.toml without restart (SIGHUP)")
 		fmt.Fprintln(out, "")
 		fmt.Fprintln(out, "Flags:")
 		fmt.Fprintln(out, "  --addr string           listen address (default :3000)")
+		fmt.Fprintln(out, "  --admin-addr string     admin API on loopback host:port or unix:/path (env: VPROX_ADMIN_ADDR)")
 		fmt.Fprintln(out, "  --auto-burst int        override auto-quarantine burst (env: VPROX_AUTO_BURST)")
 		fmt.Fprintln(out, "  --auto-rps float        override auto-quarantine RPS (env: VPROX_AUTO_RPS)")
 		fmt.Fprintln(out, "  --burst int             override default burst (env: VPROX_BURST)")
@@ -1534,18 +1955,23 @@ func main() {
 		fmt.Fprintln(out, "  --disable-auto          disable auto-quarantine")
 		fmt.Fprintln(out, "  --disable-backup        disable automatic backup loop and persist to backup.toml")
 		fmt.Fprintln(out, "  --dry-run               load everything but don't start server")
+		fmt.Fprintln(out, "  --grpc-addr string      dedicated h2c listen address for native gRPC (env: VPROX_GRPC_ADDR)")
+		fmt.Fprintln(out, "  --tls-addr string       HTTPS listen address, certs from chain [tls] (env: VPROX_TLS_ADDR)")
 		fmt.Fprintln(out, "  --help                  show this help")
 		fmt.Fprintln(out, "  --home string           override VPROX_HOME")
 		fmt.Fprintln(out, "  --info                  show loaded config summary and exit")
 		fmt.Fprintln(out, "  --list-backup           list available backup archives and exit")
 		fmt.Fprintln(out, "  --log-file string       override main log file path")
+		fmt.Fprintln(out, "  --metrics-addr string   dedicated Prometheus /metrics listener (env: VPROX_METRICS_ADDR)")
 		fmt.Fprintln(out, "  --new-backup            create a new backup archive and exit")
+		fmt.Fprintln(out, "  --otlp-endpoint string  OTLP collector URL, enables tracing (env: VPROX_OTLP_ENDPOINT)")
 		fmt.Fprintln(out, "  --quiet                 suppress non-error output")
 		fmt.Fprintln(out, "  --reset-count           reset persisted access counters (backup)")
 		fmt.Fprintln(out, "  --rps float             override default RPS (env: VPROX_RPS)")
 		fmt.Fprintln(out, "  --backup-status         show backup automation status and next-run ETA")
 		fmt.Fprintln(out, "  --validate              validate configs and exit")
 		fmt.Fprintln(out, "  --verbose               verbose logging output")
+		fmt.Fprintln(out, "  --watch-config          reload configs when chain TOMLs/ports.toml change (env: VPROX_WATCH_CONFIG)")
 		fmt.Fprintln(out, "  --version               show version and exit")
 		fmt.Fprintln(out, "")
 		fmt.Fprintln(out, "Backup output goes to terminal + main.log. When run standalone (not via systemd),")
@@ -1568,6 +1994,9 @@ func main() {
 	case "stop":
 		stopSubcmd = true
 		rawArgs = rawArgs[1:]
+	case "reload":
+		reloadSubcmd = true
+		rawArgs = rawArgs[1:]
 	default:
 		// Unknown bare word (not a flag) → error
 		if !strings.HasPrefix(rawArgs[0], "-") {
@@ -1593,6 +2022,11 @@ func main() {
 	configFlag := flag.String("config", "", "override config directory")
 	chainsFlag := flag.String("chains", "", "override chains directory")
 	addrFlag := flag.String("addr", "", "listen address (default :3000)")
+	grpcAddrFlag := flag.String("grpc-addr", "", "dedicated h2c listen address for native gRPC")
+	tlsAddrFlag := flag.String("tls-addr", "", "HTTPS listen address (certificates from chain [tls] tables)")
+	adminAddrFlag := flag.String("admin-addr", "", "admin API listen address: loopback host:port or unix:/path")
+	metricsAddrFlag := flag.String("metrics-addr", "", "dedicated listen address for Prometheus /metrics")
+	otlpEndpointFlag := flag.String("otlp-endpoint", "", "OTLP collector URL for traces (enables tracing)")
 	logFileFlag := flag.String("log-file", "", "override main log file path")
 	validateFlag := flag.Bool("validate", false, "validate configs and exit")
 	dryRunFlag := flag.Bool("dry-run", false, "load everything but don't start server")
@@ -1606,6 +2040,7 @@ func main() {
 	autoBurstFlag := flag.Int("auto-burst", 0, "override auto-quarantine burst (env: VPROX_AUTO_BURST)")
 	disableAutoFlag := flag.Bool("disable-auto", false, "disable auto-quarantine")
 	disableBackupFlag := flag.Bool("disable-backup", false, "disable automatic backup loop")
+	watchConfigFlag := flag.Bool("watch-config", false, "reload configs when chain TOMLs/ports.toml change")
 
 	flag.Usage = printHelp
 
@@ -1637,6 +2072,15 @@ func main() {
 		return
 	}
 
+	// reload command: delegate to service command (systemd sends SIGHUP)
+	if reloadSubcmd {
+		if err := runServiceCommand("reload"); err != nil {
+			fmt.Fprintf(os.Stderr, "reload failed: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	// --daemon / -d: start service via service command
 	if *daemonFlag || *daemonShortFlag {
 		if err := runServiceCommand("start"); err != nil {
@@ -1745,20 +2189,7 @@ func main() {
 			}
 			applog.Print("INFO", "access", "counter_reset", applog.F("path", accessCountsPath))
 		}
-		bupCfg, bupLoaded, _ := backup.LoadConfig(resolveBackupConfigPath(configDir))
-		listSrc := "default"
-		if bupLoaded {
-			listSrc = "loaded"
-		}
-		extraFiles := resolveBackupExtraFiles(bupCfg, dataDir, logsDir, configDir, mainLogPath)
-		if err := backup.RunOnce(backup.Options{
-			LogPath:    mainLogPath,
-			ArchiveDir: archiveDir,
-			StatePath:  filepath.Join(dataDir, "backup.last"),
-			Method:     "MANUAL",
-			ExtraFiles: extraFiles,
-			ListSource: listSrc,
-		}); err != nil {
+		if err := backup.RunOnce(manualBackupOptions(mainLogPath)); err != nil {
 			log.Fatalf("Backup failed: %v", err)
 		}
 		return
@@ -1769,39 +2200,18 @@ func main() {
 	loadAccessCounts(accessCountsPath)
 	stopCounterTicker := startAccessCountTicker(accessCountsPath)
 
-	// Load configs (TOML only)
-	var loadErr error
-	portsPath := filepath.Join(configDir, "ports.toml")
-	if _, err := os.Stat(portsPath); err != nil {
-		log.Fatalf("ports config missing: %s", portsPath)
-	}
-	defaultPorts, loadErr = loadPorts(portsPath)
+	// Load configs (TOML only) into the initial routing table
+	rt, loadErr := loadRouteTable()
 	if loadErr != nil {
-		log.Fatalf("Could not load default ports: %v", loadErr)
-	}
-
-	// Load chain configs — scan order:
-	//   1. $configDir/chains/ (new structured layout, primary)
-	//   2. $chainsDir (~/.vProx/chains/, legacy primary)
-	//   3. $configDir (flat layout, backward compat — filtered by isChainTOML)
-	foundChains := false
-	for _, scanDir := range []string{chainsConfigDir, chainsDir, configDir} {
-		if !hasChainConfigs(scanDir) {
-			continue
-		}
-		if err := loadChains(scanDir); err != nil {
-			if *validateFlag {
-				log.Printf("[VALIDATE] Error loading chains from %s: %v", scanDir, err)
-				os.Exit(1)
-			}
-			log.Fatalf("Could not load chain configs from %s: %v", scanDir, err)
+		if *validateFlag {
+			log.Printf("[VALIDATE] Error loading configs: %v", loadErr)
+			os.Exit(1)
 		}
-		foundChains = true
-		applog.Print("INFO", "config", "chains_loaded", applog.F("dir", scanDir))
-	}
-	if !foundChains {
-		log.Fatalf("no chain configs found in %s, %s, or %s", chainsConfigDir, chainsDir, configDir)
+		log.Fatalf("Could not load configs: %v", loadErr)
 	}
+	routes.Store(rt)
+	chains := rt.chains
+	defaultPorts := rt.defaultPorts
 
 	// Handle --validate flag: print config summary and exit
 	if *validateFlag {
@@ -1830,7 +2240,7 @@ func main() {
 		log.Println("")
 		log.Printf("Loaded chains: %d", len(chains))
 		for host, ch := range chains {
-			log.Printf("  • %s (%s) @ %s", host, ch.ChainName, ch.IP)
+			log.Printf("  • %s (%s) @ %s", host, ch.ChainName, backendSummary(ch))
 		}
 		log.Println("")
 		log.Printf("Default ports: RPC=%d, REST=%d, gRPC=%d, gRPC-Web=%d, API=%d",
@@ -1840,12 +2250,53 @@ func main() {
 			log.Println("[VERBOSE] Per-chain details:")
 			for host, ch := range chains {
 				log.Printf("  %s:", host)
-				log.Printf("    Services: RPC=%v, REST=%v, WebSocket=%v, gRPC=%v, gRPC-Web=%v",
-					ch.Services.RPC, ch.Services.REST, ch.Services.WebSocket, ch.Services.GRPC, ch.Services.GRPCWeb)
+				log.Printf("    Services: RPC=%v, REST=%v, WebSocket=%v, gRPC=%v, gRPC-Web=%v, gRPC-Web translate=%v",
+					ch.Services.RPC, ch.Services.REST, ch.Services.WebSocket, ch.Services.GRPC, ch.Services.GRPCWeb, ch.Services.GRPCWebTranslate)
 				if !ch.DefaultPorts {
 					log.Printf("    Ports: RPC=%d, REST=%d, gRPC=%d, gRPC-Web=%d",
 						ch.Ports.RPC, ch.Ports.REST, ch.Ports.GRPC, ch.Ports.GRPCWeb)
 				}
+				log.Printf("    Load balance: %s", ch.pool.strategy)
+				for _, n := range ch.pool.nodes {
+					log.Printf("    Backend %s: ip=%s weight=%d archive=%v RPC=%d, REST=%d, gRPC=%d, gRPC-Web=%d",
+						n.name, n.ip, n.weight, n.archive, n.ports.RPC, n.ports.REST, n.ports.GRPC, n.ports.GRPCWeb)
+				}
+				if ch.pool.archive != nil {
+					log.Printf("    Archive: pruning_horizon=%d margin_blocks=%d", ch.Archive.PruningHorizon, ch.Archive.MarginBlocks)
+				}
+				for _, svc := range upstreamServices {
+					log.Printf("    Timeouts %s: %s", svc, describeTimeouts(ch.Timeouts.resolve(svc)))
+				}
+				if ch.Access.active() {
+					log.Printf("    Access: allow=%v deny=%v geo_rules=%d", ch.Access.Allow, ch.Access.Deny, len(ch.Access.Geo))
+				}
+				if ch.CORS.Enabled {
+					log.Printf("    CORS: origins=%v methods=%v credentials=%v max_age=%ds",
+						ch.CORS.AllowOrigins, ch.CORS.AllowMethods, ch.CORS.AllowCredentials, ch.CORS.MaxAgeSec)
+				}
+				if ch.Breaker.Enabled {
+					b := ch.Breaker
+					log.Printf("    Breaker: failures=%d error_rate=%d%% min_requests=%d window=%ds open=%ds probes=%d",
+						b.Failures, b.ErrorRatePercent, b.MinRequests, b.WindowSec, b.OpenSec, b.HalfOpenProbes)
+				}
+				if ch.Retry.Enabled {
+					log.Printf("    Retry: max_attempts=%d on_status=%v budget=%d%%+%d/s",
+						ch.Retry.MaxAttempts, ch.Retry.OnStatus, ch.Retry.BudgetPercent, ch.Retry.BudgetMinPerSec)
+				}
+				if ch.Broadcast.Enabled {
+					log.Printf("    Broadcast: max_nodes=%d sentries=%d", ch.Broadcast.MaxNodes, len(ch.Broadcast.Sentries))
+				}
+				if ch.Mirror.Enabled {
+					log.Printf("    Mirror: %s=%s sample=%v%% max_inflight=%d log_diff=%v",
+						ch.Mirror.Name, ch.Mirror.IP, ch.Mirror.SamplePercent, ch.Mirror.MaxInflight, ch.Mirror.LogDiff)
+				}
+				if ch.Cache.Enabled {
+					log.Printf("    Cache: height_ttl=%ds latest_ttl=%ds max_entry=%dKB rules=%d",
+						ch.Cache.HeightTTLSec, ch.Cache.LatestTTLSec, ch.Cache.MaxEntryKB, len(ch.Cache.Rules))
+				}
+				if f, ok := rt.tlsHosts[strings.ToLower(host)]; ok {
+					log.Printf("    TLS: cert=%s redirect_http=%v", f.cert, ch.TLS.RedirectHTTP)
+				}
 			}
 		}
 		return
@@ -1925,11 +2376,14 @@ func main() {
 			TTL:       time.Duration(autoTTL) * time.Second,
 		}))
 	}
+	limOpts = append(limOpts, limit.WithErrorWriter(writeError), limit.WithKeyFunc(limiterKeyFunc),
+		limit.WithPolicyFunc(limiterPolicyFunc))
 	lim := limit.New(
 		limit.RateSpec{RPS: defaultRPS, Burst: defaultBurst},
 		nil,
 		limOpts...,
 	)
+	limiter = lim
 
 	// Build mux and routes
 	mux := http.NewServeMux()
@@ -1996,28 +2450,34 @@ func main() {
 		}
 	}
 
+	healthChecker = health.New(health.Options{
+		Targets:  healthTargets,
+		OnChange: onHealthChange,
+	})
+
 	mux.HandleFunc("/websocket", ws.HandleWS(ws.Deps{
 		ClientIP:          clientIP,
 		LogRequestSummary: logRequestSummary,
-		BackendWSParams: func(host string) (string, time.Duration, time.Duration, bool) {
+		BackendWSParams: func(host string) (string, time.Duration, time.Duration, func(), bool) {
 			host = normalizeHost(host)
-			ch, ok := chains[host]
+			ch, ok := currentRoutes().chains[host]
 			if !ok || !ch.Services.WebSocket || !ch.Services.RPC {
-				return "", 0, 0, false
+				return "", 0, 0, nil, false
 			}
-			// resolve effective RPC port
-			eff := defaultPorts
-			if !ch.DefaultPorts && ch.Ports.RPC != 0 {
-				eff.RPC = ch.Ports.RPC
+			node := ch.pool.next()
+			if node == nil {
+				return "", 0, 0, nil, false
 			}
-			backendURL := fmt.Sprintf("ws://%s:%d/websocket", ch.IP, eff.RPC)
+			node.acquire()
+			backendURL := node.url("ws", node.ports.RPC, "/websocket")
 			idle := time.Duration(ch.WS.IdleTimeoutSec) * time.Second
 			if idle <= 0 {
 				idle = 3600 * time.Second
 			}
 			hard := time.Duration(ch.WS.MaxLifetimeSec) * time.Second
-			return backendURL, idle, hard, true
+			return backendURL, idle, hard, node.release, true
 		},
+		CheckMessage: wsCheckMessage,
 	}))
 
 	for _, prefix := range []string{rpcPrefix, restPrefix, grpcPrefix, grpcWebPrefix, apiPrefix} {
@@ -2036,33 +2496,217 @@ func main() {
 
 	server := &http.Server{
 		Addr:              addr,
-		Handler:           lim.Middleware(mux),
+		Handler:           withDefaultDeadlines(withAPIKeyStrip(instrument(withCORS(withAccess(withAPIKeys(lim.Middleware(mux))))))),
+		Protocols:         serverProtocols(), // HTTP/1.1 + h2c for native gRPC
 		ReadHeaderTimeout: 5 * time.Second,
-		ReadTimeout:       15 * time.Second,
-		WriteTimeout:      30 * time.Second,
 		IdleTimeout:       120 * time.Second,
+		// No global Read/WriteTimeout: requests start with default deadlines
+		// and the handler replaces them with the route's from the chain
+		// [timeouts], so long downloads and streams are not cut.
+	}
+
+	// Optional dedicated native gRPC listener (h2c). Every request on it is
+	// treated as gRPC, regardless of path prefix.
+	grpcAddr := strings.TrimSpace(os.Getenv("VPROX_GRPC_ADDR"))
+	if *grpcAddrFlag != "" {
+		grpcAddr = *grpcAddrFlag
+	}
+	var grpcServer *http.Server
+	if grpcAddr != "" {
+		grpcServer = &http.Server{
+			Addr:              grpcAddr,
+			Handler:           withDefaultDeadlines(withAPIKeyStrip(instrument(withAccess(withAPIKeys(lim.Middleware(http.HandlerFunc(grpcHandler))))))),
+			Protocols:         serverProtocols(),
+			ReadHeaderTimeout: 5 * time.Second,
+			IdleTimeout:       120 * time.Second,
+		}
+	}
+
+	// Shared response cache (per-chain [cache] decides what is stored).
+	if mb := envInt("VPROX_CACHE_MAX_MB", 64); mb > 0 {
+		respCache = cache.New(int64(mb) << 20)
+	}
+
+	// Optional TLS listener: certificates are picked per SNI from the chain
+	// [tls] tables and re-read from disk when they change.
+	tlsAddr := strings.TrimSpace(os.Getenv("VPROX_TLS_ADDR"))
+	if *tlsAddrFlag != "" {
+		tlsAddr = *tlsAddrFlag
+	}
+	var tlsServer *http.Server
+	var stopCertWatcher func()
+	if tlsAddr != "" {
+		tlsServer = &http.Server{
+			Addr:              tlsAddr,
+			Handler:           withDefaultDeadlines(withAPIKeyStrip(instrument(withCORS(withAccess(withAPIKeys(lim.Middleware(mux))))))),
+			TLSConfig:         tlsServerConfig(),
+			Protocols:         tlsProtocols(), // HTTP/1.1 + HTTP/2 via ALPN
+			ReadHeaderTimeout: 5 * time.Second,
+			IdleTimeout:       120 * time.Second,
+		}
+		if _, port, err := net.SplitHostPort(tlsAddr); err == nil && port != "443" {
+			tlsRedirectPort = port
+		}
+		tlsListening = true
+		stopCertWatcher = startCertWatcher(time.Duration(envInt("VPROX_TLS_RELOAD_SEC", 30)) * time.Second)
+	}
+
+	// Optional OpenTelemetry tracing (OTLP over HTTP or gRPC).
+	otlpEndpoint := firstEnv("VPROX_OTLP_ENDPOINT", "OTEL_EXPORTER_OTLP_ENDPOINT")
+	if *otlpEndpointFlag != "" {
+		otlpEndpoint = *otlpEndpointFlag
+	}
+	if otlpEndpoint != "" {
+		topts := trace.Options{
+			Endpoint:    otlpEndpoint,
+			Protocol:    firstEnv("VPROX_OTLP_PROTOCOL", "OTEL_EXPORTER_OTLP_PROTOCOL"),
+			Headers:     parseHeaderList(firstEnv("VPROX_OTLP_HEADERS", "OTEL_EXPORTER_OTLP_HEADERS")),
+			ServiceName: strings.TrimSpace(os.Getenv("OTEL_SERVICE_NAME")),
+			SampleRatio: envFloat("VPROX_TRACE_SAMPLE", 1),
+		}
+		if err := trace.Init(topts); err != nil {
+			log.Fatalf("Tracing: %v", err)
+		}
+		applog.Print("INFO", "server", "tracing_started",
+			applog.F("endpoint", otlpEndpoint),
+			applog.F("protocol", otlpProtocolName(topts.Protocol)),
+			applog.F("sample", topts.SampleRatio),
+		)
+	}
+
+	// Optional admin API (loopback or Unix socket, bearer token).
+	adminAddr := strings.TrimSpace(os.Getenv("VPROX_ADMIN_ADDR"))
+	if *adminAddrFlag != "" {
+		adminAddr = *adminAddrFlag
+	}
+	var adminServer *http.Server
+	var adminLn net.Listener
+	if adminAddr != "" {
+		token, tokenSrc, err := adminToken(filepath.Join(dataDir, "admin.token"))
+		if err != nil {
+			log.Fatalf("Admin token: %v", err)
+		}
+		adminLn, err = adminListen(adminAddr)
+		if err != nil {
+			log.Fatalf("Admin listener: %v", err)
+		}
+		api := &adminAPI{
+			token:      token,
+			backupOpts: func() backup.Options { return manualBackupOptions(mainLogPath) },
+			backupCfg:  resolveBackupConfigPath(configDir),
+			backupLast: filepath.Join(dataDir, "backup.last"),
+		}
+		adminServer = &http.Server{
+			Handler:           api.handler(),
+			ReadHeaderTimeout: 5 * time.Second,
+			ReadTimeout:       15 * time.Second,
+			WriteTimeout:      10 * time.Minute, // POST /admin/backup/run answers when the backup is done
+			IdleTimeout:       120 * time.Second,
+		}
+		applog.Print("INFO", "server", "admin_started", applog.F("addr", adminAddr), applog.F("token", tokenSrc))
+	}
+
+	// Optional dedicated Prometheus listener (no token; bind it privately).
+	metricsAddr := strings.TrimSpace(os.Getenv("VPROX_METRICS_ADDR"))
+	if *metricsAddrFlag != "" {
+		metricsAddr = *metricsAddrFlag
+	}
+	var metricsServer *http.Server
+	if metricsAddr != "" {
+		metricsMux := http.NewServeMux()
+		metricsMux.Handle("GET /metrics", metrics.Handler())
+		metricsServer = &http.Server{
+			Addr:              metricsAddr,
+			Handler:           metricsMux,
+			ReadHeaderTimeout: 5 * time.Second,
+			ReadTimeout:       15 * time.Second,
+			WriteTimeout:      30 * time.Second,
+			IdleTimeout:       120 * time.Second,
+		}
+	}
+
+	// Hot reload: SIGHUP always, file watcher when requested.
+	hupCh := make(chan os.Signal, 1)
+	signal.Notify(hupCh, syscall.SIGHUP)
+	go func() {
+		for range hupCh {
+			_ = reloadConfig("sighup")
+		}
+	}()
+	// SIGUSR1 dumps backend health and breaker state to the log.
+	usrCh := make(chan os.Signal, 1)
+	signal.Notify(usrCh, syscall.SIGUSR1)
+	go func() {
+		for range usrCh {
+			logBackendStates()
+		}
+	}()
+	var stopWatcher func()
+	if *watchConfigFlag || envBool("VPROX_WATCH_CONFIG") {
+		stopWatcher = startConfigWatcher(time.Duration(envInt("VPROX_WATCH_INTERVAL_SEC", 5)) * time.Second)
+		applog.Print("INFO", "config", "watcher_started", applog.F("dirs", chainsConfigDir))
 	}
 
 	cleanup := func() {
+		signal.Stop(hupCh)
+		if stopWatcher != nil {
+			stopWatcher()
+		}
+		if stopCertWatcher != nil {
+			stopCertWatcher()
+		}
 		stopCounterTicker() // final flush of dirty counters
 		if stopBackup != nil {
 			stopBackup()
 		}
+		healthChecker.Close()
 		_ = lim.Close()
 		geo.Close()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		_ = trace.Shutdown(shutdownCtx) // flush queued spans
+		cancel()
 		closeChainLoggers()
 	}
 
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer stop()
 
-	errCh := make(chan error, 1)
+	errCh := make(chan error, 5)
 	go func() {
 		errCh <- server.ListenAndServe()
 	}()
+	if grpcServer != nil {
+		go func() {
+			errCh <- grpcServer.ListenAndServe()
+		}()
+	}
+	if tlsServer != nil {
+		go func() {
+			errCh <- tlsServer.ListenAndServeTLS("", "")
+		}()
+	}
+	if adminServer != nil {
+		go func() {
+			errCh <- adminServer.Serve(adminLn)
+		}()
+	}
+	if metricsServer != nil {
+		go func() {
+			errCh <- metricsServer.ListenAndServe()
+		}()
+	}
 
 	// Start server wrapped by limiter middleware
 	applog.Print("INFO", "server", "started", applog.F("addr", addr))
+	if grpcServer != nil {
+		applog.Print("INFO", "server", "grpc_started", applog.F("addr", grpcAddr))
+	}
+	if tlsServer != nil {
+		applog.Print("INFO", "server", "tls_started", applog.F("addr", tlsAddr), applog.F("hosts", len(currentRoutes().tlsHosts)))
+	}
+	if metricsServer != nil {
+		applog.Print("INFO", "server", "metrics_started", applog.F("addr", metricsAddr))
+	}
 
 	select {
 	case err := <-errCh:
@@ -2077,6 +2721,26 @@ func main() {
 		if err := server.Shutdown(ctxTimeout); err != nil {
 			applog.Print("ERROR", "server", "shutdown_error", applog.F("error", err.Error()))
 		}
+		if grpcServer != nil {
+			if err := grpcServer.Shutdown(ctxTimeout); err != nil {
+				applog.Print("ERROR", "server", "shutdown_error", applog.F("error", err.Error()))
+			}
+		}
+		if tlsServer != nil {
+			if err := tlsServer.Shutdown(ctxTimeout); err != nil {
+				applog.Print("ERROR", "server", "shutdown_error", applog.F("error", err.Error()))
+			}
+		}
+		if adminServer != nil {
+			if err := adminServer.Shutdown(ctxTimeout); err != nil {
+				applog.Print("ERROR", "server", "shutdown_error", applog.F("error", err.Error()))
+			}
+		}
+		if metricsServer != nil {
+			if err := metricsServer.Shutdown(ctxTimeout); err != nil {
+				applog.Print("ERROR", "server", "shutdown_error", applog.F("error", err.Error()))
+			}
+		}
 		cleanup()
 	}
 }
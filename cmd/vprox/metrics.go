@@ -0,0 +1,220 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	applog "github.com/vNodesV/vProx/internal/logging"
+	"github.com/vNodesV/vProx/internal/metrics"
+	"github.com/vNodesV/vProx/internal/trace"
+)
+
+// --------------------- METRICS & TRACING ---------------------
+
+// Prometheus metrics of the proxy itself; internal packages (limit, ws, geo,
+// backup) register their own. Served at /metrics on the admin listener
+// (token required) and on --metrics-addr. Spans come from the same hooks.
+
+var (
+	requestsTotal = metrics.NewCounter("vprox_requests_total",
+		"Requests by chain, route and HTTP status. chain is \"-\" for unknown hosts.", "chain", "route", "code")
+	requestSeconds = metrics.NewHistogram("vprox_request_duration_seconds",
+		"Request latency by chain and route (WebSocket sessions excluded).", metrics.DefBuckets, "chain", "route")
+	upstreamSeconds = metrics.NewHistogram("vprox_upstream_duration_seconds",
+		"Time to upstream response headers by backend and service.", metrics.DefBuckets, "chain", "backend", "service")
+	upstreamResponses = metrics.NewCounter("vprox_upstream_responses_total",
+		"Upstream responses by backend and status (\"error\" = no response).", "chain", "backend", "code")
+)
+
+func init() {
+	metrics.NewGaugeFunc("vprox_backend_inflight", "Requests in flight per backend.", func(emit metrics.Emit) {
+		for _, c := range currentRoutes().uniqueChains() {
+			if c.pool == nil {
+				continue
+			}
+			for _, n := range c.pool.nodes {
+				emit(float64(n.inflight.Load()), c.ChainName, n.name)
+			}
+		}
+	}, "chain", "backend")
+	metrics.NewGaugeFunc("vprox_backend_up", "1 when the backend is healthy and its breaker admits requests.", func(emit metrics.Emit) {
+		for _, c := range currentRoutes().uniqueChains() {
+			if c.pool == nil {
+				continue
+			}
+			for _, n := range c.pool.nodes {
+				up := 0.0
+				if n.usable() {
+					up = 1
+				}
+				emit(up, c.ChainName, n.name)
+			}
+		}
+	}, "chain", "backend")
+	metrics.NewGaugeFunc("vprox_limiter_overrides", "Active per-IP rate overrides by kind (manual, quarantine).", func(emit metrics.Emit) {
+		if limiter == nil {
+			return
+		}
+		var manual, auto int
+		for _, o := range limiter.Overrides() {
+			if o.Auto {
+				auto++
+			} else {
+				manual++
+			}
+		}
+		emit(float64(manual), "manual")
+		emit(float64(auto), "quarantine")
+	}, "kind")
+	metrics.NewCounterFunc("vprox_cache_requests_total", "Response cache lookups by result.", func(emit metrics.Emit) {
+		if respCache == nil {
+			return
+		}
+		s := respCache.Stats()
+		emit(float64(s.Hits), "hit")
+		emit(float64(s.Misses), "miss")
+	}, "result")
+	metrics.NewGaugeFunc("vprox_cache_bytes", "Bytes held by the response cache.", func(emit metrics.Emit) {
+		if respCache != nil {
+			emit(float64(respCache.Stats().Bytes))
+		}
+	})
+}
+
+// instrument records vprox_requests_total and the request latency for every
+// request of a listener, including those the limiter refuses, and wraps it in
+// a server span (child of an incoming traceparent). The route is the one
+// logRequestSummary logged ("limiter" for refused requests).
+func instrument(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		r = withLogNotes(r)
+		var span *trace.Span
+		if trace.Enabled() {
+			var ctx context.Context
+			ctx, span = trace.Start(trace.Extract(r.Context(), r.Header), r.Method, trace.KindServer,
+				trace.A("http.request.method", r.Method),
+				trace.A("url.path", r.URL.Path),
+				trace.A("server.address", normalizeHost(r.Host)),
+				trace.A("client.address", clientIP(r)),
+			)
+			r = r.WithContext(ctx)
+		}
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+
+		chain := "-"
+		if c, ok := currentRoutes().chains[normalizeHost(r.Host)]; ok {
+			chain = c.ChainName
+		}
+		code := rec.code
+		if code == 0 {
+			code = http.StatusOK
+		}
+		route := logRouteOf(r)
+		if route == "" {
+			route = "-"
+			if code == http.StatusTooManyRequests {
+				route = "limiter"
+			}
+		}
+		requestsTotal.Inc(chain, route, strconv.Itoa(code))
+		if route != "websocket" {
+			requestSeconds.Observe(time.Since(start).Seconds(), chain, route)
+		}
+		if span != nil {
+			span.SetName(r.Method + " " + route)
+			span.SetAttr("vprox.chain", chain)
+			span.SetAttr("vprox.route", route)
+			span.SetAttr("vprox.request_id", r.Header.Get(applog.RequestIDHeader))
+			span.SetAttr("http.response.status_code", code)
+			if code >= 500 {
+				span.SetError(http.StatusText(code))
+			}
+			span.End()
+		}
+	})
+}
+
+// statusRecorder remembers the status code written to the client.
+type statusRecorder struct {
+	http.ResponseWriter
+	code int
+}
+
+func (s *statusRecorder) WriteHeader(code int) {
+	if s.code == 0 && code >= 200 {
+		s.code = code
+	}
+	s.ResponseWriter.WriteHeader(code)
+}
+
+func (s *statusRecorder) Write(b []byte) (int, error) {
+	if s.code == 0 {
+		s.code = http.StatusOK
+	}
+	return s.ResponseWriter.Write(b)
+}
+
+func (s *statusRecorder) Flush() {
+	if s.code == 0 {
+		s.code = http.StatusOK
+	}
+	_ = http.NewResponseController(s.ResponseWriter).Flush()
+}
+
+// Hijack is used by the WebSocket upgrade (gorilla asserts http.Hijacker).
+func (s *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := s.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("hijack not supported")
+	}
+	if s.code == 0 {
+		s.code = http.StatusSwitchingProtocols
+	}
+	return h.Hijack()
+}
+
+func (s *statusRecorder) Unwrap() http.ResponseWriter { return s.ResponseWriter }
+
+// observedTransport times each upstream round trip (to response headers),
+// counts the outcome per backend and, when tracing, sends it as a client
+// span whose context is forwarded in traceparent.
+type observedTransport struct {
+	base                *http.Transport
+	chain, backend, svc string
+}
+
+func (t *observedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, span := trace.Start(req.Context(), "upstream "+t.svc, trace.KindClient,
+		trace.A("vprox.chain", t.chain),
+		trace.A("vprox.backend", t.backend),
+		trace.A("server.address", req.URL.Host),
+		trace.A("http.request.method", req.Method),
+	)
+	if span != nil {
+		req = req.Clone(ctx) // a RoundTripper must not modify the caller's request
+		trace.Inject(ctx, req.Header)
+	}
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	upstreamSeconds.Observe(time.Since(start).Seconds(), t.chain, t.backend, t.svc)
+	code := "error"
+	if err == nil {
+		code = strconv.Itoa(resp.StatusCode)
+		span.SetAttr("http.response.status_code", resp.StatusCode)
+		if resp.StatusCode >= 500 {
+			span.SetError(resp.Status)
+		}
+	} else {
+		span.SetError(err.Error())
+	}
+	upstreamResponses.Inc(t.chain, t.backend, code)
+	span.End()
+	return resp, err
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	applog "github.com/vNodesV/vProx/internal/logging"
+	"github.com/vNodesV/vProx/internal/metrics"
+)
+
+// --------------------- TRAFFIC MIRROR ---------------------
+
+// MirrorCfg sends a copy of a sample of a chain's traffic to a shadow node
+// ([mirror]), e.g. one running a new binary before an upgrade. Only requests
+// [retry] would consider safe to repeat are mirrored (GET/HEAD and read-only
+// JSON-RPC); mirror responses are discarded.
+type MirrorCfg struct {
+	Enabled       bool    `toml:"enabled"`
+	Name          string  `toml:"name"` // label in logs and metrics (default: ip)
+	IP            string  `toml:"ip"`
+	Ports         Ports   `toml:"ports"`          // zero ports inherit the chain's effective ports
+	SamplePercent float64 `toml:"sample_percent"` // share of eligible requests mirrored, (0, 100]
+	MaxInflight   int     `toml:"max_inflight"`   // concurrent copies; more are dropped (default 32)
+	LogDiff       bool    `toml:"log_diff"`       // log status/latency of primary vs mirror per copy
+}
+
+// mirrorDefaultTimeout bounds a copy when the chain has no total timeout.
+const mirrorDefaultTimeout = 30 * time.Second
+
+var mirrorRequests = metrics.NewCounter("vprox_mirror_requests_total",
+	"Mirrored request copies by result (ok, error, dropped).", "chain", "mirror", "result")
+
+func validateMirror(c *ChainConfig) error {
+	m := &c.Mirror
+	if !m.Enabled {
+		return nil
+	}
+	m.IP = strings.TrimSpace(m.IP)
+	if net.ParseIP(m.IP) == nil {
+		return fmt.Errorf("mirror: invalid ip: %q", m.IP)
+	}
+	m.Name = strings.TrimSpace(m.Name)
+	if m.Name == "" {
+		m.Name = m.IP
+	}
+	if m.SamplePercent <= 0 || m.SamplePercent > 100 {
+		return fmt.Errorf("mirror.sample_percent must be in (0, 100], got %v", m.SamplePercent)
+	}
+	if m.MaxInflight < 0 {
+		return errors.New("mirror.max_inflight must be >= 0")
+	}
+	if m.MaxInflight == 0 {
+		m.MaxInflight = 32
+	}
+	for _, p := range []struct {
+		label string
+		v     int
+	}{{"rpc", m.Ports.RPC}, {"rest", m.Ports.REST}, {"grpc", m.Ports.GRPC}, {"grpc_web", m.Ports.GRPCWeb}, {"api", m.Ports.API}} {
+		if p.v == 0 {
+			continue
+		}
+		if err := validatePortsLabel(p.label, p.v); err != nil {
+			return fmt.Errorf("mirror: %w", err)
+		}
+	}
+	return nil
+}
+
+// mirrorTarget is the shadow node of a chain. The node is built like a pool
+// member (own transports, chain timeouts) but never serves clients.
+type mirrorTarget struct {
+	chain   string
+	name    string
+	node    *backendNode
+	percent float64
+	logDiff bool
+	slots   chan struct{}
+}
+
+func newMirrorTarget(c *ChainConfig, defaults Ports) *mirrorTarget {
+	m := c.Mirror
+	if !m.Enabled {
+		return nil
+	}
+	label := "mirror:" + m.Name
+	return &mirrorTarget{
+		chain: c.ChainName,
+		name:  m.Name,
+		node: &backendNode{
+			key:       c.ChainName + "/" + label,
+			name:      label,
+			ip:        m.IP,
+			ports:     overlayPorts(effectivePorts(c, defaults), m.Ports),
+			weight:    1,
+			upstreams: newUpstreams(c, label),
+		},
+		percent: m.SamplePercent,
+		logDiff: m.LogDiff,
+		slots:   make(chan struct{}, m.MaxInflight),
+	}
+}
+
+// mirrorResult is one side of a comparison; status 0 means no response.
+type mirrorResult struct {
+	status  int
+	latency time.Duration
+	err     error
+}
+
+// mirrorCopy is an in-flight copy waiting for the primary's result.
+type mirrorCopy struct {
+	primary chan mirrorResult
+}
+
+// done hands the primary's result to the copy. Safe on nil.
+func (mc *mirrorCopy) done(res mirrorResult) {
+	if mc == nil {
+		return
+	}
+	select {
+	case mc.primary <- res:
+	default:
+	}
+}
+
+// start samples r and, if picked, sends a copy to the shadow node in the
+// background. It never blocks: when max_inflight copies are running the
+// copy is dropped. The result is nil when nothing was sent.
+func (m *mirrorTarget) start(r *http.Request, svc, path string, calls rpcCalls) *mirrorCopy {
+	if m == nil || !retryable(r, calls) || rand.Float64()*100 >= m.percent {
+		return nil
+	}
+	select {
+	case m.slots <- struct{}{}:
+	default:
+		mirrorRequests.Inc(m.chain, m.name, "dropped")
+		return nil
+	}
+
+	// Everything the copy needs is taken from r now; r is not touched once
+	// the handler returns.
+	target := m.node.url("http", m.node.port(svc), path)
+	if r.URL.RawQuery != "" {
+		target += "?" + r.URL.RawQuery
+	}
+	var body io.ReadCloser = http.NoBody
+	if r.GetBody != nil {
+		b, err := r.GetBody()
+		if err != nil {
+			<-m.slots
+			return nil
+		}
+		body = b
+	}
+	header := r.Header.Clone()
+	header.Set("X-Forwarded-Host", normalizeHost(r.Host))
+	if header.Get("X-Forwarded-For") == "" {
+		header.Set("X-Forwarded-For", clientIP(r))
+	}
+	header.Set("X-Vprox-Mirror", "1")
+	method, requestID := r.Method, applog.RequestIDFrom(r)
+	timeout := m.node.upstream(svc).timeouts.total
+	if timeout <= 0 {
+		timeout = mirrorDefaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(r.Context()), timeout)
+
+	mc := &mirrorCopy{primary: make(chan mirrorResult, 1)}
+	go func() {
+		defer func() { <-m.slots }()
+		defer cancel()
+		res := m.send(ctx, svc, method, target, header, body)
+		if res.err != nil {
+			mirrorRequests.Inc(m.chain, m.name, "error")
+		} else {
+			mirrorRequests.Inc(m.chain, m.name, "ok")
+		}
+		if !m.logDiff {
+			return
+		}
+		var primary mirrorResult
+		select {
+		case primary = <-mc.primary:
+		case <-ctx.Done():
+			primary.err = errors.New("no primary result")
+		}
+		m.logComparison(requestID, method, path, primary, res)
+	}()
+	return mc
+}
+
+// send performs the copy and discards the response body.
+func (m *mirrorTarget) send(ctx context.Context, svc, method, target string, header http.Header, body io.ReadCloser) mirrorResult {
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return mirrorResult{err: err}
+	}
+	req.Header = header
+	start := time.Now()
+	resp, err := m.node.upstream(svc).client.Do(req)
+	res := mirrorResult{latency: time.Since(start), err: err}
+	if err == nil {
+		res.status = resp.StatusCode
+		_, _ = io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+	return res
+}
+
+// logComparison logs primary vs mirror for one copy: WARN when the statuses
+// differ or either side failed, INFO otherwise.
+func (m *mirrorTarget) logComparison(requestID, method, path string, primary, mirror mirrorResult) {
+	level := "INFO"
+	if primary.status != mirror.status || primary.err != nil || mirror.err != nil {
+		level = "WARN"
+	}
+	fields := []applog.Field{
+		applog.F("request_id", requestID),
+		applog.F("chain", m.chain),
+		applog.F("mirror", m.name),
+		applog.F("method", method),
+		applog.F("path", path),
+		applog.F("primary_status", statusLabel(primary)),
+		applog.F("mirror_status", statusLabel(mirror)),
+		applog.F("primary_ms", primary.latency.Milliseconds()),
+		applog.F("mirror_ms", mirror.latency.Milliseconds()),
+		applog.F("delta_ms", (mirror.latency - primary.latency).Milliseconds()),
+	}
+	if mirror.err != nil {
+		fields = append(fields, applog.F("error", mirror.err.Error()))
+	}
+	applog.Print(level, "mirror", "compare", fields...)
+}
+
+func statusLabel(r mirrorResult) string {
+	if r.status == 0 {
+		return "error"
+	}
+	return fmt.Sprint(r.status)
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// mirrorCopyReq is what the shadow node received.
+type mirrorCopyReq struct {
+	method, uri, body, flag string
+}
+
+// testMirror starts a shadow node that reports every copy on the returned
+// channel once release lets it answer, and a mirror target pointing at it.
+func testMirror(t *testing.T, percent float64, maxInflight int, release <-chan struct{}) (*mirrorTarget, <-chan mirrorCopyReq) {
+	t.Helper()
+	got := make(chan mirrorCopyReq, 16)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		if release != nil {
+			<-release
+		}
+		got <- mirrorCopyReq{r.Method, r.RequestURI, string(b), r.Header.Get("X-Vprox-Mirror")}
+	}))
+	t.Cleanup(srv.Close)
+	port, _ := strconv.Atoi(srv.URL[strings.LastIndex(srv.URL, ":")+1:])
+	c := &ChainConfig{ChainName: "test", Mirror: MirrorCfg{
+		Enabled: true, IP: "127.0.0.1", Ports: Ports{RPC: port, REST: port},
+		SamplePercent: percent, MaxInflight: maxInflight,
+	}}
+	if err := validateMirror(c); err != nil {
+		t.Fatal(err)
+	}
+	return newMirrorTarget(c, Ports{RPC: 26657, REST: 1317}), got
+}
+
+func mirrorRequest(t *testing.T, method, target, body string) (*http.Request, rpcCalls) {
+	t.Helper()
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	calls, err := inspectRPC(r, strings.TrimPrefix(r.URL.Path, "/"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r, calls
+}
+
+func TestMirrorSampling(t *testing.T) {
+	tests := []struct {
+		name    string
+		percent float64
+		method  string
+		body    string
+		want    bool
+	}{
+		{"all", 100, "GET", "", true},
+		{"none sampled", 1e-9, "GET", "", false},
+		{"not retryable", 100, "POST", `{"jsonrpc":"2.0","id":1,"method":"broadcast_tx_sync","params":{}}`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, got := testMirror(t, tt.percent, 0, nil)
+			for range 20 {
+				r, calls := mirrorRequest(t, tt.method, "/", tt.body)
+				mc := m.start(r, svcRPC, "/status", calls)
+				if (mc != nil) != tt.want {
+					t.Fatalf("copy sent = %v, want %v", mc != nil, tt.want)
+				}
+				if mc != nil {
+					<-got
+				}
+			}
+		})
+	}
+}
+
+func TestMirrorMaxInflight(t *testing.T) {
+	release := make(chan struct{})
+	m, got := testMirror(t, 100, 1, release)
+	r, calls := mirrorRequest(t, "GET", "/status", "")
+	if m.start(r, svcRPC, "/status", calls) == nil {
+		t.Fatal("first copy not sent")
+	}
+	if m.start(r, svcRPC, "/status", calls) != nil {
+		t.Fatal("copy past max_inflight not dropped")
+	}
+	close(release)
+	<-got
+	deadline := time.Now().Add(5 * time.Second)
+	for len(m.slots) != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("slot not released after the copy finished")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if m.start(r, svcRPC, "/status", calls) == nil {
+		t.Fatal("copy not sent once a slot was free")
+	}
+	<-got
+}
+
+func TestMirrorBodyReplay(t *testing.T) {
+	m, got := testMirror(t, 100, 0, nil)
+	body := `{"jsonrpc":"2.0","id":7,"method":"abci_query","params":{"path":"/a"}}`
+	r, calls := mirrorRequest(t, "POST", "/?trace=1", body)
+	mc := m.start(r, svcRPC, "/", calls)
+	if mc == nil {
+		t.Fatal("copy not sent")
+	}
+	mc.done(mirrorResult{status: http.StatusOK})
+
+	// The primary still reads the whole body.
+	if b, _ := io.ReadAll(r.Body); string(b) != body {
+		t.Errorf("primary body = %q, want %q", b, body)
+	}
+	c := <-got
+	if c.method != "POST" || c.uri != "/?trace=1" || c.body != body || c.flag != "1" {
+		t.Errorf("copy = %+v", c)
+	}
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/vNodesV/vProx/internal/apikey"
+	applog "github.com/vNodesV/vProx/internal/logging"
+)
+
+// --------------------- ROUTING TABLE & HOT RELOAD ---------------------
+
+// routeTable is an immutable snapshot of ports.toml, every chain config, the
+// API keys file and the global access lists.
+// Request handlers read the current snapshot via currentRoutes() and never
+// mutate it; a reload builds a fresh table and swaps it in atomically, so
+// in-flight requests and WebSocket sessions keep the table they started with.
+type routeTable struct {
+	chains       map[string]*ChainConfig // normalized host -> chain
+	tlsHosts     map[string]tlsFiles     // host -> cert/key served for its SNI
+	defaultPorts Ports
+	apiKeys      *apikey.Set // nil: no keys file
+	access       *AccessCfg  // global [access]; nil: no access.toml
+	loadedAt     time.Time
+}
+
+var (
+	routes   atomic.Pointer[routeTable]
+	reloadMu sync.Mutex // serializes reloads (SIGHUP + watcher)
+)
+
+// currentRoutes returns the active routing table.
+func currentRoutes() *routeTable {
+	if rt := routes.Load(); rt != nil {
+		return rt
+	}
+	return &routeTable{chains: map[string]*ChainConfig{}, tlsHosts: map[string]tlsFiles{}}
+}
+
+// loadRouteTable runs loadPorts/loadChains/validateConfig into a new table.
+// Chain configs are scanned in order:
+//  1. $configDir/chains/ (new structured layout, primary)
+//  2. $chainsDir (~/.vProx/chains/, legacy primary)
+//  3. $configDir (flat layout, backward compat — filtered by isChainTOML)
+func loadRouteTable() (*routeTable, error) {
+	rt := &routeTable{chains: make(map[string]*ChainConfig), tlsHosts: make(map[string]tlsFiles), loadedAt: time.Now()}
+
+	portsPath := filepath.Join(configDir, "ports.toml")
+	if _, err := os.Stat(portsPath); err != nil {
+		return nil, fmt.Errorf("ports config missing: %s", portsPath)
+	}
+	p, err := loadPorts(portsPath)
+	if err != nil {
+		return nil, fmt.Errorf("could not load default ports: %w", err)
+	}
+	rt.defaultPorts = p
+
+	found := false
+	for _, scanDir := range []string{chainsConfigDir, chainsDir, configDir} {
+		if !hasChainConfigs(scanDir) {
+			continue
+		}
+		if err := rt.loadChains(scanDir); err != nil {
+			return nil, fmt.Errorf("chain configs in %s: %w", scanDir, err)
+		}
+		found = true
+		applog.Print("INFO", "config", "chains_loaded", applog.F("dir", scanDir))
+	}
+	if !found {
+		return nil, fmt.Errorf("no chain configs found in %s, %s, or %s", chainsConfigDir, chainsDir, configDir)
+	}
+	if err := rt.loadAPIKeys(apiKeysPath()); err != nil {
+		return nil, err
+	}
+	if rt.access, err = loadAccess(accessPath()); err != nil {
+		return nil, fmt.Errorf("access lists: %w", err)
+	}
+	return rt, nil
+}
+
+// loadAPIKeys loads the keys file and checks that every chain a key is
+// scoped to exists.
+func (rt *routeTable) loadAPIKeys(path string) error {
+	keys, err := apikey.Load(path)
+	if err != nil {
+		return fmt.Errorf("api keys %s: %w", path, err)
+	}
+	if keys == nil {
+		return nil
+	}
+	names := make(map[string]bool)
+	for _, c := range rt.uniqueChains() {
+		names[strings.ToLower(c.ChainName)] = true
+	}
+	for _, k := range keys.Keys() {
+		for _, c := range k.Chains {
+			if !names[strings.ToLower(c)] {
+				return fmt.Errorf("api keys %s: key %q: unknown chain %q", path, k.ID, c)
+			}
+		}
+	}
+	rt.apiKeys = keys
+	applog.Print("INFO", "config", "api_keys_loaded", applog.F("file", path), applog.F("keys", len(keys.Keys())))
+	return nil
+}
+
+// reloadConfig rebuilds the routing table and swaps it in. On any load or
+// validation error the running table stays in place and the error is logged.
+func reloadConfig(trigger string) error {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	rt, err := loadRouteTable()
+	if err != nil {
+		applog.Print("ERROR", "config", "reload_failed",
+			applog.F("trigger", trigger),
+			applog.F("error", err.Error()),
+		)
+		return err
+	}
+	seedHealthState(rt)
+	carryBreakers(currentRoutes(), rt)
+	old := routes.Swap(rt)
+	if old != nil {
+		// In-flight requests keep their connections; only idle ones go.
+		old.closeIdleConnections()
+	}
+	applog.Print("INFO", "config", "reloaded",
+		applog.F("trigger", trigger),
+		applog.F("chains", len(rt.uniqueChains())),
+		applog.F("hosts", len(rt.chains)),
+	)
+	return nil
+}
+
+// uniqueChains returns each chain of the table once (chains is keyed by every
+// registered host, so the same config appears several times).
+func (rt *routeTable) uniqueChains() []*ChainConfig {
+	seen := make(map[*ChainConfig]bool)
+	var out []*ChainConfig
+	for _, c := range rt.chains {
+		if !seen[c] {
+			seen[c] = true
+			out = append(out, c)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ChainName < out[j].ChainName })
+	return out
+}
+
+// closeIdleConnections releases the idle upstream connections of every node
+// in the table (called on the table a reload replaced).
+func (rt *routeTable) closeIdleConnections() {
+	for _, c := range rt.uniqueChains() {
+		if c.pool == nil {
+			continue
+		}
+		for _, n := range c.pool.nodes {
+			for _, u := range n.upstreams {
+				u.transport.CloseIdleConnections()
+			}
+		}
+	}
+}
+
+// startConfigWatcher polls the config directories every interval and reloads
+// when a chain TOML, ports.toml, the API keys file or access.toml is added,
+// removed or modified. Returns a stop function.
+func startConfigWatcher(interval time.Duration) func() {
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+	last := configFingerprint()
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				cur := configFingerprint()
+				if cur == last {
+					continue
+				}
+				last = cur
+				_ = reloadConfig("watch")
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		ticker.Stop()
+		close(done)
+	}
+}
+
+// configFingerprint summarizes name/size/mtime of every file loadRouteTable
+// reads, so the watcher can detect changes without an fsnotify dependency.
+func configFingerprint() string {
+	var b strings.Builder
+	add := func(path string) {
+		if fi, err := os.Stat(path); err == nil {
+			fmt.Fprintf(&b, "%s|%d|%d\n", path, fi.Size(), fi.ModTime().UnixNano())
+		}
+	}
+	add(filepath.Join(configDir, "ports.toml"))
+	add(apiKeysPath())
+	add(accessPath())
+	for _, dir := range []string{chainsConfigDir, chainsDir, configDir} {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			if !errors.Is(err, os.ErrNotExist) {
+				fmt.Fprintf(&b, "%s|err\n", dir)
+			}
+			continue
+		}
+		for _, e := range entries {
+			if !e.IsDir() && isChainTOML(e.Name()) {
+				add(filepath.Join(dir, e.Name()))
+			}
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+)
+
+// --------------------- RETRY & FAILOVER ---------------------
+
+// RetryCfg configures per-chain retries of idempotent requests ([retry]).
+// Only GET/HEAD without a body and read-only JSON-RPC calls are retried, and
+// always on a different backend.
+type RetryCfg struct {
+	Enabled         bool  `toml:"enabled"`
+	MaxAttempts     int   `toml:"max_attempts"`       // incl. the first try (default 2)
+	OnStatus        []int `toml:"on_status"`          // upstream statuses that trigger a retry (default 502, 503, 504)
+	BudgetPercent   int   `toml:"budget_percent"`     // retries per 10 s window as % of requests (default 20)
+	BudgetMinPerSec int   `toml:"budget_min_per_sec"` // retries always allowed regardless of traffic (default 1)
+}
+
+// retryBudgetWindow is the accounting window of the retry budget.
+const retryBudgetWindow = 10 * time.Second
+
+func validateRetry(c *ChainConfig) error {
+	rc := &c.Retry
+	if rc.MaxAttempts < 0 || rc.BudgetPercent < 0 || rc.BudgetMinPerSec < 0 {
+		return errors.New("retry: max_attempts, budget_percent and budget_min_per_sec must be >= 0")
+	}
+	if rc.MaxAttempts == 0 {
+		rc.MaxAttempts = 2
+	}
+	if rc.OnStatus == nil {
+		rc.OnStatus = []int{http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout}
+	}
+	for _, s := range rc.OnStatus {
+		if s < 500 || s > 599 {
+			return fmt.Errorf("retry.on_status: %d is not a 5xx status", s)
+		}
+	}
+	if rc.BudgetPercent == 0 {
+		rc.BudgetPercent = 20
+	}
+	if rc.BudgetMinPerSec == 0 {
+		rc.BudgetMinPerSec = 1
+	}
+	return nil
+}
+
+// retryBudget caps retries to a share of the chain's traffic so a failing
+// backend cannot multiply load on the others: within each window, retries
+// may not exceed BudgetMinPerSec*window + BudgetPercent% of requests.
+type retryBudget struct {
+	percent int
+	minimum int
+
+	mu       sync.Mutex
+	start    time.Time
+	requests int
+	retries  int
+}
+
+func newRetryBudget(c RetryCfg) *retryBudget {
+	return &retryBudget{
+		percent: c.BudgetPercent,
+		minimum: c.BudgetMinPerSec * int(retryBudgetWindow/time.Second),
+	}
+}
+
+func (b *retryBudget) rollLocked(now time.Time) {
+	if now.Sub(b.start) >= retryBudgetWindow {
+		b.start, b.requests, b.retries = now, 0, 0
+	}
+}
+
+// request records one proxied request of the chain (its first attempt).
+func (b *retryBudget) request() {
+	b.mu.Lock()
+	b.rollLocked(time.Now())
+	b.requests++
+	b.mu.Unlock()
+}
+
+// take reserves one retry and reports whether the budget allowed it.
+func (b *retryBudget) take() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.rollLocked(time.Now())
+	if b.retries >= b.minimum+b.requests*b.percent/100 {
+		return false
+	}
+	b.retries++
+	return true
+}
+
+// retryable reports whether r may be sent again: GET/HEAD without a body,
+// or a JSON-RPC POST whose buffered body can be replayed and whose calls are
+// all read-only.
+func retryable(r *http.Request, calls rpcCalls) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		return r.ContentLength == 0
+	case http.MethodPost:
+		if calls.URI || len(calls.Calls) == 0 || r.GetBody == nil {
+			return false
+		}
+		for _, c := range calls.Calls {
+			if !rpcReadOnly(c.Method) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// rpcMutatingMethods change node state or its peer set; they are never
+// retried (a retried broadcast could be accepted twice).
+var rpcMutatingMethods = []string{"broadcast_tx_*", "broadcast_evidence", "unsafe_*", "dial_*"}
+
+func rpcReadOnly(method string) bool {
+	return method != "" && !matchMethod(rpcMutatingMethods, method)
+}
+
+// retryError reports whether a transport error is worth another backend:
+// connect failures and upstream timeouts.
+func retryError(err error) bool {
+	if isTimeout(err) {
+		return true
+	}
+	var op *net.OpError
+	return errors.As(err, &op) && op.Op == "dial"
+}
+
+func (c *RetryCfg) retryStatus(status int) bool {
+	return slices.Contains(c.OnStatus, status)
+}
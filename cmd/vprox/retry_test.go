@@ -0,0 +1,112 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRPCReadOnly(t *testing.T) {
+	tests := []struct {
+		method string
+		want   bool
+	}{
+		{"status", true},
+		{"abci_query", true},
+		{"broadcast_tx_sync", false},
+		{"broadcast_tx_commit", false},
+		{"broadcast_evidence", false},
+		{"unsafe_flush_mempool", false},
+		{"dial_peers", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := rpcReadOnly(tt.method); got != tt.want {
+			t.Errorf("rpcReadOnly(%q) = %v, want %v", tt.method, got, tt.want)
+		}
+	}
+}
+
+func TestRetryable(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		target string
+		body   string
+		want   bool
+	}{
+		{"get", "GET", "/status", "", true},
+		{"head", "HEAD", "/status", "", true},
+		{"get with body", "GET", "/status", "x", false},
+		{"uri call", "POST", "/status", "", false},
+		{"read-only call", "POST", "/", `{"jsonrpc":"2.0","id":1,"method":"block","params":{}}`, true},
+		{"read-only batch", "POST", "/", `[{"jsonrpc":"2.0","id":1,"method":"block"},{"jsonrpc":"2.0","id":2,"method":"status"}]`, true},
+		{"batch with a broadcast", "POST", "/", `[{"jsonrpc":"2.0","id":1,"method":"block"},{"jsonrpc":"2.0","id":2,"method":"broadcast_tx_sync"}]`, false},
+		{"broadcast", "POST", "/", `{"jsonrpc":"2.0","id":1,"method":"broadcast_tx_async"}`, false},
+		{"put", "PUT", "/", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+			calls, err := inspectRPC(r, strings.TrimPrefix(r.URL.Path, "/"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := retryable(r, calls); got != tt.want {
+				t.Errorf("retryable = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// A POST whose body was not buffered cannot be replayed.
+	r := httptest.NewRequest("POST", "/", strings.NewReader(`{"method":"status"}`))
+	if retryable(r, rpcCalls{Calls: []rpcCall{{Method: "status"}}}) {
+		t.Error("a POST without GetBody must not be retried")
+	}
+}
+
+func TestRetryBudget(t *testing.T) {
+	b := newRetryBudget(RetryCfg{BudgetPercent: 20, BudgetMinPerSec: 1})
+	// The minimum alone: 1/s over the window.
+	for i := 0; i < 10; i++ {
+		if !b.take() {
+			t.Fatalf("retry %d refused within the minimum", i+1)
+		}
+	}
+	if b.take() {
+		t.Fatal("retry allowed past the minimum without traffic")
+	}
+	// 20% of 50 requests adds 10 more.
+	for range 50 {
+		b.request()
+	}
+	for i := 0; i < 10; i++ {
+		if !b.take() {
+			t.Fatalf("retry %d refused within 20%% of traffic", i+1)
+		}
+	}
+	if b.take() {
+		t.Fatal("retry allowed past the budget")
+	}
+	// A new window starts empty.
+	b.start = b.start.Add(-retryBudgetWindow)
+	if !b.take() || b.requests != 0 || b.retries != 1 {
+		t.Errorf("after the window: requests=%d retries=%d", b.requests, b.retries)
+	}
+}
+
+func TestValidateRetryDefaults(t *testing.T) {
+	c := &ChainConfig{Retry: RetryCfg{Enabled: true}}
+	if err := validateRetry(c); err != nil {
+		t.Fatal(err)
+	}
+	rc := c.Retry
+	if rc.MaxAttempts != 2 || rc.BudgetPercent != 20 || rc.BudgetMinPerSec != 1 ||
+		!rc.retryStatus(http.StatusBadGateway) || rc.retryStatus(http.StatusInternalServerError) {
+		t.Errorf("defaults = %+v", rc)
+	}
+	if err := validateRetry(&ChainConfig{Retry: RetryCfg{OnStatus: []int{429}}}); err == nil {
+		t.Error("want an error for a non-5xx on_status")
+	}
+}
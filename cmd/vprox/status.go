@@ -0,0 +1,193 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/vNodesV/vProx/internal/health"
+)
+
+// --------------------- STATUS ---------------------
+
+// statusPath is answered by vProx itself on every chain host with the status
+// of that host's chain; GET /admin/status lists every chain. Node data comes
+// from the [health] probes, so a status page never reaches the nodes.
+const statusPath = "/vprox/status"
+
+// chainStatus is the public summary of one chain. Node fields come from the
+// answering backend with the highest block and stay empty without [health].
+type chainStatus struct {
+	Name       string     `json:"name"`
+	ChainID    string     `json:"chain_id,omitempty"` // node_info.network
+	Height     int64      `json:"latest_height,omitempty"`
+	Time       time.Time  `json:"latest_time,omitzero"`
+	CatchingUp bool       `json:"catching_up"`
+	Version    string     `json:"node_version,omitempty"`
+	Health     string     `json:"health"` // OK | DEGRADED | DOWN | UNCHECKED
+	Healthy    int        `json:"healthy_backends"`
+	Backends   int        `json:"backends"`
+	Checked    time.Time  `json:"checked,omitzero"`
+	Services   []string   `json:"services"`
+	URLs       statusURLs `json:"urls"`
+}
+
+// statusURLs are the public endpoints of a chain as clients reach them.
+type statusURLs struct {
+	RPC       []string `json:"rpc,omitempty"`
+	REST      []string `json:"rest,omitempty"`
+	API       []string `json:"api,omitempty"`
+	GRPC      []string `json:"grpc,omitempty"`
+	GRPCWeb   []string `json:"grpc_web,omitempty"`
+	WebSocket []string `json:"websocket,omitempty"`
+}
+
+// serveStatus answers statusPath for chain.
+func serveStatus(w http.ResponseWriter, chain *ChainConfig) {
+	adminJSON(w, http.StatusOK, chain.status())
+}
+
+func (a *adminAPI) status(w http.ResponseWriter, r *http.Request) {
+	rt := currentRoutes()
+	out := []chainStatus{}
+	for _, c := range rt.uniqueChains() {
+		out = append(out, c.status())
+	}
+	adminJSON(w, http.StatusOK, map[string]any{
+		"loaded_at": rt.loadedAt.UTC(),
+		"chains":    out,
+	})
+}
+
+// status summarizes c from its serving backends' last health probes.
+func (c *ChainConfig) status() chainStatus {
+	s := chainStatus{Name: c.ChainName, Health: "UNCHECKED", Services: c.enabledServices(), URLs: c.exposedURLs()}
+	if c.pool == nil {
+		return s
+	}
+	s.Backends = len(c.pool.serving)
+	if healthChecker == nil || !c.Health.Enabled {
+		return s
+	}
+	var best *health.Status
+	for _, n := range c.pool.serving {
+		st, ok := healthChecker.Status(n.key)
+		if !ok {
+			continue
+		}
+		if st.Healthy {
+			s.Healthy++
+		}
+		if st.Error == "" && (best == nil || st.Height > best.Height) {
+			best = &st
+		}
+	}
+	switch {
+	case s.Healthy == s.Backends:
+		s.Health = "OK"
+	case s.Healthy > 0:
+		s.Health = "DEGRADED"
+	default:
+		s.Health = "DOWN"
+	}
+	if best != nil {
+		s.ChainID, s.Height, s.Time = best.Network, best.Height, best.LatestTime.UTC()
+		s.CatchingUp, s.Version, s.Checked = best.CatchingUp, best.Version, best.Checked.UTC()
+	}
+	return s
+}
+
+// enabledServices lists the [services] switched on, in config order.
+func (c *ChainConfig) enabledServices() []string {
+	out := []string{}
+	for _, s := range []struct {
+		name string
+		on   bool
+	}{
+		{"rpc", c.Services.RPC},
+		{"rest", c.Services.REST},
+		{"websocket", c.Services.WebSocket},
+		{"grpc", c.Services.GRPC},
+		{"grpc_web", c.Services.GRPCWeb},
+		{"grpc_web_translate", c.Services.GRPCWebTranslate},
+		{"api_alias", c.Services.APIAlias},
+	} {
+		if s.on {
+			out = append(out, s.name)
+		}
+	}
+	return out
+}
+
+// exposedURLs builds the chain's public URLs the way the handler routes
+// them: path routes (/rpc, /rest, ...) on the base host, plus on alias hosts
+// when vhosts are off; with [expose] vhost, <rpc>.<host> and <rest>.<host>
+// and the aliases serve RPC and REST at their root. https/wss is used when
+// the chain has a certificate and the TLS listener is up.
+func (c *ChainConfig) exposedURLs() statusURLs {
+	scheme, wsScheme, port := "http", "ws", ""
+	if c.TLS.enabled() && tlsListening {
+		scheme, wsScheme, port = "https", "wss", tlsRedirectPort
+	}
+	origin := func(s, host string) string {
+		if port != "" {
+			return s + "://" + host + ":" + port
+		}
+		return s + "://" + host
+	}
+	var u statusURLs
+	ws := c.Services.WebSocket && c.Services.RPC
+
+	if c.Expose.Path {
+		hosts := []string{c.Host}
+		if !c.Expose.VHost {
+			hosts = slices.Concat(hosts, c.Aliases.RPC, c.Aliases.REST, c.Aliases.API)
+		}
+		for _, h := range hosts {
+			if h == "" {
+				continue
+			}
+			base := origin(scheme, h)
+			if c.Services.RPC {
+				u.RPC = append(u.RPC, base+rpcPrefix)
+			}
+			if c.Services.REST {
+				u.REST = append(u.REST, base+restPrefix)
+			}
+			if c.Services.APIAlias {
+				u.API = append(u.API, base+apiPrefix)
+			}
+			if c.Services.GRPC {
+				u.GRPC = append(u.GRPC, base+grpcPrefix)
+			}
+			if c.Services.GRPCWeb || c.Services.GRPCWebTranslate {
+				u.GRPCWeb = append(u.GRPCWeb, base+grpcWebPrefix)
+			}
+			if ws {
+				u.WebSocket = append(u.WebSocket, origin(wsScheme, h)+"/websocket")
+			}
+		}
+	}
+	if c.Expose.VHost {
+		if c.Services.RPC {
+			for _, h := range append([]string{c.Expose.VHostPrefix.RPC + "." + c.Host}, c.Aliases.RPC...) {
+				if h == "" {
+					continue
+				}
+				u.RPC = append(u.RPC, origin(scheme, h))
+				if ws {
+					u.WebSocket = append(u.WebSocket, origin(wsScheme, h)+"/websocket")
+				}
+			}
+		}
+		if c.Services.REST {
+			hosts := slices.Concat([]string{c.Expose.VHostPrefix.REST + "." + c.Host}, c.Aliases.REST, c.Aliases.API)
+			for _, h := range hosts {
+				if h != "" {
+					u.REST = append(u.REST, origin(scheme, h))
+				}
+			}
+		}
+	}
+	return u
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExposedURLs(t *testing.T) {
+	all := Services{RPC: true, REST: true, WebSocket: true, GRPC: true, GRPCWebTranslate: true, APIAlias: true}
+	aliases := Aliases{RPC: []string{"rpc.alias.net"}, REST: []string{"lcd.alias.net"}, API: []string{"api.alias.net"}}
+	vhost := Expose{VHost: true, VHostPrefix: VHostPrefix{RPC: "rpc", REST: "api"}}
+	both := vhost
+	both.Path = true
+
+	tests := []struct {
+		name string
+		c    ChainConfig
+		tls  bool
+		want statusURLs
+	}{
+		{"path", ChainConfig{Host: "a.com", Expose: Expose{Path: true}, Services: all}, false, statusURLs{
+			RPC: []string{"http://a.com/rpc"}, REST: []string{"http://a.com/rest"}, API: []string{"http://a.com/api"},
+			GRPC: []string{"http://a.com/grpc"}, GRPCWeb: []string{"http://a.com/grpc-web"},
+			WebSocket: []string{"ws://a.com/websocket"},
+		}},
+		{"path on aliases without vhosts", ChainConfig{Host: "a.com", Aliases: aliases, Expose: Expose{Path: true},
+			Services: Services{RPC: true}}, false, statusURLs{
+			RPC: []string{"http://a.com/rpc", "http://rpc.alias.net/rpc", "http://lcd.alias.net/rpc", "http://api.alias.net/rpc"},
+		}},
+		{"websocket needs rpc", ChainConfig{Host: "a.com", Expose: Expose{Path: true},
+			Services: Services{REST: true, WebSocket: true}}, false, statusURLs{REST: []string{"http://a.com/rest"}}},
+		{"vhost", ChainConfig{Host: "a.com", Aliases: aliases, Expose: vhost, Services: all}, false, statusURLs{
+			RPC:       []string{"http://rpc.a.com", "http://rpc.alias.net"},
+			REST:      []string{"http://api.a.com", "http://lcd.alias.net", "http://api.alias.net"},
+			WebSocket: []string{"ws://rpc.a.com/websocket", "ws://rpc.alias.net/websocket"},
+		}},
+		{"path and vhost", ChainConfig{Host: "a.com", Aliases: aliases, Expose: both, Services: Services{RPC: true, REST: true}}, false, statusURLs{
+			RPC:  []string{"http://a.com/rpc", "http://rpc.a.com", "http://rpc.alias.net"},
+			REST: []string{"http://a.com/rest", "http://api.a.com", "http://lcd.alias.net", "http://api.alias.net"},
+		}},
+		{"tls", ChainConfig{Host: "a.com", Expose: both, Services: Services{RPC: true, WebSocket: true},
+			TLS: TLSCfg{CertFile: "a.crt", KeyFile: "a.key"}}, true, statusURLs{
+			RPC:       []string{"https://a.com:8443/rpc", "https://rpc.a.com:8443"},
+			WebSocket: []string{"wss://a.com:8443/websocket", "wss://rpc.a.com:8443/websocket"},
+		}},
+		{"certificate without tls listener", ChainConfig{Host: "a.com", Expose: Expose{Path: true}, Services: Services{RPC: true},
+			TLS: TLSCfg{CertFile: "a.crt", KeyFile: "a.key"}}, false, statusURLs{RPC: []string{"http://a.com/rpc"}}},
+	}
+	prevListening, prevPort := tlsListening, tlsRedirectPort
+	t.Cleanup(func() { tlsListening, tlsRedirectPort = prevListening, prevPort })
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tlsListening, tlsRedirectPort = tt.tls, "8443"
+			if got := tt.c.exposedURLs(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("exposedURLs =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+)
+
+// --------------------- UPSTREAM TIMEOUTS ---------------------
+
+// Upstream services with their own timeouts and transports.
+const (
+	svcRPC     = "rpc"
+	svcREST    = "rest"
+	svcGRPC    = "grpc"
+	svcGRPCWeb = "grpc_web"
+	svcAPI     = "api"
+)
+
+var upstreamServices = []string{svcRPC, svcREST, svcGRPC, svcGRPCWeb, svcAPI}
+
+// TimeoutSpec holds upstream timeouts in seconds. 0 inherits (service →
+// [timeouts] → built-in default), -1 disables the timeout.
+type TimeoutSpec struct {
+	ConnectSec        int `toml:"connect_sec"`         // TCP connect
+	ResponseHeaderSec int `toml:"response_header_sec"` // request sent → response headers
+	TotalSec          int `toml:"total_sec"`           // whole exchange incl. body streaming
+	IdleSec           int `toml:"idle_sec"`            // idle keep-alive connections
+}
+
+// TimeoutsCfg is the chain [timeouts] table: chain-wide values plus
+// per-service overrides ([timeouts.rpc], [timeouts.rest], ...).
+type TimeoutsCfg struct {
+	TimeoutSpec
+	RPC     TimeoutSpec `toml:"rpc"`
+	REST    TimeoutSpec `toml:"rest"`
+	GRPC    TimeoutSpec `toml:"grpc"`
+	GRPCWeb TimeoutSpec `toml:"grpc_web"`
+	API     TimeoutSpec `toml:"api"`
+}
+
+// Built-in defaults. Native gRPC has no total cap (streams).
+var (
+	defaultTimeouts     = TimeoutSpec{ConnectSec: 5, ResponseHeaderSec: 30, TotalSec: 60, IdleSec: 90}
+	defaultGRPCTimeouts = TimeoutSpec{ConnectSec: 5, ResponseHeaderSec: 30, TotalSec: -1, IdleSec: 90}
+)
+
+// routeDeadlineGrace is added to the upstream total for the client
+// connection's read/write deadline, so the proxy can still answer 504.
+const routeDeadlineGrace = 5 * time.Second
+
+// upstreamTimeouts is a resolved TimeoutSpec; zero durations mean "none".
+type upstreamTimeouts struct {
+	connect, header, total, idle time.Duration
+}
+
+func (c *TimeoutsCfg) service(svc string) TimeoutSpec {
+	switch svc {
+	case svcRPC:
+		return c.RPC
+	case svcREST:
+		return c.REST
+	case svcGRPC:
+		return c.GRPC
+	case svcGRPCWeb:
+		return c.GRPCWeb
+	case svcAPI:
+		return c.API
+	}
+	return TimeoutSpec{}
+}
+
+// resolve overlays service > chain > built-in values for svc.
+func (c *TimeoutsCfg) resolve(svc string) upstreamTimeouts {
+	base := defaultTimeouts
+	if svc == svcGRPC {
+		base = defaultGRPCTimeouts
+	}
+	pick := func(builtin, chain, service int) time.Duration {
+		v := builtin
+		if chain != 0 {
+			v = chain
+		}
+		if service != 0 {
+			v = service
+		}
+		if v < 0 {
+			return 0
+		}
+		return time.Duration(v) * time.Second
+	}
+	s := c.service(svc)
+	return upstreamTimeouts{
+		connect: pick(base.ConnectSec, c.ConnectSec, s.ConnectSec),
+		header:  pick(base.ResponseHeaderSec, c.ResponseHeaderSec, s.ResponseHeaderSec),
+		total:   pick(base.TotalSec, c.TotalSec, s.TotalSec),
+		idle:    pick(base.IdleSec, c.IdleSec, s.IdleSec),
+	}
+}
+
+func validateTimeouts(c *ChainConfig) error {
+	check := func(label string, s TimeoutSpec) error {
+		for _, v := range []int{s.ConnectSec, s.ResponseHeaderSec, s.TotalSec, s.IdleSec} {
+			if v < -1 {
+				return fmt.Errorf("%s: values must be >= -1 (0 = inherit, -1 = disabled)", label)
+			}
+		}
+		return nil
+	}
+	if err := check("timeouts", c.Timeouts.TimeoutSpec); err != nil {
+		return err
+	}
+	for _, svc := range upstreamServices {
+		if err := check("timeouts."+svc, c.Timeouts.service(svc)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// newUpstreamTransport builds the transport of one backend service. gRPC
+// uses HTTP/2 cleartext (h2c) and leaves compression to the gRPC peers.
+func newUpstreamTransport(t upstreamTimeouts, h2c bool) *http.Transport {
+	dialer := &net.Dialer{Timeout: t.connect, KeepAlive: 30 * time.Second}
+	tr := &http.Transport{
+		DialContext:           dialer.DialContext,
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   32,
+		IdleConnTimeout:       t.idle,
+		ResponseHeaderTimeout: t.header,
+	}
+	if h2c {
+		var protos http.Protocols
+		protos.SetUnencryptedHTTP2(true)
+		tr.Protocols = &protos
+		tr.DisableCompression = true
+	}
+	return tr
+}
+
+// upstream is a backend node's client for one service.
+type upstream struct {
+	timeouts  upstreamTimeouts
+	transport *http.Transport
+	rt        http.RoundTripper // transport + upstream metrics; use this to send
+	client    *http.Client
+}
+
+func newUpstreams(c *ChainConfig, backend string) map[string]*upstream {
+	out := make(map[string]*upstream, len(upstreamServices))
+	for _, svc := range upstreamServices {
+		t := c.Timeouts.resolve(svc)
+		tr := newUpstreamTransport(t, svc == svcGRPC)
+		rt := &observedTransport{base: tr, chain: c.ChainName, backend: backend, svc: svc}
+		out[svc] = &upstream{timeouts: t, transport: tr, rt: rt, client: &http.Client{Transport: rt}}
+	}
+	return out
+}
+
+// Default client deadlines, set as a request arrives (withDefaultDeadlines)
+// and replaced by setRouteDeadlines once the route is known. They bound all
+// that happens before: body inspection, limiter waits, denials and errors.
+const (
+	defaultReadDeadline  = 15 * time.Second
+	defaultWriteDeadline = 30 * time.Second
+)
+
+// withDefaultDeadlines sets the default read/write deadlines on the client
+// connection of every request.
+func withDefaultDeadlines(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rc := http.NewResponseController(w)
+		now := time.Now()
+		_ = rc.SetReadDeadline(now.Add(defaultReadDeadline))
+		_ = rc.SetWriteDeadline(now.Add(defaultWriteDeadline))
+		next.ServeHTTP(w, r)
+	})
+}
+
+// setRouteDeadlines replaces the default deadlines of the client connection
+// with the route's upstream total (plus grace). Routes without a total
+// (streams) get none, so long downloads and streams are not cut.
+func setRouteDeadlines(w http.ResponseWriter, total time.Duration) {
+	rc := http.NewResponseController(w)
+	var d time.Time
+	if total > 0 {
+		d = time.Now().Add(total + routeDeadlineGrace)
+	}
+	_ = rc.SetReadDeadline(d)
+	_ = rc.SetWriteDeadline(d)
+}
+
+// isTimeout reports whether err is an upstream timeout (connect, header or
+// total deadline).
+func isTimeout(err error) bool {
+	var ne net.Error
+	return errors.Is(err, context.DeadlineExceeded) || errors.As(err, &ne) && ne.Timeout()
+}
+
+// describeTimeouts renders resolved timeouts for --info.
+func describeTimeouts(t upstreamTimeouts) string {
+	f := func(d time.Duration) string {
+		if d <= 0 {
+			return "off"
+		}
+		return d.String()
+	}
+	return fmt.Sprintf("connect=%s header=%s total=%s idle=%s", f(t.connect), f(t.header), f(t.total), f(t.idle))
+}
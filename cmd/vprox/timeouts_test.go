@@ -0,0 +1,60 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTimeoutsResolve(t *testing.T) {
+	cfg := TimeoutsCfg{
+		TimeoutSpec: TimeoutSpec{ConnectSec: 2, TotalSec: 20},
+		RPC:         TimeoutSpec{TotalSec: 8, IdleSec: -1},
+		REST:        TimeoutSpec{ConnectSec: -1},
+		GRPC:        TimeoutSpec{ResponseHeaderSec: 7},
+	}
+	tests := []struct {
+		name string
+		cfg  TimeoutsCfg
+		svc  string
+		want upstreamTimeouts
+	}{
+		{"defaults", TimeoutsCfg{}, svcRPC, upstreamTimeouts{5 * time.Second, 30 * time.Second, 60 * time.Second, 90 * time.Second}},
+		{"grpc default has no total", TimeoutsCfg{}, svcGRPC, upstreamTimeouts{5 * time.Second, 30 * time.Second, 0, 90 * time.Second}},
+		{"service over chain", cfg, svcRPC, upstreamTimeouts{2 * time.Second, 30 * time.Second, 8 * time.Second, 0}},
+		{"service disables", cfg, svcREST, upstreamTimeouts{0, 30 * time.Second, 20 * time.Second, 90 * time.Second}},
+		{"chain over default", cfg, svcAPI, upstreamTimeouts{2 * time.Second, 30 * time.Second, 20 * time.Second, 90 * time.Second}},
+		{"chain total caps grpc", cfg, svcGRPC, upstreamTimeouts{2 * time.Second, 7 * time.Second, 20 * time.Second, 90 * time.Second}},
+		{"unknown service", cfg, "ws", upstreamTimeouts{2 * time.Second, 30 * time.Second, 20 * time.Second, 90 * time.Second}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.cfg.resolve(tt.svc); got != tt.want {
+				t.Errorf("resolve(%s) = %+v, want %+v", tt.svc, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateTimeouts(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     TimeoutsCfg
+		wantErr string
+	}{
+		{"inherit and disable", TimeoutsCfg{TimeoutSpec: TimeoutSpec{TotalSec: -1}, RPC: TimeoutSpec{IdleSec: 0}}, ""},
+		{"chain out of range", TimeoutsCfg{TimeoutSpec: TimeoutSpec{ConnectSec: -2}}, "timeouts:"},
+		{"service out of range", TimeoutsCfg{GRPCWeb: TimeoutSpec{TotalSec: -5}}, "timeouts.grpc_web:"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateTimeouts(&ChainConfig{Timeouts: tt.cfg})
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Fatalf("unexpected error: %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Fatalf("error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
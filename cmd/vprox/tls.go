@@ -0,0 +1,250 @@
+package main
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	applog "github.com/vNodesV/vProx/internal/logging"
+)
+
+// --------------------- TLS TERMINATION (SNI) ---------------------
+
+// TLSCertPair is a cert/key file pair for an explicit host list.
+type TLSCertPair struct {
+	Hosts    []string `toml:"hosts"`
+	CertFile string   `toml:"cert_file"`
+	KeyFile  string   `toml:"key_file"`
+}
+
+// TLSCfg configures TLS termination for a chain. cert_file/key_file cover
+// every host registered for the chain (base host, vhosts, aliases); [[tls.certs]]
+// overrides the pair for specific hosts.
+type TLSCfg struct {
+	CertFile     string        `toml:"cert_file"`
+	KeyFile      string        `toml:"key_file"`
+	Certs        []TLSCertPair `toml:"certs"`
+	RedirectHTTP bool          `toml:"redirect_http"` // 308 plain-HTTP requests to https://
+}
+
+func (t TLSCfg) enabled() bool {
+	return t.CertFile != "" || len(t.Certs) > 0
+}
+
+// pairFor returns the cert/key files serving host, or ok=false.
+func (t TLSCfg) pairFor(host string) (cert, key string, ok bool) {
+	for _, p := range t.Certs {
+		if inList(p.Hosts, host) {
+			return p.CertFile, p.KeyFile, true
+		}
+	}
+	if t.CertFile != "" {
+		return t.CertFile, t.KeyFile, true
+	}
+	return "", "", false
+}
+
+// resolveConfigPath resolves relative paths under the config directory.
+func resolveConfigPath(p string) string {
+	p = strings.TrimSpace(p)
+	if p == "" || filepath.IsAbs(p) {
+		return p
+	}
+	return filepath.Join(configDir, p)
+}
+
+// validateTLS normalizes [tls] paths and checks every pair loads.
+func validateTLS(c *ChainConfig) error {
+	t := &c.TLS
+	if !t.enabled() {
+		if t.RedirectHTTP {
+			return errors.New("tls.redirect_http requires tls.cert_file/key_file or [[tls.certs]]")
+		}
+		return nil
+	}
+	check := func(label string, cert, key *string) error {
+		*cert, *key = resolveConfigPath(*cert), resolveConfigPath(*key)
+		if *cert == "" || *key == "" {
+			return fmt.Errorf("%s: cert_file and key_file are both required", label)
+		}
+		if _, err := tls.LoadX509KeyPair(*cert, *key); err != nil {
+			return fmt.Errorf("%s: %w", label, err)
+		}
+		return nil
+	}
+	if t.CertFile != "" || t.KeyFile != "" {
+		if err := check("tls", &t.CertFile, &t.KeyFile); err != nil {
+			return err
+		}
+	}
+	for i := range t.Certs {
+		p := &t.Certs[i]
+		if len(p.Hosts) == 0 {
+			return fmt.Errorf("tls.certs[%d]: hosts is empty", i)
+		}
+		for j, h := range p.Hosts {
+			p.Hosts[j] = strings.ToLower(strings.TrimSpace(h))
+		}
+		if err := check(fmt.Sprintf("tls.certs[%d]", i), &p.CertFile, &p.KeyFile); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tlsFiles identifies a loaded certificate on disk.
+type tlsFiles struct{ cert, key string }
+
+type cachedCert struct {
+	cert            *tls.Certificate
+	certMod, keyMod time.Time
+	lastErr         string
+}
+
+// certCache holds parsed certificates keyed by file pair. Entries are
+// re-parsed when either file's mtime changes, so renewed certificates are
+// picked up for new handshakes while established connections are untouched.
+var certCache = struct {
+	sync.RWMutex
+	m map[tlsFiles]*cachedCert
+}{m: make(map[tlsFiles]*cachedCert)}
+
+func loadCachedCert(f tlsFiles) (*tls.Certificate, error) {
+	certCache.RLock()
+	e, ok := certCache.m[f]
+	certCache.RUnlock()
+	if ok && e.cert != nil {
+		return e.cert, nil
+	}
+	return refreshCert(f)
+}
+
+// refreshCert (re)loads the pair when it is new or changed on disk. A broken
+// renewal keeps serving the previous certificate.
+func refreshCert(f tlsFiles) (*tls.Certificate, error) {
+	cm, err1 := modTime(f.cert)
+	km, err2 := modTime(f.key)
+
+	certCache.Lock()
+	defer certCache.Unlock()
+	e := certCache.m[f]
+	if e != nil && e.cert != nil && (err1 != nil || err2 != nil || (cm.Equal(e.certMod) && km.Equal(e.keyMod))) {
+		return e.cert, nil
+	}
+	c, err := tls.LoadX509KeyPair(f.cert, f.key)
+	if err != nil {
+		if e != nil && e.cert != nil {
+			if e.lastErr != err.Error() {
+				e.lastErr = err.Error()
+				applog.Print("ERROR", "tls", "cert_reload_failed", applog.F("cert", f.cert), applog.F("error", err.Error()))
+			}
+			return e.cert, nil
+		}
+		return nil, err
+	}
+	if e != nil && e.cert != nil {
+		applog.Print("INFO", "tls", "cert_reloaded", applog.F("cert", f.cert))
+	}
+	certCache.m[f] = &cachedCert{cert: &c, certMod: cm, keyMod: km}
+	return &c, nil
+}
+
+func modTime(p string) (time.Time, error) {
+	fi, err := os.Stat(p)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return fi.ModTime(), nil
+}
+
+// startCertWatcher re-checks every cached certificate on interval. Returns a
+// stop function.
+func startCertWatcher(interval time.Duration) func() {
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				certCache.RLock()
+				keys := make([]tlsFiles, 0, len(certCache.m))
+				for k := range certCache.m {
+					keys = append(keys, k)
+				}
+				certCache.RUnlock()
+				for _, k := range keys {
+					_, _ = refreshCert(k)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		ticker.Stop()
+		close(done)
+	}
+}
+
+// getCertificate selects the certificate by SNI from the current routing
+// table; the host set is exactly what registerHost registered.
+func getCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	name := strings.ToLower(strings.TrimSuffix(hello.ServerName, "."))
+	if name == "" {
+		return nil, errors.New("tls: client did not send SNI")
+	}
+	rt := currentRoutes()
+	f, ok := rt.tlsHosts[name]
+	if !ok {
+		return nil, fmt.Errorf("tls: no certificate for %q", name)
+	}
+	return loadCachedCert(f)
+}
+
+// tlsServerConfig builds the listener TLS config. ALPN offers h2 so native
+// gRPC and HTTP/2 clients negotiate HTTP/2 over TLS.
+func tlsServerConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: getCertificate,
+	}
+}
+
+// tlsProtocols returns HTTP/1.1 + HTTP/2 (negotiated by ALPN).
+func tlsProtocols() *http.Protocols {
+	var protos http.Protocols
+	protos.SetHTTP1(true)
+	protos.SetHTTP2(true)
+	return &protos
+}
+
+// tlsRedirectPort is the port appended to https:// redirects ("" for 443).
+var tlsRedirectPort string
+
+// maybeRedirectHTTPS answers plain-HTTP requests for chains with
+// tls.redirect_http with a 308 to the https:// URL. Requests already marked
+// https by an upstream proxy (X-Forwarded-Proto) are left alone.
+func maybeRedirectHTTPS(w http.ResponseWriter, r *http.Request, chain *ChainConfig, host string) bool {
+	if r.TLS != nil || !chain.TLS.RedirectHTTP || !tlsListening {
+		return false
+	}
+	if strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https") {
+		return false
+	}
+	target := "https://" + host
+	if tlsRedirectPort != "" {
+		target = "https://" + net.JoinHostPort(host, tlsRedirectPort)
+	}
+	http.Redirect(w, r, target+r.URL.RequestURI(), http.StatusPermanentRedirect)
+	return true
+}
+
+// tlsListening is true once the TLS listener is configured.
+var tlsListening bool
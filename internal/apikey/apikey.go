@@ -0,0 +1,235 @@
+// Package apikey loads API keys from a TOML file and finds the key a request
+// presents in a header, query parameter or path segment. Secrets are kept
+// only as SHA-256 digests; callers log Key.ID, never the secret.
+package apikey
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+	"github.com/vNodesV/vProx/internal/limit"
+)
+
+// Default places a key is read from; "-" in the file disables one.
+const (
+	DefaultHeader      = "X-API-Key"
+	DefaultQuery       = "api_key"
+	DefaultPathSegment = "key" // /key/<secret>/rest/...
+)
+
+// File is the structure of apikeys.toml.
+type File struct {
+	Header      string      `toml:"header"`
+	Query       string      `toml:"query"`
+	PathSegment string      `toml:"path_segment"`
+	Keys        []KeyConfig `toml:"keys"`
+}
+
+// KeyConfig is one [[keys]] entry. Exactly one of Key (plaintext) and
+// KeySHA256 (hex digest of the secret) is set.
+type KeyConfig struct {
+	ID        string    `toml:"id"`
+	Key       string    `toml:"key"`
+	KeySHA256 string    `toml:"key_sha256"`
+	RPS       float64   `toml:"rps"`
+	Burst     int       `toml:"burst"`
+	Chains    []string  `toml:"chains"`  // chain_name values; empty = every chain
+	Expires   time.Time `toml:"expires"` // zero = never
+}
+
+// Key is a loaded API key.
+type Key struct {
+	ID      string
+	Spec    limit.RateSpec
+	Chains  []string
+	Expires time.Time
+}
+
+// Expired reports whether k has an expiry before now.
+func (k *Key) Expired(now time.Time) bool {
+	return !k.Expires.IsZero() && now.After(k.Expires)
+}
+
+// AllowsChain reports whether k may be used on the named chain.
+func (k *Key) AllowsChain(name string) bool {
+	if len(k.Chains) == 0 {
+		return true
+	}
+	for _, c := range k.Chains {
+		if strings.EqualFold(c, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// Set is an immutable set of keys and where to find them in a request.
+type Set struct {
+	header, query, segment string
+	byHash                 map[[32]byte]*Key
+	keys                   []*Key // sorted by ID
+}
+
+// Load reads path. A missing file yields (nil, nil): API keys are off.
+func Load(path string) (*Set, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var f File
+	if err := toml.Unmarshal(b, &f); err != nil {
+		return nil, err
+	}
+	return New(f)
+}
+
+// New validates f and builds a Set.
+func New(f File) (*Set, error) {
+	s := &Set{
+		header:  orDefault(f.Header, DefaultHeader),
+		query:   orDefault(f.Query, DefaultQuery),
+		segment: strings.Trim(orDefault(f.PathSegment, DefaultPathSegment), "/"),
+		byHash:  make(map[[32]byte]*Key, len(f.Keys)),
+	}
+	ids := make(map[string]bool, len(f.Keys))
+	for i, kc := range f.Keys {
+		id := strings.TrimSpace(kc.ID)
+		if id == "" {
+			return nil, fmt.Errorf("keys[%d]: id is required", i)
+		}
+		if ids[id] {
+			return nil, fmt.Errorf("key %q: duplicate id", id)
+		}
+		ids[id] = true
+		var sum [32]byte
+		switch {
+		case kc.Key != "" && kc.KeySHA256 != "":
+			return nil, fmt.Errorf("key %q: set key or key_sha256, not both", id)
+		case kc.Key != "":
+			if len(kc.Key) < 16 {
+				return nil, fmt.Errorf("key %q: secret must be at least 16 characters", id)
+			}
+			sum = sha256.Sum256([]byte(kc.Key))
+		case kc.KeySHA256 != "":
+			d, err := hex.DecodeString(strings.TrimSpace(kc.KeySHA256))
+			if err != nil || len(d) != len(sum) {
+				return nil, fmt.Errorf("key %q: key_sha256 must be 64 hex characters", id)
+			}
+			copy(sum[:], d)
+		default:
+			return nil, fmt.Errorf("key %q: key or key_sha256 is required", id)
+		}
+		if _, dup := s.byHash[sum]; dup {
+			return nil, fmt.Errorf("key %q: same secret as another key", id)
+		}
+		if kc.RPS <= 0 || kc.Burst < 1 {
+			return nil, fmt.Errorf("key %q: rps must be > 0 and burst >= 1", id)
+		}
+		k := &Key{
+			ID:      id,
+			Spec:    limit.RateSpec{RPS: kc.RPS, Burst: kc.Burst},
+			Chains:  kc.Chains,
+			Expires: kc.Expires,
+		}
+		s.byHash[sum] = k
+		s.keys = append(s.keys, k)
+	}
+	sort.Slice(s.keys, func(i, j int) bool { return s.keys[i].ID < s.keys[j].ID })
+	return s, nil
+}
+
+func orDefault(v, def string) string {
+	v = strings.TrimSpace(v)
+	switch v {
+	case "":
+		return def
+	case "-":
+		return ""
+	}
+	return v
+}
+
+// Keys returns the keys sorted by ID.
+func (s *Set) Keys() []*Key {
+	if s == nil {
+		return nil
+	}
+	return s.keys
+}
+
+// Lookup returns the key whose secret is secret, or nil.
+func (s *Set) Lookup(secret string) *Key {
+	if s == nil || secret == "" {
+		return nil
+	}
+	return s.byHash[sha256.Sum256([]byte(secret))]
+}
+
+// Extract returns the secret r presents (header first, then query, then
+// path segment) and removes it from r, so it is neither forwarded upstream
+// nor logged. "" when r carries no key.
+func (s *Set) Extract(r *http.Request) string {
+	if s == nil {
+		return ""
+	}
+	var secret string
+	if s.header != "" {
+		if v := strings.TrimSpace(r.Header.Get(s.header)); v != "" {
+			secret = v
+		}
+		r.Header.Del(s.header)
+	}
+	if s.query != "" && r.URL.RawQuery != "" {
+		var v string
+		r.URL.RawQuery, v = cutQueryParam(r.URL.RawQuery, s.query)
+		if v = strings.TrimSpace(v); v != "" && secret == "" {
+			secret = v
+		}
+	}
+	if s.segment != "" {
+		prefix := "/" + s.segment + "/"
+		if rest, ok := strings.CutPrefix(r.URL.Path, prefix); ok {
+			v, tail, _ := strings.Cut(rest, "/")
+			if secret == "" {
+				secret = v
+			}
+			r.URL.Path = "/" + tail
+			r.URL.RawPath = ""
+		}
+	}
+	r.RequestURI = r.URL.RequestURI()
+	return secret
+}
+
+// cutQueryParam removes every name=value pair from the raw query and returns
+// the rest with the other pairs byte-for-byte unchanged (order and encoding
+// matter to CometBFT URI calls and cache keys), plus the first value of name.
+func cutQueryParam(raw, name string) (rest, value string) {
+	pairs := strings.Split(raw, "&")
+	kept := pairs[:0]
+	found := false
+	for _, p := range pairs {
+		k, v, _ := strings.Cut(p, "=")
+		if key, err := url.QueryUnescape(k); err == nil && key == name {
+			if !found {
+				value, _ = url.QueryUnescape(v)
+				found = true
+			}
+			continue
+		}
+		kept = append(kept, p)
+	}
+	return strings.Join(kept, "&"), value
+}
@@ -0,0 +1,131 @@
+package apikey
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const secret = "0123456789abcdef-secret"
+
+func testSet(t *testing.T, f File) *Set {
+	t.Helper()
+	if len(f.Keys) == 0 {
+		f.Keys = []KeyConfig{{ID: "plain", Key: secret, RPS: 5, Burst: 10}}
+	}
+	s, err := New(f)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return s
+}
+
+func TestNewValidation(t *testing.T) {
+	sum := sha256.Sum256([]byte(secret))
+	digest := hex.EncodeToString(sum[:])
+	tests := []struct {
+		name    string
+		keys    []KeyConfig
+		wantErr string
+	}{
+		{"plaintext", []KeyConfig{{ID: "a", Key: secret, RPS: 1, Burst: 1}}, ""},
+		{"digest", []KeyConfig{{ID: "a", KeySHA256: digest, RPS: 1, Burst: 1}}, ""},
+		{"missing id", []KeyConfig{{Key: secret, RPS: 1, Burst: 1}}, "id is required"},
+		{"duplicate id", []KeyConfig{{ID: "a", Key: secret, RPS: 1, Burst: 1}, {ID: "a", Key: secret + "x", RPS: 1, Burst: 1}}, "duplicate id"},
+		{"both secrets", []KeyConfig{{ID: "a", Key: secret, KeySHA256: digest, RPS: 1, Burst: 1}}, "not both"},
+		{"no secret", []KeyConfig{{ID: "a", RPS: 1, Burst: 1}}, "is required"},
+		{"short secret", []KeyConfig{{ID: "a", Key: "short", RPS: 1, Burst: 1}}, "at least 16"},
+		{"bad digest", []KeyConfig{{ID: "a", KeySHA256: "abcd", RPS: 1, Burst: 1}}, "64 hex"},
+		{"same secret", []KeyConfig{{ID: "a", Key: secret, RPS: 1, Burst: 1}, {ID: "b", KeySHA256: digest, RPS: 1, Burst: 1}}, "same secret"},
+		{"no rate", []KeyConfig{{ID: "a", Key: secret, Burst: 1}}, "rps must be > 0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(File{Keys: tt.keys})
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Fatalf("unexpected error: %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Fatalf("error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLookupMatchesDigest(t *testing.T) {
+	sum := sha256.Sum256([]byte(secret))
+	s := testSet(t, File{Keys: []KeyConfig{{ID: "hashed", KeySHA256: strings.ToUpper(hex.EncodeToString(sum[:])), RPS: 1, Burst: 1}}})
+	if k := s.Lookup(secret); k == nil || k.ID != "hashed" {
+		t.Fatalf("Lookup(secret) = %v, want hashed", k)
+	}
+	for _, bad := range []string{"", secret + "x", strings.ToUpper(secret)} {
+		if k := s.Lookup(bad); k != nil {
+			t.Errorf("Lookup(%q) = %s, want nil", bad, k.ID)
+		}
+	}
+	var nilSet *Set
+	if nilSet.Lookup(secret) != nil || nilSet.Extract(httptest.NewRequest("GET", "/?api_key=x", nil)) != "" {
+		t.Error("nil Set must find nothing")
+	}
+}
+
+func TestExtract(t *testing.T) {
+	tests := []struct {
+		name       string
+		file       File
+		target     string
+		header     string
+		wantSecret string
+		wantURI    string
+	}{
+		{"none", File{}, "/rpc/status?height=5", "", "", "/rpc/status?height=5"},
+		{"header", File{}, "/rpc/status", secret, secret, "/rpc/status"},
+		{"query keeps other pairs as sent", File{}, "/rpc/block_search?query=%22tx.height%3D5%22&api_key=" + secret + "&page=2&order_by=%22desc%22",
+			"", secret, "/rpc/block_search?query=%22tx.height%3D5%22&page=2&order_by=%22desc%22"},
+		{"query only param", File{}, "/rest/x?api_key=" + secret, "", secret, "/rest/x"},
+		{"query repeated", File{}, "/x?api_key=" + secret + "&b=1&api_key=other", "", secret, "/x?b=1"},
+		{"query encoded name", File{}, "/x?a=1&api%5Fkey=" + secret, "", secret, "/x?a=1"},
+		{"query similar name kept", File{}, "/x?my_api_key=1&api_keys=2", "", "", "/x?my_api_key=1&api_keys=2"},
+		{"header wins over query", File{}, "/x?api_key=other", secret, secret, "/x"},
+		{"path segment", File{}, "/key/" + secret + "/rest/cosmos/bank?x=1", "", secret, "/rest/cosmos/bank?x=1"},
+		{"custom places", File{Header: "X-Token", Query: "token", PathSegment: "-"}, "/key/abc/x?token=" + secret, "", secret, "/key/abc/x"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := testSet(t, tt.file)
+			r := httptest.NewRequest("GET", tt.target, nil)
+			if tt.header != "" {
+				r.Header.Set("X-API-Key", tt.header)
+			}
+			if got := s.Extract(r); got != tt.wantSecret {
+				t.Errorf("secret = %q, want %q", got, tt.wantSecret)
+			}
+			if got := r.URL.RequestURI(); got != tt.wantURI {
+				t.Errorf("URI = %q, want %q", got, tt.wantURI)
+			}
+			if r.RequestURI != tt.wantURI {
+				t.Errorf("RequestURI = %q, want %q", r.RequestURI, tt.wantURI)
+			}
+			if r.Header.Get("X-API-Key") != "" {
+				t.Error("key header must be removed")
+			}
+		})
+	}
+}
+
+func TestKeyScopeAndExpiry(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	k := &Key{Chains: []string{"cosmoshub"}, Expires: now}
+	if !k.AllowsChain("CosmosHub") || k.AllowsChain("osmosis") {
+		t.Error("chain scope mismatch")
+	}
+	if k.Expired(now) || !k.Expired(now.Add(time.Second)) {
+		t.Error("expiry mismatch")
+	}
+	if (&Key{}).Expired(now) || !(&Key{}).AllowsChain("any") {
+		t.Error("zero key must never expire and allow every chain")
+	}
+}
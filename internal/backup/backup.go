@@ -3,6 +3,7 @@ package backup
 import (
 	"archive/tar"
 	"compress/gzip"
+	"context"
 	"crypto/rand"
 	"encoding/hex"
 	"errors"
@@ -12,9 +13,12 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	applog "github.com/vNodesV/vProx/internal/logging"
+	"github.com/vNodesV/vProx/internal/metrics"
+	"github.com/vNodesV/vProx/internal/trace"
 )
 
 const defaultCompression = "tar.gz"
@@ -62,7 +66,22 @@ type archiveEntry struct {
 	Name    string
 }
 
-// RunOnce performs a single backup run.
+// ErrRunning is returned by RunOnce while another run is in progress.
+var ErrRunning = errors.New("backup: a run is already in progress")
+
+// runMu serializes runs (scheduler, manual and admin-triggered).
+var runMu sync.Mutex
+
+var (
+	runsTotal = metrics.NewCounter("vprox_backup_runs_total",
+		"Backup runs by method (AUTO, MANUAL) and result.", "method", "result")
+	archiveBytes = metrics.NewHistogram("vprox_backup_archive_bytes",
+		"Compressed size of created backup archives.", metrics.ExponentialBuckets(64<<10, 4, 10))
+	lastSuccess = metrics.NewGauge("vprox_backup_last_success_timestamp_seconds",
+		"Unix time of the last completed backup.")
+)
+
+// RunOnce performs a single backup run. Concurrent calls fail with ErrRunning.
 //
 // Behavior:
 //  1. Collect all source files (LogPath + ExtraFiles that exist)
@@ -73,7 +92,11 @@ type archiveEntry struct {
 //  6. Compress all snapshots into a single tar.gz archive
 //  7. Remove temp copies
 //  8. Emit UPD COMPLETED (or UPD FAILED) log line
-func RunOnce(opts Options) error {
+func RunOnce(opts Options) (err error) {
+	if !runMu.TryLock() {
+		return ErrRunning
+	}
+	defer runMu.Unlock()
 	if strings.TrimSpace(opts.LogPath) == "" {
 		return errors.New("backup: LogPath is required")
 	}
@@ -93,6 +116,16 @@ func RunOnce(opts Options) error {
 	if listSource == "" {
 		listSource = "default"
 	}
+	_, span := trace.Start(context.Background(), "backup", trace.KindInternal,
+		trace.A("vprox.backup.id", id),
+		trace.A("vprox.backup.method", method),
+	)
+	defer func() {
+		if err != nil {
+			span.SetError(err.Error())
+		}
+		span.End()
+	}()
 
 	logPath := filepath.Clean(opts.LogPath)
 	logDir := filepath.Dir(logPath)
@@ -150,7 +183,7 @@ func RunOnce(opts Options) error {
 	if containsPath(presentSources, logPath) {
 		if err := os.Truncate(logPath, 0); err != nil {
 			_ = cleanupTemps(tmpPaths)
-			emitFailed(id, method, err.Error())
+			emitFailed(id, method, err.Error(), span)
 			return fmt.Errorf("backup: truncate log: %w", err)
 		}
 	}
@@ -166,27 +199,35 @@ func RunOnce(opts Options) error {
 		applog.F("list", listSource),
 		applog.F("to", finalPath),
 		applog.F("size", humanSize(totalSize)),
+		traceField(span),
 	)
 
 	if err := writeTarGz(entries, finalPath); err != nil {
 		_ = cleanupTemps(tmpPaths)
-		emitFailed(id, method, err.Error())
+		emitFailed(id, method, err.Error(), span)
 		return err
 	}
 	_ = cleanupTemps(tmpPaths)
 
 	archiveInfo, err := os.Stat(finalPath)
 	if err != nil {
-		emitFailed(id, method, err.Error())
+		emitFailed(id, method, err.Error(), span)
 		return fmt.Errorf("backup: stat archive: %w", err)
 	}
 
+	runsTotal.Inc(method, "completed")
+	archiveBytes.Observe(float64(archiveInfo.Size()))
+	lastSuccess.Set(float64(now.Unix()))
+	span.SetAttr("vprox.backup.files", len(entries))
+	span.SetAttr("vprox.backup.archive_bytes", archiveInfo.Size())
+
 	// Emit UPD COMPLETED line.
 	applog.PrintLifecycle("UPD", "backup",
 		applog.F("ID", id),
 		applog.F("status", "COMPLETED"),
 		applog.F("location", finalPath),
 		applog.F("compressedSize", humanSize(archiveInfo.Size())),
+		traceField(span),
 	)
 
 	if opts.StatePath != "" {
@@ -196,15 +237,26 @@ func RunOnce(opts Options) error {
 }
 
 // emitFailed emits a UPD FAILED log line.
-func emitFailed(id, method, reason string) {
+func emitFailed(id, method, reason string, span *trace.Span) {
+	runsTotal.Inc(method, "failed")
 	applog.PrintLifecycle("UPD", "backup",
 		applog.F("ID", id),
 		applog.F("status", "FAILED"),
 		applog.F("method", method),
 		applog.F("reason", reason),
+		traceField(span),
 	)
 }
 
+// traceField is the traceID field of a recorded span; the logger skips it
+// (empty key) when the run is not traced.
+func traceField(span *trace.Span) applog.Field {
+	if id := span.TraceID(); id != "" {
+		return applog.F("traceID", id)
+	}
+	return applog.Field{}
+}
+
 // cleanupTemps removes a list of temporary file paths, ignoring errors.
 func cleanupTemps(paths []string) error {
 	for _, p := range paths {
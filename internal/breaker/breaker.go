@@ -0,0 +1,252 @@
+package breaker
+
+import (
+	"sync"
+	"time"
+
+	applog "github.com/vNodesV/vProx/internal/logging"
+)
+
+// State of a breaker.
+type State int
+
+const (
+	Closed State = iota
+	Open
+	HalfOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case Open:
+		return "OPEN"
+	case HalfOpen:
+		return "HALF_OPEN"
+	default:
+		return "CLOSED"
+	}
+}
+
+// Options configures a Breaker. Zero values take the defaults noted.
+type Options struct {
+	Group string // chain name (for logs)
+	Name  string // backend name (for logs)
+
+	Failures       int           // consecutive failures that open the breaker (default 5; -1 = off)
+	ErrorRate      int           // error percentage over Window that opens it (0 = off)
+	MinRequests    int           // requests in Window before ErrorRate applies (default 20)
+	Window         time.Duration // error-rate window (default 30s)
+	OpenFor        time.Duration // cool-down before half-open (default 30s)
+	HalfOpenProbes int           // successful probes needed to close (default 1)
+}
+
+// Snapshot is a point-in-time view of a breaker.
+type Snapshot struct {
+	State       State
+	Reason      string // why it last changed state
+	Consecutive int    // current run of failures
+	Requests    int    // requests in the current window
+	Errors      int    // failures in the current window
+	Since       time.Time
+	Opens       uint64 // times opened since start
+}
+
+// Breaker is a per-backend circuit breaker. It is CLOSED while the backend
+// behaves and OPENS after a run of consecutive failures or when the error
+// rate over a window crosses a threshold; while open, Allow refuses requests
+// so callers fail fast or route around the node. After the cool-down it goes
+// HALF_OPEN and lets probes through one at a time: enough successes close it
+// again, any failure re-opens it. State changes are logged as lifecycle lines.
+// Safe for concurrent use; a nil *Breaker always allows.
+type Breaker struct {
+	opts Options
+
+	mu          sync.Mutex
+	state       State
+	reason      string
+	since       time.Time
+	consecutive int
+	winStart    time.Time
+	requests    int
+	errors      int
+	probing     bool // a half-open probe is in flight
+	probesOK    int
+	opens       uint64
+
+	now func() time.Time
+}
+
+// New returns a closed breaker.
+func New(opts Options) *Breaker {
+	if opts.Failures == 0 {
+		opts.Failures = 5
+	}
+	if opts.MinRequests <= 0 {
+		opts.MinRequests = 20
+	}
+	if opts.Window <= 0 {
+		opts.Window = 30 * time.Second
+	}
+	if opts.OpenFor <= 0 {
+		opts.OpenFor = 30 * time.Second
+	}
+	if opts.HalfOpenProbes <= 0 {
+		opts.HalfOpenProbes = 1
+	}
+	now := time.Now()
+	return &Breaker{opts: opts, since: now, winStart: now, now: time.Now}
+}
+
+// Ready reports whether Allow would currently admit a request, without
+// reserving anything. Used to route around open nodes.
+func (b *Breaker) Ready() bool {
+	if b == nil {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case Open:
+		return !b.now().Before(b.since.Add(b.opts.OpenFor))
+	case HalfOpen:
+		return !b.probing
+	}
+	return true
+}
+
+// Allow reports whether a request may be sent. In HALF_OPEN it reserves the
+// single probe slot; the caller must then report Success, Failure or Cancel.
+func (b *Breaker) Allow() bool {
+	if b == nil {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case Open:
+		if b.now().Before(b.since.Add(b.opts.OpenFor)) {
+			return false
+		}
+		b.setLocked(HalfOpen, "COOLDOWN_ELAPSED")
+		b.probesOK = 0
+		fallthrough
+	case HalfOpen:
+		if b.probing {
+			return false
+		}
+		b.probing = true
+	}
+	return true
+}
+
+// Success records a successful request.
+func (b *Breaker) Success() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.consecutive = 0
+	switch b.state {
+	case HalfOpen:
+		b.probing = false
+		b.probesOK++
+		if b.probesOK >= b.opts.HalfOpenProbes {
+			b.resetWindowLocked(b.now())
+			b.setLocked(Closed, "PROBES_OK")
+		}
+	case Closed:
+		b.countLocked(false)
+	}
+}
+
+// Failure records a failed request and opens the breaker when a threshold
+// is crossed.
+func (b *Breaker) Failure() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.consecutive++
+	switch b.state {
+	case HalfOpen:
+		b.probing = false
+		b.openLocked("PROBE_FAILED")
+	case Closed:
+		b.countLocked(true)
+		if b.opts.Failures > 0 && b.consecutive >= b.opts.Failures {
+			b.openLocked("CONSECUTIVE_FAILURES")
+		} else if b.opts.ErrorRate > 0 && b.requests >= b.opts.MinRequests &&
+			b.errors*100 >= b.opts.ErrorRate*b.requests {
+			b.openLocked("ERROR_RATE")
+		}
+	}
+}
+
+// Cancel releases a request that ended without a verdict (e.g. the client
+// went away), freeing the half-open probe slot.
+func (b *Breaker) Cancel() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	b.probing = false
+	b.mu.Unlock()
+}
+
+// Snapshot returns the current state and counters.
+func (b *Breaker) Snapshot() Snapshot {
+	if b == nil {
+		return Snapshot{}
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return Snapshot{
+		State:       b.state,
+		Reason:      b.reason,
+		Consecutive: b.consecutive,
+		Requests:    b.requests,
+		Errors:      b.errors,
+		Since:       b.since,
+		Opens:       b.opens,
+	}
+}
+
+func (b *Breaker) countLocked(failed bool) {
+	now := b.now()
+	if now.Sub(b.winStart) >= b.opts.Window {
+		b.resetWindowLocked(now)
+	}
+	b.requests++
+	if failed {
+		b.errors++
+	}
+}
+
+func (b *Breaker) resetWindowLocked(now time.Time) {
+	b.winStart, b.requests, b.errors = now, 0, 0
+}
+
+func (b *Breaker) openLocked(reason string) {
+	b.opens++
+	b.setLocked(Open, reason)
+}
+
+func (b *Breaker) setLocked(s State, reason string) {
+	b.state, b.reason, b.since = s, reason, b.now()
+	fields := []applog.Field{
+		applog.F("chain", b.opts.Group),
+		applog.F("backend", b.opts.Name),
+		applog.F("status", s.String()),
+		applog.F("reason", reason),
+		applog.F("consecutive", b.consecutive),
+	}
+	if b.requests > 0 {
+		fields = append(fields, applog.F("errorRate", b.errors*100/b.requests))
+	}
+	if s == Open {
+		fields = append(fields, applog.F("openFor", b.opts.OpenFor.String()))
+	}
+	applog.PrintLifecycle("UPD", "breaker", fields...)
+}
@@ -0,0 +1,163 @@
+package breaker
+
+import (
+	"testing"
+	"time"
+)
+
+// step is one event fed to a breaker: "ok", "fail", "cancel", "allow",
+// "deny" (Allow must refuse) or "wait" (advance the clock by d), followed
+// by the expected state.
+type step struct {
+	op   string
+	d    time.Duration
+	want State
+}
+
+func testBreaker(opts Options) (*Breaker, *time.Time) {
+	b := New(opts)
+	clock := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	b.now = func() time.Time { return clock }
+	b.since, b.winStart = clock, clock
+	return b, &clock
+}
+
+func TestStateMachine(t *testing.T) {
+	tests := []struct {
+		name       string
+		opts       Options
+		steps      []step
+		wantReason string
+		wantOpens  uint64
+	}{
+		{
+			name: "consecutive failures open",
+			opts: Options{Failures: 3},
+			steps: []step{
+				{op: "fail", want: Closed}, {op: "fail", want: Closed},
+				{op: "fail", want: Open}, {op: "deny", want: Open},
+			},
+			wantReason: "CONSECUTIVE_FAILURES", wantOpens: 1,
+		},
+		{
+			name: "success resets the run",
+			opts: Options{Failures: 3},
+			steps: []step{
+				{op: "fail", want: Closed}, {op: "fail", want: Closed}, {op: "ok", want: Closed},
+				{op: "fail", want: Closed}, {op: "fail", want: Closed},
+			},
+		},
+		{
+			name: "failures off",
+			opts: Options{Failures: -1},
+			steps: []step{
+				{op: "fail", want: Closed}, {op: "fail", want: Closed}, {op: "fail", want: Closed},
+				{op: "fail", want: Closed}, {op: "fail", want: Closed}, {op: "fail", want: Closed},
+			},
+		},
+		{
+			name: "error rate after min requests",
+			opts: Options{Failures: -1, ErrorRate: 50, MinRequests: 4},
+			steps: []step{
+				{op: "ok", want: Closed}, {op: "fail", want: Closed}, {op: "ok", want: Closed},
+				{op: "fail", want: Open},
+			},
+			wantReason: "ERROR_RATE", wantOpens: 1,
+		},
+		{
+			name: "error rate window expires",
+			opts: Options{Failures: -1, ErrorRate: 50, MinRequests: 4, Window: 10 * time.Second},
+			steps: []step{
+				{op: "fail", want: Closed}, {op: "fail", want: Closed}, {op: "fail", want: Closed},
+				{op: "wait", d: 10 * time.Second, want: Closed},
+				{op: "fail", want: Closed}, {op: "ok", want: Closed}, {op: "ok", want: Closed},
+				{op: "ok", want: Closed},
+			},
+		},
+		{
+			name: "cool-down then probe closes",
+			opts: Options{Failures: 1, OpenFor: 5 * time.Second},
+			steps: []step{
+				{op: "fail", want: Open},
+				{op: "wait", d: 4 * time.Second, want: Open}, {op: "deny", want: Open},
+				{op: "wait", d: time.Second, want: Open},
+				{op: "allow", want: HalfOpen}, {op: "deny", want: HalfOpen},
+				{op: "ok", want: Closed}, {op: "allow", want: Closed},
+			},
+			wantReason: "PROBES_OK", wantOpens: 1,
+		},
+		{
+			name: "failed probe re-opens",
+			opts: Options{Failures: 1, OpenFor: time.Second},
+			steps: []step{
+				{op: "fail", want: Open}, {op: "wait", d: time.Second, want: Open},
+				{op: "allow", want: HalfOpen}, {op: "fail", want: Open}, {op: "deny", want: Open},
+			},
+			wantReason: "PROBE_FAILED", wantOpens: 2,
+		},
+		{
+			name: "several probes needed",
+			opts: Options{Failures: 1, OpenFor: time.Second, HalfOpenProbes: 2},
+			steps: []step{
+				{op: "fail", want: Open}, {op: "wait", d: time.Second, want: Open},
+				{op: "allow", want: HalfOpen}, {op: "ok", want: HalfOpen},
+				{op: "allow", want: HalfOpen}, {op: "ok", want: Closed},
+			},
+			wantReason: "PROBES_OK", wantOpens: 1,
+		},
+		{
+			name: "cancel frees the probe slot",
+			opts: Options{Failures: 1, OpenFor: time.Second},
+			steps: []step{
+				{op: "fail", want: Open}, {op: "wait", d: time.Second, want: Open},
+				{op: "allow", want: HalfOpen}, {op: "deny", want: HalfOpen},
+				{op: "cancel", want: HalfOpen}, {op: "allow", want: HalfOpen},
+			},
+			wantReason: "COOLDOWN_ELAPSED", wantOpens: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, clock := testBreaker(tt.opts)
+			for i, s := range tt.steps {
+				switch s.op {
+				case "ok":
+					b.Success()
+				case "fail":
+					b.Failure()
+				case "cancel":
+					b.Cancel()
+				case "wait":
+					*clock = clock.Add(s.d)
+				case "allow", "deny":
+					ready := b.Ready()
+					if got := b.Allow(); got != (s.op == "allow") || ready != got {
+						t.Fatalf("step %d: Allow = %v, Ready = %v, want %v", i, got, ready, s.op == "allow")
+					}
+				default:
+					t.Fatalf("step %d: unknown op %q", i, s.op)
+				}
+				if got := b.Snapshot().State; got != s.want {
+					t.Fatalf("step %d (%s): state = %v, want %v", i, s.op, got, s.want)
+				}
+			}
+			snap := b.Snapshot()
+			if snap.Reason != tt.wantReason || snap.Opens != tt.wantOpens {
+				t.Errorf("reason = %q, opens = %d, want %q, %d", snap.Reason, snap.Opens, tt.wantReason, tt.wantOpens)
+			}
+		})
+	}
+}
+
+func TestNilBreaker(t *testing.T) {
+	var b *Breaker
+	if !b.Allow() || !b.Ready() {
+		t.Fatal("nil breaker must allow")
+	}
+	b.Success()
+	b.Failure()
+	b.Cancel()
+	if s := b.Snapshot(); s.State != Closed {
+		t.Fatalf("state = %v, want CLOSED", s.State)
+	}
+}
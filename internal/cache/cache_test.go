@@ -0,0 +1,152 @@
+package cache
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// Every test entry is 200 bytes: 1-byte key, 71-byte body, 128 overhead.
+const entrySize = 200
+
+func entry(now time.Time, ttl time.Duration) *Entry {
+	return &Entry{Status: 200, Body: []byte(strings.Repeat("x", 71)), Stored: now, Expires: now.Add(ttl)}
+}
+
+// op is one cache operation: "set" (with ttl), "hit", "miss" or "wait".
+type op struct {
+	do  string
+	key string
+	d   time.Duration
+}
+
+func TestLRU(t *testing.T) {
+	tests := []struct {
+		name          string
+		maxBytes      int64
+		ops           []op
+		wantEntries   int
+		wantEvictions uint64
+	}{
+		{
+			name:        "hit and miss",
+			maxBytes:    3 * entrySize,
+			ops:         []op{{do: "set", key: "a", d: time.Minute}, {do: "hit", key: "a"}, {do: "miss", key: "b"}},
+			wantEntries: 1,
+		},
+		{
+			name:     "evicts least recently set",
+			maxBytes: 3 * entrySize,
+			ops: []op{
+				{do: "set", key: "a", d: time.Minute}, {do: "set", key: "b", d: time.Minute},
+				{do: "set", key: "c", d: time.Minute}, {do: "set", key: "d", d: time.Minute},
+				{do: "miss", key: "a"}, {do: "hit", key: "b"}, {do: "hit", key: "d"},
+			},
+			wantEntries: 3, wantEvictions: 1,
+		},
+		{
+			name:     "get refreshes recency",
+			maxBytes: 3 * entrySize,
+			ops: []op{
+				{do: "set", key: "a", d: time.Minute}, {do: "set", key: "b", d: time.Minute},
+				{do: "set", key: "c", d: time.Minute}, {do: "hit", key: "a"},
+				{do: "set", key: "d", d: time.Minute},
+				{do: "hit", key: "a"}, {do: "miss", key: "b"},
+			},
+			wantEntries: 3, wantEvictions: 1,
+		},
+		{
+			name:     "replacing a key keeps its size once",
+			maxBytes: 2 * entrySize,
+			ops: []op{
+				{do: "set", key: "a", d: time.Minute}, {do: "set", key: "b", d: time.Minute},
+				{do: "set", key: "a", d: time.Minute}, {do: "set", key: "a", d: time.Minute},
+				{do: "hit", key: "a"}, {do: "hit", key: "b"},
+			},
+			wantEntries: 2,
+		},
+		{
+			name:     "expired entries are dropped",
+			maxBytes: 3 * entrySize,
+			ops: []op{
+				{do: "set", key: "a", d: 2 * time.Second}, {do: "set", key: "b", d: time.Minute},
+				{do: "wait", d: time.Second}, {do: "hit", key: "a"},
+				{do: "wait", d: time.Second}, {do: "miss", key: "a"}, {do: "hit", key: "b"},
+			},
+			wantEntries: 1,
+		},
+		{
+			name:        "entry larger than the cache is ignored",
+			maxBytes:    entrySize - 1,
+			ops:         []op{{do: "set", key: "a", d: time.Minute}, {do: "miss", key: "a"}},
+			wantEntries: 0,
+		},
+		{
+			name:        "disabled cache",
+			maxBytes:    0,
+			ops:         []op{{do: "set", key: "a", d: time.Minute}, {do: "miss", key: "a"}},
+			wantEntries: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New(tt.maxBytes)
+			now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+			c.now = func() time.Time { return now }
+			var hits, misses uint64
+			for i, o := range tt.ops {
+				switch o.do {
+				case "set":
+					c.Set(o.key, entry(now, o.d))
+				case "wait":
+					now = now.Add(o.d)
+				case "hit", "miss":
+					e, ok := c.Get(o.key)
+					if ok != (o.do == "hit") || ok != (e != nil) {
+						t.Fatalf("op %d: Get(%q) = %v, want %s", i, o.key, ok, o.do)
+					}
+					if ok {
+						hits++
+					} else {
+						misses++
+					}
+				default:
+					t.Fatalf("op %d: unknown op %q", i, o.do)
+				}
+			}
+			s := c.Stats()
+			if s.Entries != tt.wantEntries || s.Bytes != int64(tt.wantEntries)*entrySize {
+				t.Errorf("entries = %d (%d bytes), want %d (%d bytes)", s.Entries, s.Bytes, tt.wantEntries, tt.wantEntries*entrySize)
+			}
+			if s.Evictions != tt.wantEvictions || s.Hits != hits || s.Misses != misses {
+				t.Errorf("evictions/hits/misses = %d/%d/%d, want %d/%d/%d",
+					s.Evictions, s.Hits, s.Misses, tt.wantEvictions, hits, misses)
+			}
+		})
+	}
+}
+
+func TestEntrySizeCountsHeaders(t *testing.T) {
+	e := &Entry{Body: []byte("body"), Header: map[string][]string{"X-A": {"12", "345"}}}
+	if got, want := e.size("key"), int64(3+4+128+3+2+3); got != want {
+		t.Errorf("size = %d, want %d", got, want)
+	}
+}
+
+func TestPurge(t *testing.T) {
+	c := New(10 * entrySize)
+	now := time.Now()
+	c.Set("a", entry(now, time.Minute))
+	c.Set("b", entry(now, time.Minute))
+	c.Purge()
+	if s := c.Stats(); s.Entries != 0 || s.Bytes != 0 {
+		t.Fatalf("after Purge: %+v", s)
+	}
+	if _, ok := c.Get("a"); ok {
+		t.Fatal("purged entry still served")
+	}
+	c.Set("a", entry(now, time.Minute))
+	if _, ok := c.Get("a"); !ok {
+		t.Fatal("cache unusable after Purge")
+	}
+}
@@ -12,6 +12,8 @@ import (
 
 	"github.com/oschwald/geoip2-golang"
 	maxminddb "github.com/oschwald/maxminddb-golang"
+
+	"github.com/vNodesV/vProx/internal/metrics"
 )
 
 var (
@@ -171,6 +173,11 @@ var cache sync.Map // ip string -> cacheEntry
 
 const cacheTTL = 10 * time.Minute
 
+// lookups counts Lookup calls by how they were answered: cache, ip2location,
+// geolite2 or none (no database had the IP).
+var lookups = metrics.NewCounter("vprox_geo_lookups_total",
+	"Geo lookups by source (cache, ip2location, geolite2, none).", "source")
+
 func init() {
 	// Periodic cache sweep to evict expired entries and bound memory.
 	go func() {
@@ -211,6 +218,7 @@ func Lookup(ipStr string) (string, string) {
 		return "", ""
 	}
 	if cc, asn, ok := cacheGet(ipStr); ok {
+		lookups.Inc("cache")
 		return cc, asn
 	}
 
@@ -240,6 +248,7 @@ func Lookup(ipStr string) (string, string) {
 
 			if cc != "" || asn != "" {
 				cacheSet(ipStr, cc, asn)
+				lookups.Inc("ip2location")
 				return cc, asn
 			}
 		}
@@ -260,6 +269,9 @@ func Lookup(ipStr string) (string, string) {
 	}
 	if cc != "" || asn != "" {
 		cacheSet(ipStr, cc, asn)
+		lookups.Inc("geolite2")
+	} else {
+		lookups.Inc("none")
 	}
 	return cc, asn
 }
@@ -0,0 +1,309 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	applog "github.com/vNodesV/vProx/internal/logging"
+)
+
+// Target is one backend node to poll. Nodes sharing a Group (the chain) are
+// compared against each other for block-height lag.
+type Target struct {
+	Key       string        // unique id, e.g. "cosmoshub/node-a"
+	Group     string        // chain name
+	Backend   string        // backend name (for logs)
+	StatusURL string        // CometBFT RPC /status URL
+	Interval  time.Duration // poll interval (default 10s)
+	Timeout   time.Duration // per-probe timeout (default 3s)
+	MaxLag    int64         // blocks behind the best peer before ejection (0 = disabled)
+}
+
+// Status is the last observed state of a target.
+type Status struct {
+	Healthy        bool
+	Reason         string // OK | CATCHING_UP | UNREACHABLE | LAGGING | BAD_RESPONSE
+	Error          string
+	Height         int64
+	EarliestHeight int64
+	LatestTime     time.Time
+	CatchingUp     bool
+	Network        string
+	Version        string
+	Lag            int64
+	Checked        time.Time
+}
+
+// Options configures a Checker.
+type Options struct {
+	// Targets returns the current set of nodes to poll. It is called on every
+	// tick so targets may change at runtime (e.g. after a config reload).
+	Targets func() []Target
+
+	// OnChange is called whenever a target flips between healthy and unhealthy.
+	OnChange func(t Target, s Status)
+
+	// Tick is the scheduler resolution (default 1s).
+	Tick time.Duration
+
+	// Client overrides the HTTP client used for probes.
+	Client *http.Client
+}
+
+// Checker polls CometBFT /status on every target and tracks health.
+type Checker struct {
+	opts   Options
+	client *http.Client
+
+	mu      sync.Mutex
+	state   map[string]Status
+	nextDue map[string]time.Time
+	running map[string]bool
+	group   map[string]string // key -> Target.Group
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// New starts a background health checker. Call Close to stop it.
+func New(opts Options) *Checker {
+	if opts.Tick <= 0 {
+		opts.Tick = time.Second
+	}
+	c := &Checker{
+		opts:    opts,
+		client:  opts.Client,
+		state:   make(map[string]Status),
+		nextDue: make(map[string]time.Time),
+		running: make(map[string]bool),
+		group:   make(map[string]string),
+		done:    make(chan struct{}),
+	}
+	if c.client == nil {
+		c.client = &http.Client{Transport: &http.Transport{MaxIdleConnsPerHost: 2, IdleConnTimeout: 90 * time.Second}}
+	}
+	c.wg.Add(1)
+	go c.loop()
+	return c
+}
+
+// Close stops the checker and waits for in-flight probes.
+func (c *Checker) Close() {
+	close(c.done)
+	c.wg.Wait()
+}
+
+// Status returns the last observed status for key. ok is false before the
+// first probe has completed.
+func (c *Checker) Status(key string) (Status, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s, ok := c.state[key]
+	return s, ok
+}
+
+func (c *Checker) loop() {
+	defer c.wg.Done()
+	ticker := time.NewTicker(c.opts.Tick)
+	defer ticker.Stop()
+	c.tick()
+	for {
+		select {
+		case <-ticker.C:
+			c.tick()
+		case <-c.done:
+			return
+		}
+	}
+}
+
+func (c *Checker) tick() {
+	if c.opts.Targets == nil {
+		return
+	}
+	targets := c.opts.Targets()
+	now := time.Now()
+
+	live := make(map[string]bool, len(targets))
+	var due []Target
+	c.mu.Lock()
+	for _, t := range targets {
+		live[t.Key] = true
+		c.group[t.Key] = t.Group
+		if c.running[t.Key] || now.Before(c.nextDue[t.Key]) {
+			continue
+		}
+		c.running[t.Key] = true
+		c.nextDue[t.Key] = now.Add(intervalOf(t))
+		due = append(due, t)
+	}
+	// forget targets removed by a reload
+	for k := range c.nextDue {
+		if !live[k] && !c.running[k] {
+			delete(c.nextDue, k)
+			delete(c.state, k)
+			delete(c.group, k)
+		}
+	}
+	c.mu.Unlock()
+
+	for _, t := range due {
+		c.wg.Add(1)
+		go func(t Target) {
+			defer c.wg.Done()
+			c.probe(t)
+		}(t)
+	}
+}
+
+func intervalOf(t Target) time.Duration {
+	if t.Interval <= 0 {
+		return 10 * time.Second
+	}
+	return t.Interval
+}
+
+func (c *Checker) probe(t Target) {
+	timeout := t.Timeout
+	if timeout <= 0 {
+		timeout = 3 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	s, err := fetchStatus(ctx, c.client, t.StatusURL)
+	s.Checked = time.Now()
+	if err != nil {
+		s.Error = err.Error()
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.running, t.Key)
+
+	prev, seen := c.state[t.Key]
+	s.Lag = c.lagLocked(t, s)
+	switch {
+	case err != nil && errors.Is(err, errBadResponse):
+		s.Healthy, s.Reason = false, "BAD_RESPONSE"
+	case err != nil:
+		s.Healthy, s.Reason = false, "UNREACHABLE"
+	case s.CatchingUp:
+		s.Healthy, s.Reason = false, "CATCHING_UP"
+	case t.MaxLag > 0 && s.Lag > t.MaxLag:
+		s.Healthy, s.Reason = false, "LAGGING"
+	default:
+		s.Healthy, s.Reason = true, "OK"
+	}
+	c.state[t.Key] = s
+
+	// Nodes start out healthy, so the first probe only logs when it fails.
+	if (!seen && !s.Healthy) || (seen && prev.Healthy != s.Healthy) {
+		logChange(t, s)
+		if c.opts.OnChange != nil {
+			c.opts.OnChange(t, s)
+		}
+	}
+}
+
+// lagLocked returns how many blocks s trails the best reachable peer of the
+// same group. Caller holds c.mu.
+func (c *Checker) lagLocked(t Target, s Status) int64 {
+	if s.Height <= 0 {
+		return 0
+	}
+	best := s.Height
+	for k, st := range c.state {
+		if k == t.Key || c.group[k] != t.Group {
+			continue
+		}
+		if st.Error == "" && st.Height > best {
+			best = st.Height
+		}
+	}
+	return best - s.Height
+}
+
+func logChange(t Target, s Status) {
+	status := "HEALTHY"
+	if !s.Healthy {
+		status = "UNHEALTHY"
+	}
+	fields := []applog.Field{
+		applog.F("chain", t.Group),
+		applog.F("backend", t.Backend),
+		applog.F("status", status),
+		applog.F("reason", s.Reason),
+		applog.F("height", s.Height),
+		applog.F("lag", s.Lag),
+	}
+	if s.Error != "" {
+		fields = append(fields, applog.F("error", s.Error))
+	}
+	applog.PrintLifecycle("UPD", "health", fields...)
+}
+
+var errBadResponse = errors.New("bad /status response")
+
+// statusResponse covers both the JSON-RPC wrapped ({"result":{...}}) and the
+// bare /status payloads served by CometBFT / Tendermint.
+type statusResponse struct {
+	Result *statusResult `json:"result"`
+	statusResult
+}
+
+type statusResult struct {
+	NodeInfo struct {
+		Network string `json:"network"`
+		Version string `json:"version"`
+	} `json:"node_info"`
+	SyncInfo struct {
+		LatestBlockHeight   string    `json:"latest_block_height"`
+		LatestBlockTime     time.Time `json:"latest_block_time"`
+		EarliestBlockHeight string    `json:"earliest_block_height"`
+		CatchingUp          bool      `json:"catching_up"`
+	} `json:"sync_info"`
+}
+
+func fetchStatus(ctx context.Context, client *http.Client, url string) (Status, error) {
+	var s Status
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return s, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return s, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return s, fmt.Errorf("%w: http %d", errBadResponse, resp.StatusCode)
+	}
+	var sr statusResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&sr); err != nil {
+		return s, fmt.Errorf("%w: %v", errBadResponse, err)
+	}
+	res := sr.statusResult
+	if sr.Result != nil {
+		res = *sr.Result
+	}
+	h, err := strconv.ParseInt(strings.TrimSpace(res.SyncInfo.LatestBlockHeight), 10, 64)
+	if err != nil {
+		return s, fmt.Errorf("%w: latest_block_height %q", errBadResponse, res.SyncInfo.LatestBlockHeight)
+	}
+	s.Height = h
+	s.EarliestHeight, _ = strconv.ParseInt(strings.TrimSpace(res.SyncInfo.EarliestBlockHeight), 10, 64)
+	s.LatestTime = res.SyncInfo.LatestBlockTime
+	s.CatchingUp = res.SyncInfo.CatchingUp
+	s.Network = res.NodeInfo.Network
+	s.Version = res.NodeInfo.Version
+	return s, nil
+}
@@ -0,0 +1,156 @@
+package health
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// node is a fake CometBFT /status endpoint.
+type node struct {
+	status     int    // HTTP status (0 = 200)
+	body       string // raw body; overrides height/catchingUp
+	height     int64
+	catchingUp bool
+}
+
+func (n *node) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	if n.status != 0 {
+		w.WriteHeader(n.status)
+	}
+	if n.body != "" {
+		fmt.Fprint(w, n.body)
+		return
+	}
+	fmt.Fprintf(w, `{"jsonrpc":"2.0","id":-1,"result":{"node_info":{"network":"test-1","version":"0.38.0"},`+
+		`"sync_info":{"latest_block_height":"%d","earliest_block_height":"1","catching_up":%v}}}`, n.height, n.catchingUp)
+}
+
+func newTestChecker(onChange func(Target, Status)) *Checker {
+	return &Checker{
+		opts:    Options{OnChange: onChange},
+		client:  http.DefaultClient,
+		state:   make(map[string]Status),
+		nextDue: make(map[string]time.Time),
+		running: make(map[string]bool),
+		group:   make(map[string]string),
+	}
+}
+
+// target serves n and registers a target of group for it with c, as a tick
+// would.
+func target(t *testing.T, c *Checker, key, group string, maxLag int64, n *node) Target {
+	t.Helper()
+	srv := httptest.NewServer(n)
+	t.Cleanup(srv.Close)
+	c.group[key] = group
+	return Target{Key: key, Group: group, Backend: key, StatusURL: srv.URL + "/status", MaxLag: maxLag}
+}
+
+func TestProbeReasons(t *testing.T) {
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+	tests := []struct {
+		name       string
+		n          *node
+		url        string
+		wantReason string
+		wantHeight int64
+	}{
+		{"ok", &node{height: 120}, "", "OK", 120},
+		{"bare payload", &node{body: `{"sync_info":{"latest_block_height":"7"}}`}, "", "OK", 7},
+		{"catching up", &node{height: 50, catchingUp: true}, "", "CATCHING_UP", 50},
+		{"http error", &node{status: http.StatusServiceUnavailable}, "", "BAD_RESPONSE", 0},
+		{"not json", &node{body: "<html>"}, "", "BAD_RESPONSE", 0},
+		{"no height", &node{body: `{"result":{"sync_info":{}}}`}, "", "BAD_RESPONSE", 0},
+		{"unreachable", &node{}, down.URL + "/status", "UNREACHABLE", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestChecker(nil)
+			tg := target(t, c, "a", "chain", 0, tt.n)
+			if tt.url != "" {
+				tg.StatusURL = tt.url
+			}
+			c.probe(tg)
+			s, ok := c.Status("a")
+			if !ok {
+				t.Fatal("no status after a probe")
+			}
+			if s.Reason != tt.wantReason || s.Healthy != (tt.wantReason == "OK") || s.Height != tt.wantHeight {
+				t.Errorf("status = %s healthy=%v height=%d (%s), want %s height=%d",
+					s.Reason, s.Healthy, s.Height, s.Error, tt.wantReason, tt.wantHeight)
+			}
+		})
+	}
+}
+
+func TestLagEjection(t *testing.T) {
+	tests := []struct {
+		name       string
+		maxLag     int64
+		wantReason string
+	}{
+		{"beyond max lag", 3, "LAGGING"},
+		{"at max lag", 5, "OK"},
+		{"lag check off", 0, "OK"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestChecker(nil)
+			best := target(t, c, "best", "chain", tt.maxLag, &node{height: 105})
+			other := target(t, c, "other", "other-chain", tt.maxLag, &node{height: 500})
+			slow := target(t, c, "slow", "chain", tt.maxLag, &node{height: 100})
+			c.probe(best)
+			c.probe(other)
+			c.probe(slow)
+			s, _ := c.Status("slow")
+			if s.Lag != 5 || s.Reason != tt.wantReason {
+				t.Errorf("slow: lag=%d reason=%s, want lag=5 reason=%s", s.Lag, s.Reason, tt.wantReason)
+			}
+			if s, _ := c.Status("best"); s.Lag != 0 || !s.Healthy {
+				t.Errorf("best: lag=%d healthy=%v", s.Lag, s.Healthy)
+			}
+		})
+	}
+}
+
+func TestLagIgnoresFailedPeers(t *testing.T) {
+	c := newTestChecker(nil)
+	peer := &node{height: 200}
+	peerT := target(t, c, "peer", "chain", 3, peer)
+	selfT := target(t, c, "self", "chain", 3, &node{height: 100})
+	c.probe(peerT)
+	peer.status = http.StatusBadGateway // the peer's last probe fails
+	c.probe(peerT)
+	c.probe(selfT)
+	if s, _ := c.Status("self"); s.Lag != 0 || !s.Healthy {
+		t.Errorf("self: lag=%d reason=%s, want no lag behind a failed peer", s.Lag, s.Reason)
+	}
+}
+
+func TestOnChange(t *testing.T) {
+	var changes []string
+	c := newTestChecker(func(_ Target, s Status) { changes = append(changes, s.Reason) })
+	n := &node{height: 10}
+	tg := target(t, c, "a", "chain", 0, n)
+
+	c.probe(tg) // first probe healthy: silent
+	n.catchingUp = true
+	c.probe(tg)
+	c.probe(tg) // still unhealthy: silent
+	n.catchingUp = false
+	c.probe(tg)
+	if got := fmt.Sprint(changes); got != "[CATCHING_UP OK]" {
+		t.Errorf("changes = %s, want [CATCHING_UP OK]", got)
+	}
+
+	changes = nil
+	c2 := newTestChecker(func(_ Target, s Status) { changes = append(changes, s.Reason) })
+	c2.probe(target(t, c2, "b", "chain", 0, &node{status: http.StatusInternalServerError}))
+	if got := fmt.Sprint(changes); got != "[BAD_RESPONSE]" {
+		t.Errorf("first unhealthy probe: changes = %s, want [BAD_RESPONSE]", got)
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -16,6 +17,7 @@ import (
 
 	"github.com/vNodesV/vProx/internal/geo"
 	applog "github.com/vNodesV/vProx/internal/logging"
+	"github.com/vNodesV/vProx/internal/metrics"
 	"golang.org/x/time/rate"
 )
 
@@ -41,6 +43,14 @@ type IPLimiter struct {
 	// limiter pool per ip.
 	pool sync.Map // ip(string) -> *rate.Limiter
 
+	// API keys: authenticated requests use a bucket per key ID instead.
+	keyFunc KeyFunc
+	keyPool sync.Map // key id(string) -> *keyBucket
+
+	// external rate policies (e.g. country/ASN rules): bucket per policy+IP.
+	policyFunc PolicyFunc
+	policyPool sync.Map // name|ip(string) -> *keyBucket
+
 	// auto-quarantine
 	autoRule    *AutoRule
 	autoState   sync.Map // ip -> *strikeState
@@ -69,10 +79,18 @@ type IPLimiter struct {
 	// JSONL filter
 	logImportantOnly bool
 
+	// response body of 429s (default http.Error)
+	errorWriter ErrorWriter
+
 	// time source
 	now func() time.Time
 }
 
+type keyBucket struct {
+	spec RateSpec
+	lim  *rate.Limiter
+}
+
 type strikeState struct {
 	mu        sync.Mutex
 	count     int
@@ -92,6 +110,7 @@ type Option func(*IPLimiter)
 //	  "event": "429|auto-override-add|auto-override-expire|allow-sample|...",
 //	  "reason": "429|auto-override-add|auto-override-expire|allow-sample|...",
 //	  "ip": "192.0.2.1",
+//	  "key_id": "partner-a",    // API key requests only (never the secret)
 //	  "country": "US",
 //	  "asn": "AS1234",
 //	  "method": "GET",
@@ -100,7 +119,8 @@ type Option func(*IPLimiter)
 //	  "user_agent": "curl/7.64.1",
 //	  "ua": "curl/7.64.1",
 //	  "rps": 25.0,
-//	  "burst": 100
+//	  "burst": 100,
+//	  "detail": "dial_peers"   // policy events only (LogEvent)
 //	}
 //
 // Mirror log (when enabled) writes to main log in standard format:
@@ -166,6 +186,44 @@ func WithMirrorToMainLog() Option {
 	return func(l *IPLimiter) { l.mirrorMain = true }
 }
 
+// ErrorWriter writes the error response of a refused request (status 429).
+// Headers such as Retry-After and X-RateLimit-* are already set.
+type ErrorWriter func(w http.ResponseWriter, r *http.Request, status int, msg string)
+
+// WithErrorWriter renders 429 responses with f instead of plain text, so
+// they match the proxy's other error bodies.
+func WithErrorWriter(f ErrorWriter) Option {
+	return func(l *IPLimiter) { l.errorWriter = f }
+}
+
+// KeyFunc reports the API key r authenticated with: its ID (bucket name,
+// logged) and rate. ok is false for anonymous requests.
+type KeyFunc func(r *http.Request) (id string, spec RateSpec, ok bool)
+
+// WithKeyFunc limits authenticated requests per API key instead of per IP.
+// Keyed requests always get 429 on overflow and skip auto-quarantine.
+func WithKeyFunc(f KeyFunc) Option {
+	return func(l *IPLimiter) { l.keyFunc = f }
+}
+
+// Policy is a rate a rule outside the limiter assigns to a request's client
+// (e.g. a country or ASN rule). Each client gets its own bucket per policy.
+type Policy struct {
+	Name   string // bucket namespace, logged as the event detail
+	Reason string // event logged on overflow instead of "429"
+	Spec   RateSpec
+}
+
+// PolicyFunc reports the Policy that applies to r, if any.
+type PolicyFunc func(r *http.Request) (Policy, bool)
+
+// WithPolicyFunc applies external rate policies. They are strict (429 on
+// overflow) and rank below per-IP overrides and quarantines and above the
+// defaults; API key requests ignore them.
+func WithPolicyFunc(f PolicyFunc) Option {
+	return func(l *IPLimiter) { l.policyFunc = f }
+}
+
 // New creates an IPLimiter with global defaults and per-IP overrides.
 func New(defaults RateSpec, overrides map[string]RateSpec, opts ...Option) *IPLimiter {
 	l := &IPLimiter{
@@ -235,6 +293,23 @@ func (l *IPLimiter) Middleware(next http.Handler) http.Handler {
 
 		ip := l.clientIP(r)
 
+		// API key traffic: per-key bucket, strict.
+		if id, spec, ok := l.keyOf(r); ok {
+			if !l.keyLimiter(id, spec).Allow() {
+				l.logAccessLimited(ip, r, "RATE_LIMIT_EXCEEDED")
+				w.Header().Set("Retry-After", "1")
+				w.Header().Set("X-RateLimit-Policy", formatKeyPolicy(id, spec))
+				w.Header().Set("X-RateLimit-Status", "blocked")
+				l.writeError(w, r, http.StatusTooManyRequests, "rate limit exceeded")
+				l.logEvent(ip, r, "429")
+				return
+			}
+			w.Header().Set("X-RateLimit-Status", "ok")
+			r = r.WithContext(context.WithValue(r.Context(), ctxStatusKey, "ok"))
+			next.ServeHTTP(w, r)
+			return
+		}
+
 		// expire any auto override
 		l.autoMaybeExpire(ip, r)
 		// count for auto rule
@@ -266,7 +341,7 @@ func (l *IPLimiter) Middleware(next http.Handler) http.Handler {
 				w.Header().Set("Retry-After", "1")
 				w.Header().Set("X-RateLimit-Policy", l.policyString(ip))
 				w.Header().Set("X-RateLimit-Status", "blocked")
-				http.Error(w, "rate limit exceeded", http.StatusTooManyRequests)
+				l.writeError(w, r, http.StatusTooManyRequests, "rate limit exceeded")
 				l.logEvent(ip, r, "429")
 				return
 			}
@@ -277,6 +352,24 @@ func (l *IPLimiter) Middleware(next http.Handler) http.Handler {
 			return
 		}
 
+		// External policy (country/ASN rule): own bucket, strict.
+		if pol, ok := l.policyOf(r); ok {
+			if !l.policyLimiter(pol, ip).Allow() {
+				l.logAccessLimited(ip, r, "RATE_LIMIT_EXCEEDED")
+				w.Header().Set("Retry-After", "1")
+				w.Header().Set("X-RateLimit-Policy", formatNamedPolicy(pol.Name, pol.Spec))
+				w.Header().Set("X-RateLimit-Status", "blocked")
+				l.writeError(w, r, http.StatusTooManyRequests, "rate limit exceeded")
+				l.logEventDetail(ip, r, pol.reason(), pol.Name)
+				return
+			}
+			w.Header().Set("X-RateLimit-Status", "limited")
+			r = r.WithContext(context.WithValue(r.Context(), ctxStatusKey, "limited"))
+			next.ServeHTTP(w, r)
+			l.maybeLogAllow(ip, r)
+			return
+		}
+
 		// DEFAULTS: either Allow() (drop) or Wait() (smooth)
 		if l.enforceDefaults {
 			if !lim.Allow() {
@@ -284,7 +377,7 @@ func (l *IPLimiter) Middleware(next http.Handler) http.Handler {
 				w.Header().Set("Retry-After", "1")
 				w.Header().Set("X-RateLimit-Policy", l.policyString(ip))
 				w.Header().Set("X-RateLimit-Status", "blocked")
-				http.Error(w, "rate limit exceeded", http.StatusTooManyRequests)
+				l.writeError(w, r, http.StatusTooManyRequests, "rate limit exceeded")
 				l.logEvent(ip, r, "429")
 				return
 			}
@@ -300,7 +393,7 @@ func (l *IPLimiter) Middleware(next http.Handler) http.Handler {
 		if err := lim.Wait(r.Context()); err != nil {
 			l.logAccessLimited(ip, r, "REQUEST_CANCELED")
 			w.Header().Set("X-RateLimit-Status", "blocked")
-			http.Error(w, "request canceled", http.StatusTooManyRequests)
+			l.writeError(w, r, http.StatusTooManyRequests, "request canceled")
 			l.logEvent(ip, r, "wait-canceled")
 			return
 		}
@@ -311,22 +404,99 @@ func (l *IPLimiter) Middleware(next http.Handler) http.Handler {
 	})
 }
 
+func (l *IPLimiter) writeError(w http.ResponseWriter, r *http.Request, status int, msg string) {
+	if l.errorWriter != nil {
+		l.errorWriter(w, r, status, msg)
+		return
+	}
+	http.Error(w, msg, status)
+}
+
+// Charge takes n additional tokens from the bucket of r's client, on top of
+// the one Middleware already took (e.g. the remaining calls of a JSON-RPC
+// batch). n is capped at the bucket's burst so a single request can always
+// pass on a full bucket. On refusal it logs like a regular 429 and returns
+// false; the caller writes the response.
+func (l *IPLimiter) Charge(r *http.Request, n int) bool {
+	if n <= 0 {
+		return true
+	}
+	ip := l.clientIP(r)
+	lim := l.limiterFor(ip)
+	strict := l.hasOverride(ip) || l.enforceDefaults
+	reason, detail := "429", ""
+	if id, spec, ok := l.keyOf(r); ok {
+		lim, strict = l.keyLimiter(id, spec), true
+	} else if pol, ok := l.policyOf(r); ok && !l.hasOverride(ip) {
+		lim, strict = l.policyLimiter(pol, ip), true
+		reason, detail = pol.reason(), pol.Name
+	}
+	if b := lim.Burst(); n > b {
+		n = b
+	}
+	if strict {
+		if lim.AllowN(time.Now(), n) {
+			return true
+		}
+		l.logAccessLimited(ip, r, "RATE_LIMIT_EXCEEDED")
+		l.logEventDetail(ip, r, reason, detail)
+		return false
+	}
+	if err := lim.WaitN(r.Context(), n); err != nil {
+		l.logAccessLimited(ip, r, "REQUEST_CANCELED")
+		l.logEvent(ip, r, "wait-canceled")
+		return false
+	}
+	return true
+}
+
 // SetOverride adds/updates a per-IP RateSpec at runtime (resets cached limiter).
+// It replaces an active auto-quarantine of ip.
 func (l *IPLimiter) SetOverride(ip string, spec RateSpec) error {
 	if net.ParseIP(ip) == nil {
 		return errors.New("invalid ip")
 	}
 	l.overrides.Store(ip, spec)
+	l.autoExpiry.Delete(ip)
 	l.pool.Delete(ip)
 	return nil
 }
 
-// DeleteOverride removes a per-IP override (falls back to defaults).
+// DeleteOverride removes a per-IP override or quarantine (falls back to defaults).
 func (l *IPLimiter) DeleteOverride(ip string) {
 	l.overrides.Delete(ip)
+	l.autoExpiry.Delete(ip)
 	l.pool.Delete(ip)
 }
 
+// Override is a per-IP RateSpec in effect; Auto marks an auto-quarantine,
+// which is removed at Expires.
+type Override struct {
+	IP      string
+	Spec    RateSpec
+	Auto    bool
+	Expires time.Time
+}
+
+// Overrides returns the manual overrides and active quarantines, sorted by IP.
+func (l *IPLimiter) Overrides() []Override {
+	var out []Override
+	l.overrides.Range(func(key, val any) bool {
+		o := Override{IP: key.(string), Spec: val.(RateSpec)}
+		if exp, ok := l.autoExpiry.Load(key); ok {
+			o.Auto = true
+			o.Expires = exp.(time.Time)
+		}
+		out = append(out, o)
+		return true
+	})
+	sort.Slice(out, func(i, j int) bool { return out[i].IP < out[j].IP })
+	return out
+}
+
+// Defaults returns the default RateSpec.
+func (l *IPLimiter) Defaults() RateSpec { return l.defaults }
+
 // Close releases resources (e.g., log file).
 func (l *IPLimiter) Close() error {
 	close(l.sweepDone)
@@ -368,6 +538,14 @@ func (l *IPLimiter) sweep() {
 		return true
 	})
 
+	// Policy buckets are per IP; drop the refilled (idle) ones.
+	l.policyPool.Range(func(key, val any) bool {
+		if b := val.(*keyBucket); b.lim.Tokens() >= float64(b.lim.Burst()) {
+			l.policyPool.Delete(key)
+		}
+		return true
+	})
+
 	// Evict stale allow-log timestamps
 	l.lastAllowLog.Range(func(key, val any) bool {
 		ts := val.(time.Time)
@@ -417,6 +595,58 @@ func (l *IPLimiter) limiterFor(ip string) *rate.Limiter {
 	return actual.(*rate.Limiter)
 }
 
+func (l *IPLimiter) keyOf(r *http.Request) (string, RateSpec, bool) {
+	if l.keyFunc == nil {
+		return "", RateSpec{}, false
+	}
+	return l.keyFunc(r)
+}
+
+// keyLimiter returns the bucket of API key id, replacing it when the key's
+// rate changed (keys file reloaded).
+func (l *IPLimiter) keyLimiter(id string, spec RateSpec) *rate.Limiter {
+	if v, ok := l.keyPool.Load(id); ok && v.(*keyBucket).spec == spec {
+		return v.(*keyBucket).lim
+	}
+	burst := spec.Burst
+	if burst < 1 {
+		burst = 1
+	}
+	b := &keyBucket{spec: spec, lim: rate.NewLimiter(rate.Limit(spec.RPS), burst)}
+	l.keyPool.Store(id, b)
+	return b.lim
+}
+
+func (l *IPLimiter) policyOf(r *http.Request) (Policy, bool) {
+	if l.policyFunc == nil {
+		return Policy{}, false
+	}
+	return l.policyFunc(r)
+}
+
+func (p Policy) reason() string {
+	if p.Reason == "" {
+		return "429"
+	}
+	return p.Reason
+}
+
+// policyLimiter returns ip's bucket under pol, replacing it when the
+// policy's rate changed.
+func (l *IPLimiter) policyLimiter(pol Policy, ip string) *rate.Limiter {
+	name := pol.Name + "|" + ip
+	if v, ok := l.policyPool.Load(name); ok && v.(*keyBucket).spec == pol.Spec {
+		return v.(*keyBucket).lim
+	}
+	burst := pol.Spec.Burst
+	if burst < 1 {
+		burst = 1
+	}
+	b := &keyBucket{spec: pol.Spec, lim: rate.NewLimiter(rate.Limit(pol.Spec.RPS), burst)}
+	l.policyPool.Store(name, b)
+	return b.lim
+}
+
 func (l *IPLimiter) clientIP(r *http.Request) string {
 	// 0) Cloudflare direct hint
 	if l.trustProxy {
@@ -524,6 +754,14 @@ func formatPolicy(ip string, spec RateSpec) string {
 	return "ip=" + ip + "; rps=" + formatFloat(spec.RPS) + "; burst=" + itoa(spec.Burst)
 }
 
+func formatKeyPolicy(id string, spec RateSpec) string {
+	return "key=" + id + "; rps=" + formatFloat(spec.RPS) + "; burst=" + itoa(spec.Burst)
+}
+
+func formatNamedPolicy(name string, spec RateSpec) string {
+	return "policy=" + name + "; rps=" + formatFloat(spec.RPS) + "; burst=" + itoa(spec.Burst)
+}
+
 func formatFloat(f float64) string {
 	return strings.TrimRight(strings.TrimRight(strconvFormatFloat(f, 'f', 2, 64), "0"), ".")
 }
@@ -545,6 +783,12 @@ func intToBytes(i int) []byte {
 	return []byte(strconv.Itoa(i))
 }
 
+// events counts limiter decisions ("429", "auto-override-add",
+// "auto-override-expire", "wait-canceled", policy denials) whether or not
+// they are logged.
+var events = metrics.NewCounter("vprox_limiter_events_total",
+	"Rate limiter events by reason (429, auto-override-add, auto-override-expire, wait-canceled, rpc-*).", "reason")
+
 // --------- Auto-quarantine ----------
 
 func (l *IPLimiter) autoMaybeFlag(ip string, r *http.Request) {
@@ -606,12 +850,20 @@ func (l *IPLimiter) maybeLogAllow(ip string, r *http.Request) {
 	l.logEvent(ip, r, "allow-sample")
 }
 
+// LogEvent records a policy decision taken outside the limiter (e.g.
+// "rpc-method-denied") in rate-limit.jsonl and the main log, in the same
+// format as limiter events. detail is optional context such as the method.
+func (l *IPLimiter) LogEvent(r *http.Request, reason, detail string) {
+	l.logEventDetail(l.clientIP(r), r, reason, detail)
+}
+
 func (l *IPLimiter) shouldLog(reason string) bool {
 	if !l.logImportantOnly {
 		return true
 	}
 	switch reason {
-	case "429", "auto-override-add", "auto-override-expire", "wait-canceled":
+	case "429", "auto-override-add", "auto-override-expire", "wait-canceled", "rpc-method-denied", "rpc-batch-too-large",
+		"api-key-denied", "access-denied", "geo-denied", "geo-limited":
 		return true
 	default:
 		return false
@@ -620,9 +872,9 @@ func (l *IPLimiter) shouldLog(reason string) bool {
 
 func (l *IPLimiter) logEventLevel(reason string) string {
 	switch reason {
-	case "429", "wait-canceled":
+	case "429", "wait-canceled", "geo-limited":
 		return "ERROR"
-	case "auto-override-add":
+	case "auto-override-add", "rpc-method-denied", "rpc-batch-too-large", "api-key-denied", "access-denied", "geo-denied":
 		return "WARN"
 	case "auto-override-expire", "allow-sample":
 		return "INFO"
@@ -632,6 +884,13 @@ func (l *IPLimiter) logEventLevel(reason string) string {
 }
 
 func (l *IPLimiter) logEvent(ip string, r *http.Request, reason string) {
+	l.logEventDetail(ip, r, reason, "")
+}
+
+func (l *IPLimiter) logEventDetail(ip string, r *http.Request, reason, detail string) {
+	if reason != "allow-sample" {
+		events.Inc(reason)
+	}
 	if !l.shouldLog(reason) {
 		return
 	}
@@ -651,6 +910,12 @@ func (l *IPLimiter) logEvent(ip string, r *http.Request, reason string) {
 	if o, ok := l.overrides.Load(ip); ok {
 		spec = o.(RateSpec)
 	}
+	keyID, keySpec, keyed := l.keyOf(r)
+	if keyed {
+		spec = keySpec
+	} else if pol, ok := l.policyOf(r); ok && !l.hasOverride(ip) {
+		spec = pol.Spec
+	}
 
 	ts := l.now().UTC()
 	level := l.logEventLevel(reason)
@@ -663,6 +928,7 @@ func (l *IPLimiter) logEvent(ip string, r *http.Request, reason string) {
 		Reason    string  `json:"reason"`
 		RequestID string  `json:"request_id,omitempty"`
 		IP        string  `json:"ip"`
+		KeyID     string  `json:"key_id,omitempty"`
 		Country   string  `json:"country,omitempty"`
 		ASN       string  `json:"asn,omitempty"`
 		Method    string  `json:"method"`
@@ -672,6 +938,7 @@ func (l *IPLimiter) logEvent(ip string, r *http.Request, reason string) {
 		UA        string  `json:"ua,omitempty"`
 		RPS       float64 `json:"rps"`
 		Burst     int     `json:"burst"`
+		Detail    string  `json:"detail,omitempty"`
 	}
 	ua := r.Header.Get("User-Agent")
 	requestID := applog.RequestIDFrom(r)
@@ -682,6 +949,7 @@ func (l *IPLimiter) logEvent(ip string, r *http.Request, reason string) {
 		Reason:    reason,
 		RequestID: requestID,
 		IP:        ip,
+		KeyID:     keyID,
 		Country:   country,
 		ASN:       asn,
 		Method:    r.Method,
@@ -691,6 +959,7 @@ func (l *IPLimiter) logEvent(ip string, r *http.Request, reason string) {
 		UA:        ua,
 		RPS:       spec.RPS,
 		Burst:     spec.Burst,
+		Detail:    detail,
 	}
 	if b, err := json.Marshal(rec); err == nil {
 		l.logger.Println(string(b))
@@ -715,9 +984,15 @@ func (l *IPLimiter) logEvent(ip string, r *http.Request, reason string) {
 			applog.F("burst", spec.Burst),
 			applog.F("ua", ua),
 		}
-		if reason == "429" || reason == "wait-canceled" {
+		if keyed {
+			fields = append(fields, applog.F("key", keyID))
+		}
+		if reason == "429" || reason == "wait-canceled" || reason == "geo-limited" {
 			fields = append(fields, applog.F("status", "limited"))
 		}
+		if detail != "" {
+			fields = append(fields, applog.F("detail", detail))
+		}
 		applog.Print(level, "limiter", limiterEventMessage(reason),
 			fields...,
 		)
@@ -791,6 +1066,10 @@ func limiterReasonLabel(reason string) string {
 		return "AUTO_OVERRIDE_EXPIRE"
 	case "allow-sample":
 		return "ALLOW_SAMPLE"
+	case "rpc-method-denied":
+		return "RPC_METHOD_DENIED"
+	case "rpc-batch-too-large":
+		return "RPC_BATCH_TOO_LARGE"
 	default:
 		v := strings.ToUpper(strings.TrimSpace(reason))
 		v = strings.ReplaceAll(v, "-", "_")
@@ -813,6 +1092,10 @@ func limiterEventMessage(reason string) string {
 		return "auto override expired"
 	case "allow-sample":
 		return "allow sample"
+	case "rpc-method-denied":
+		return "rpc method denied"
+	case "rpc-batch-too-large":
+		return "rpc batch too large"
 	default:
 		v := strings.TrimSpace(reason)
 		if v == "" {
@@ -0,0 +1,345 @@
+// Package metrics is a small Prometheus registry: labeled counters, gauges
+// and histograms plus function-backed values, exposed in the Prometheus text
+// format (0.0.4). Metrics are package-level variables registered on creation,
+// so any package can instrument itself without wiring.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefBuckets suits request latencies in seconds.
+var DefBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30}
+
+// ExponentialBuckets returns n buckets starting at start, each factor times
+// the previous one.
+func ExponentialBuckets(start, factor float64, n int) []float64 {
+	out := make([]float64, n)
+	for i := range out {
+		out[i] = start
+		start *= factor
+	}
+	return out
+}
+
+type collector interface {
+	desc() *desc
+	write(w *bufio.Writer)
+}
+
+var (
+	regMu    sync.Mutex
+	registry = map[string]collector{}
+)
+
+func register(c collector) {
+	regMu.Lock()
+	defer regMu.Unlock()
+	name := c.desc().name
+	if _, dup := registry[name]; dup {
+		panic("metrics: duplicate metric " + name)
+	}
+	registry[name] = c
+}
+
+type desc struct {
+	name   string
+	help   string
+	typ    string // counter | gauge | histogram
+	labels []string
+}
+
+func (d *desc) header(w *bufio.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", d.name, escapeHelp(d.help), d.name, d.typ)
+}
+
+func (d *desc) key(lvs []string) string {
+	if len(lvs) != len(d.labels) {
+		panic(fmt.Sprintf("metrics: %s wants %d label values, got %d", d.name, len(d.labels), len(lvs)))
+	}
+	return strings.Join(lvs, "\xff")
+}
+
+// labelPairs renders {a="x",b="y"} with extra appended (e.g. le).
+func (d *desc) labelPairs(lvs []string, extra ...string) string {
+	if len(d.labels) == 0 && len(extra) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, l := range d.labels {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(l + `="` + escapeLabel(lvs[i]) + `"`)
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		if b.Len() > 1 {
+			b.WriteByte(',')
+		}
+		b.WriteString(extra[i] + `="` + escapeLabel(extra[i+1]) + `"`)
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+// --------- Counter / Gauge ---------
+
+type series struct {
+	lvs []string
+	v   float64
+}
+
+type valueVec struct {
+	d      desc
+	mu     sync.Mutex
+	series map[string]*series
+}
+
+func (v *valueVec) desc() *desc { return &v.d }
+
+func (v *valueVec) add(delta float64, set bool, lvs []string) {
+	k := v.d.key(lvs)
+	v.mu.Lock()
+	s, ok := v.series[k]
+	if !ok {
+		s = &series{lvs: append([]string(nil), lvs...)}
+		v.series[k] = s
+	}
+	if set {
+		s.v = delta
+	} else {
+		s.v += delta
+	}
+	v.mu.Unlock()
+}
+
+func (v *valueVec) write(w *bufio.Writer) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if len(v.series) == 0 && len(v.d.labels) > 0 {
+		return
+	}
+	v.d.header(w)
+	if len(v.d.labels) == 0 && len(v.series) == 0 {
+		fmt.Fprintf(w, "%s 0\n", v.d.name)
+		return
+	}
+	for _, k := range sortedKeys(v.series) {
+		s := v.series[k]
+		fmt.Fprintf(w, "%s%s %s\n", v.d.name, v.d.labelPairs(s.lvs), formatFloat(s.v))
+	}
+}
+
+// Counter is a monotonically increasing value per label set.
+type Counter struct{ v valueVec }
+
+// NewCounter registers a counter with the given label names.
+func NewCounter(name, help string, labels ...string) *Counter {
+	c := &Counter{valueVec{d: desc{name: name, help: help, typ: "counter", labels: labels}, series: map[string]*series{}}}
+	register(&c.v)
+	return c
+}
+
+// Inc adds 1 for the label values lvs.
+func (c *Counter) Inc(lvs ...string) { c.v.add(1, false, lvs) }
+
+// Add adds delta (>= 0) for the label values lvs.
+func (c *Counter) Add(delta float64, lvs ...string) {
+	if delta < 0 {
+		return
+	}
+	c.v.add(delta, false, lvs)
+}
+
+// Gauge is a value that can go up and down per label set.
+type Gauge struct{ v valueVec }
+
+// NewGauge registers a gauge with the given label names.
+func NewGauge(name, help string, labels ...string) *Gauge {
+	g := &Gauge{valueVec{d: desc{name: name, help: help, typ: "gauge", labels: labels}, series: map[string]*series{}}}
+	register(&g.v)
+	return g
+}
+
+// Set, Add, Inc and Dec change the value for the label values lvs.
+func (g *Gauge) Set(val float64, lvs ...string)   { g.v.add(val, true, lvs) }
+func (g *Gauge) Add(delta float64, lvs ...string) { g.v.add(delta, false, lvs) }
+func (g *Gauge) Inc(lvs ...string)                { g.v.add(1, false, lvs) }
+func (g *Gauge) Dec(lvs ...string)                { g.v.add(-1, false, lvs) }
+
+// --------- Function-backed values ---------
+
+// Emit reports one sample of a function-backed metric.
+type Emit func(val float64, lvs ...string)
+
+type funcVec struct {
+	d  desc
+	fn func(Emit)
+}
+
+func (f *funcVec) desc() *desc { return &f.d }
+
+func (f *funcVec) write(w *bufio.Writer) {
+	type sample struct {
+		lvs []string
+		v   float64
+	}
+	var out []sample
+	f.fn(func(val float64, lvs ...string) {
+		f.d.key(lvs) // label count check
+		out = append(out, sample{append([]string(nil), lvs...), val})
+	})
+	if len(out) == 0 {
+		return
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return strings.Join(out[i].lvs, "\xff") < strings.Join(out[j].lvs, "\xff")
+	})
+	f.d.header(w)
+	for _, s := range out {
+		fmt.Fprintf(w, "%s%s %s\n", f.d.name, f.d.labelPairs(s.lvs), formatFloat(s.v))
+	}
+}
+
+// NewGaugeFunc registers a gauge whose samples are produced by fn at scrape
+// time (e.g. in-flight requests read from live state).
+func NewGaugeFunc(name, help string, fn func(Emit), labels ...string) {
+	register(&funcVec{d: desc{name: name, help: help, typ: "gauge", labels: labels}, fn: fn})
+}
+
+// NewCounterFunc is NewGaugeFunc for values that only grow (e.g. counters
+// kept by another component).
+func NewCounterFunc(name, help string, fn func(Emit), labels ...string) {
+	register(&funcVec{d: desc{name: name, help: help, typ: "counter", labels: labels}, fn: fn})
+}
+
+// --------- Histogram ---------
+
+type histSeries struct {
+	lvs    []string
+	counts []uint64 // per bucket, not cumulative
+	count  uint64
+	sum    float64
+}
+
+// Histogram counts observations into cumulative buckets per label set.
+type Histogram struct {
+	d       desc
+	buckets []float64
+	mu      sync.Mutex
+	series  map[string]*histSeries
+}
+
+// NewHistogram registers a histogram with the given upper bounds (sorted;
+// +Inf is implicit) and label names.
+func NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	b := append([]float64(nil), buckets...)
+	sort.Float64s(b)
+	h := &Histogram{d: desc{name: name, help: help, typ: "histogram", labels: labels}, buckets: b, series: map[string]*histSeries{}}
+	register(h)
+	return h
+}
+
+func (h *Histogram) desc() *desc { return &h.d }
+
+// Observe records val for the label values lvs.
+func (h *Histogram) Observe(val float64, lvs ...string) {
+	k := h.d.key(lvs)
+	i := sort.SearchFloat64s(h.buckets, val) // first bound >= val
+	h.mu.Lock()
+	s, ok := h.series[k]
+	if !ok {
+		s = &histSeries{lvs: append([]string(nil), lvs...), counts: make([]uint64, len(h.buckets)+1)}
+		h.series[k] = s
+	}
+	s.counts[i]++
+	s.count++
+	s.sum += val
+	h.mu.Unlock()
+}
+
+func (h *Histogram) write(w *bufio.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.series) == 0 {
+		return
+	}
+	h.d.header(w)
+	for _, k := range sortedKeys(h.series) {
+		s := h.series[k]
+		var cum uint64
+		for i, le := range h.buckets {
+			cum += s.counts[i]
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.d.name, h.d.labelPairs(s.lvs, "le", formatFloat(le)), cum)
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.d.name, h.d.labelPairs(s.lvs, "le", "+Inf"), s.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.d.name, h.d.labelPairs(s.lvs), formatFloat(s.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.d.name, h.d.labelPairs(s.lvs), s.count)
+	}
+}
+
+// --------- Exposition ---------
+
+// Write renders every registered metric in the Prometheus text format.
+func Write(out io.Writer) error {
+	regMu.Lock()
+	cs := make([]collector, 0, len(registry))
+	for _, c := range registry {
+		cs = append(cs, c)
+	}
+	regMu.Unlock()
+	sort.Slice(cs, func(i, j int) bool { return cs[i].desc().name < cs[j].desc().name })
+
+	w := bufio.NewWriter(out)
+	for _, c := range cs {
+		c.write(w)
+	}
+	return w.Flush()
+}
+
+// Handler serves Write at any path (mount it at /metrics).
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
+		_ = Write(w)
+	})
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeLabel(s string) string { return labelEscaper.Replace(s) }
+func escapeHelp(s string) string  { return helpEscaper.Replace(s) }
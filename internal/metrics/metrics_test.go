@@ -0,0 +1,166 @@
+package metrics
+
+import (
+	"bufio"
+	"math"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// render returns the exposition of a single collector.
+func render(c collector) string {
+	var b strings.Builder
+	w := bufio.NewWriter(&b)
+	c.write(w)
+	_ = w.Flush()
+	return b.String()
+}
+
+func TestCounterAndGauge(t *testing.T) {
+	c := NewCounter("test_requests_total", "Requests.\nSecond line", "chain", "code")
+	c.Inc("a", "200")
+	c.Inc("a", "200")
+	c.Add(2.5, "b", "502")
+	c.Add(-1, "b", "502") // ignored
+	c.Inc(`q"\`+"\n", "200")
+
+	g := NewGauge("test_inflight", "In flight.")
+	g.Inc()
+	g.Add(3)
+	g.Dec()
+
+	idle := NewGauge("test_idle", "Never set.", "chain")
+	unlabeled := NewCounter("test_unlabeled_total", "Never set.")
+
+	tests := []struct {
+		name string
+		c    collector
+		want string
+	}{
+		{"counter", &c.v, `# HELP test_requests_total Requests.\nSecond line
+# TYPE test_requests_total counter
+test_requests_total{chain="a",code="200"} 2
+test_requests_total{chain="b",code="502"} 2.5
+test_requests_total{chain="q\"\\\n",code="200"} 1
+`},
+		{"gauge", &g.v, "# HELP test_inflight In flight.\n# TYPE test_inflight gauge\ntest_inflight 3\n"},
+		{"labeled without series", &idle.v, ""},
+		{"unlabeled without series", &unlabeled.v, "# HELP test_unlabeled_total Never set.\n# TYPE test_unlabeled_total counter\ntest_unlabeled_total 0\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := render(tt.c); got != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+	g.Set(-7)
+	if got := render(&g.v); !strings.HasSuffix(got, "test_inflight -7\n") {
+		t.Errorf("after Set: %q", got)
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	h := NewHistogram("test_latency_seconds", "Latency.", []float64{1, 0.1, 0.5}, "svc")
+	for _, v := range []float64{0.05, 0.1, 0.3, 2} {
+		h.Observe(v, "rpc")
+	}
+	want := `# HELP test_latency_seconds Latency.
+# TYPE test_latency_seconds histogram
+test_latency_seconds_bucket{svc="rpc",le="0.1"} 2
+test_latency_seconds_bucket{svc="rpc",le="0.5"} 3
+test_latency_seconds_bucket{svc="rpc",le="1"} 3
+test_latency_seconds_bucket{svc="rpc",le="+Inf"} 4
+test_latency_seconds_sum{svc="rpc"} 2.45
+test_latency_seconds_count{svc="rpc"} 4
+`
+	if got := render(h); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestFuncMetrics(t *testing.T) {
+	NewGaugeFunc("test_func_gauge", "From state.", func(emit Emit) {
+		emit(2, "b")
+		emit(1, "a")
+	}, "backend")
+	NewCounterFunc("test_func_empty_total", "Nothing yet.", func(Emit) {})
+	NewGauge("test_func_late", "Sorts after test_func_gauge.").Set(1)
+
+	var b strings.Builder
+	if err := Write(&b); err != nil {
+		t.Fatal(err)
+	}
+	out := b.String()
+	if !strings.Contains(out, "# TYPE test_func_gauge gauge\ntest_func_gauge{backend=\"a\"} 1\ntest_func_gauge{backend=\"b\"} 2\n") {
+		t.Errorf("func gauge missing or unsorted:\n%s", out)
+	}
+	if strings.Contains(out, "test_func_empty_total") {
+		t.Error("a func metric without samples must not be written")
+	}
+	if i, j := strings.Index(out, "# HELP test_func_gauge"), strings.Index(out, "# HELP test_func_late"); i < 0 || j < 0 || i > j {
+		t.Error("metrics must be written sorted by name")
+	}
+}
+
+func TestRegistryMisuse(t *testing.T) {
+	tests := []struct {
+		name string
+		fn   func()
+	}{
+		{"duplicate name", func() {
+			NewCounter("test_dup_total", "x")
+			NewGauge("test_dup_total", "x")
+		}},
+		{"label count", func() { NewCounter("test_labels_total", "x", "a").Inc() }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Error("want a panic")
+				}
+			}()
+			tt.fn()
+		})
+	}
+}
+
+func TestFormatFloat(t *testing.T) {
+	tests := []struct {
+		v    float64
+		want string
+	}{
+		{0, "0"},
+		{1, "1"},
+		{0.25, "0.25"},
+		{1e21, "1e+21"},
+		{math.Inf(1), "+Inf"},
+		{math.Inf(-1), "-Inf"},
+		{math.NaN(), "NaN"},
+	}
+	for _, tt := range tests {
+		if got := formatFloat(tt.v); got != tt.want {
+			t.Errorf("formatFloat(%v) = %q, want %q", tt.v, got, tt.want)
+		}
+	}
+}
+
+func TestHandler(t *testing.T) {
+	w := httptest.NewRecorder()
+	Handler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := w.Header().Get("Content-Type"); ct != "text/plain; version=0.0.4; charset=utf-8" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	if w.Header().Get("Cache-Control") != "no-store" {
+		t.Error("metrics must not be cached")
+	}
+}
+
+func TestExponentialBuckets(t *testing.T) {
+	got := ExponentialBuckets(0.01, 10, 3)
+	if len(got) != 3 || got[0] != 0.01 || got[1] != 0.1 || math.Abs(got[2]-1) > 1e-12 {
+		t.Errorf("ExponentialBuckets = %v", got)
+	}
+}
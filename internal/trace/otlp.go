@@ -0,0 +1,333 @@
+package trace
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	applog "github.com/vNodesV/vProx/internal/logging"
+)
+
+const (
+	queueSize     = 2048
+	batchSize     = 512
+	flushInterval = 5 * time.Second
+	exportTimeout = 10 * time.Second
+
+	grpcExportPath = "/opentelemetry.proto.collector.trace.v1.TraceService/Export"
+)
+
+// exporter batches finished spans and sends them as an OTLP
+// ExportTraceServiceRequest (protobuf) over HTTP or gRPC.
+type exporter struct {
+	url      string
+	grpc     bool
+	headers  map[string]string
+	client   *http.Client
+	resource []byte // encoded Resource message
+
+	mu      sync.RWMutex // guards closed vs. sends on queue
+	closed  bool
+	queue   chan *Span
+	done    chan struct{}
+	dropped atomic.Int64 // spans lost to a full queue since the last flush
+}
+
+func newExporter(opts Options) (*exporter, error) {
+	u, err := url.Parse(strings.TrimSpace(opts.Endpoint))
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, fmt.Errorf("trace: endpoint must be http(s)://host:port, got %q", opts.Endpoint)
+	}
+	e := &exporter{
+		headers: opts.Headers,
+		queue:   make(chan *Span, queueSize),
+		done:    make(chan struct{}),
+	}
+	tr := &http.Transport{MaxIdleConns: 4, IdleConnTimeout: 90 * time.Second}
+	switch strings.ToLower(strings.TrimSpace(opts.Protocol)) {
+	case "", "http", "http/protobuf":
+		path := strings.TrimRight(u.Path, "/")
+		if !strings.HasSuffix(path, "/v1/traces") {
+			path += "/v1/traces"
+		}
+		u.Path = path
+	case "grpc":
+		e.grpc = true
+		var protos http.Protocols
+		if u.Scheme == "http" {
+			protos.SetUnencryptedHTTP2(true)
+		} else {
+			protos.SetHTTP2(true)
+		}
+		tr.Protocols = &protos
+		u.Path = grpcExportPath
+	default:
+		return nil, fmt.Errorf("trace: protocol must be http or grpc, got %q", opts.Protocol)
+	}
+	e.url = u.String()
+	e.client = &http.Client{Transport: tr, Timeout: exportTimeout}
+
+	name := opts.ServiceName
+	if name == "" {
+		name = "vprox"
+	}
+	res := []Attr{{"service.name", name}, {"telemetry.sdk.name", "vprox"}, {"telemetry.sdk.language", "go"}}
+	if opts.Version != "" {
+		res = append(res, Attr{"service.version", opts.Version})
+	}
+	var p pb
+	for _, a := range res {
+		p.message(1, func(kv *pb) { encodeKeyValue(kv, a) })
+	}
+	e.resource = p.b
+
+	go e.loop()
+	return e, nil
+}
+
+func (e *exporter) enqueue(s *Span) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	if e.closed {
+		return
+	}
+	select {
+	case e.queue <- s:
+	default:
+		e.dropped.Add(1)
+	}
+}
+
+func (e *exporter) loop() {
+	defer close(e.done)
+	tick := time.NewTicker(flushInterval)
+	defer tick.Stop()
+	batch := make([]*Span, 0, batchSize)
+	flush := func() {
+		if n := e.dropped.Swap(0); n > 0 {
+			applog.Print("WARN", "trace", "spans_dropped", applog.F("spans", n))
+		}
+		if len(batch) == 0 {
+			return
+		}
+		if err := e.export(batch); err != nil {
+			applog.Print("WARN", "trace", "export_failed",
+				applog.F("spans", len(batch)),
+				applog.F("error", err.Error()),
+			)
+		}
+		batch = batch[:0]
+	}
+	for {
+		select {
+		case s, ok := <-e.queue:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, s)
+			if len(batch) >= batchSize {
+				flush()
+			}
+		case <-tick.C:
+			flush()
+		}
+	}
+}
+
+func (e *exporter) shutdown(ctx context.Context) error {
+	e.mu.Lock()
+	if !e.closed {
+		e.closed = true
+		close(e.queue)
+	}
+	e.mu.Unlock()
+	select {
+	case <-e.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (e *exporter) export(spans []*Span) error {
+	msg := encodeRequest(e.resource, spans)
+	var body []byte
+	ctype := "application/x-protobuf"
+	if e.grpc {
+		body = make([]byte, 5+len(msg))
+		binary.BigEndian.PutUint32(body[1:5], uint32(len(msg)))
+		copy(body[5:], msg)
+		ctype = "application/grpc"
+	} else {
+		body = msg
+	}
+	req, err := http.NewRequest(http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", ctype)
+	if e.grpc {
+		req.Header.Set("TE", "trailers")
+	}
+	for k, v := range e.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("collector answered %s", resp.Status)
+	}
+	if e.grpc {
+		st := resp.Trailer.Get("Grpc-Status")
+		if st == "" {
+			st = resp.Header.Get("Grpc-Status") // trailers-only response
+		}
+		if st != "" && st != "0" {
+			msg := resp.Trailer.Get("Grpc-Message")
+			if msg == "" {
+				msg = resp.Header.Get("Grpc-Message")
+			}
+			return errors.New("grpc-status " + st + " " + msg)
+		}
+	}
+	return nil
+}
+
+// --------- OTLP protobuf encoding ---------
+//
+// Field numbers follow opentelemetry/proto collector/trace/v1 and trace/v1.
+
+func encodeRequest(resource []byte, spans []*Span) []byte {
+	var p pb
+	p.message(1, func(rs *pb) { // ResourceSpans
+		rs.bytes(1, resource)        // Resource
+		rs.message(2, func(ss *pb) { // ScopeSpans
+			ss.message(1, func(sc *pb) { sc.str(1, "vprox") }) // InstrumentationScope
+			for _, s := range spans {
+				ss.message(2, func(sp *pb) { encodeSpan(sp, s) })
+			}
+		})
+	})
+	return p.b
+}
+
+func encodeSpan(p *pb, s *Span) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p.bytes(1, s.sc.TraceID[:])
+	p.bytes(2, s.sc.SpanID[:])
+	p.str(3, s.sc.TraceState)
+	if s.parent != [8]byte{} {
+		p.bytes(4, s.parent[:])
+	}
+	p.str(5, s.name)
+	p.varint(6, uint64(s.kind))
+	p.fixed64(7, uint64(s.start.UnixNano()))
+	p.fixed64(8, uint64(s.end.UnixNano()))
+	for _, a := range s.attrs {
+		p.message(9, func(kv *pb) { encodeKeyValue(kv, a) })
+	}
+	if s.isError {
+		p.message(15, func(st *pb) { // Status
+			st.str(2, s.errMsg)
+			st.varint(3, 2) // STATUS_CODE_ERROR
+		})
+	}
+}
+
+func encodeKeyValue(p *pb, a Attr) {
+	p.str(1, a.Key)
+	p.message(2, func(v *pb) { // AnyValue
+		switch x := a.Value.(type) {
+		case string:
+			v.strAlways(1, x)
+		case bool:
+			b := uint64(0)
+			if x {
+				b = 1
+			}
+			v.varintAlways(2, b)
+		case int:
+			v.varintAlways(3, uint64(int64(x)))
+		case int64:
+			v.varintAlways(3, uint64(x))
+		case float64:
+			v.fixed64Always(4, math.Float64bits(x))
+		default:
+			v.strAlways(1, fmt.Sprint(x))
+		}
+	})
+}
+
+// pb is a minimal protobuf writer. Scalar helpers skip zero values (proto3
+// defaults) except the *Always variants, used inside oneofs.
+type pb struct{ b []byte }
+
+func (p *pb) tag(field, wire int) { p.b = binary.AppendUvarint(p.b, uint64(field<<3|wire)) }
+
+func (p *pb) varint(field int, v uint64) {
+	if v != 0 {
+		p.varintAlways(field, v)
+	}
+}
+
+func (p *pb) varintAlways(field int, v uint64) {
+	p.tag(field, 0)
+	p.b = binary.AppendUvarint(p.b, v)
+}
+
+func (p *pb) fixed64(field int, v uint64) {
+	if v != 0 {
+		p.fixed64Always(field, v)
+	}
+}
+
+func (p *pb) fixed64Always(field int, v uint64) {
+	p.tag(field, 1)
+	p.b = binary.LittleEndian.AppendUint64(p.b, v)
+}
+
+func (p *pb) bytes(field int, b []byte) {
+	if len(b) == 0 {
+		return
+	}
+	p.tag(field, 2)
+	p.b = binary.AppendUvarint(p.b, uint64(len(b)))
+	p.b = append(p.b, b...)
+}
+
+func (p *pb) str(field int, s string) {
+	if s != "" {
+		p.strAlways(field, s)
+	}
+}
+
+func (p *pb) strAlways(field int, s string) {
+	p.tag(field, 2)
+	p.b = binary.AppendUvarint(p.b, uint64(len(s)))
+	p.b = append(p.b, s...)
+}
+
+// message writes a length-delimited sub-message built by fn.
+func (p *pb) message(field int, fn func(*pb)) {
+	var sub pb
+	fn(&sub)
+	p.tag(field, 2)
+	p.b = binary.AppendUvarint(p.b, uint64(len(sub.b)))
+	p.b = append(p.b, sub.b...)
+}
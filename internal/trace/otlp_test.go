@@ -0,0 +1,238 @@
+package trace
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"testing"
+	"time"
+)
+
+func TestPBWriter(t *testing.T) {
+	tests := []struct {
+		name  string
+		write func(p *pb)
+		want  string
+	}{
+		{"varint", func(p *pb) { p.varint(1, 150) }, "089601"},
+		{"varint zero skipped", func(p *pb) { p.varint(1, 0) }, ""},
+		{"varint always", func(p *pb) { p.varintAlways(2, 0) }, "1000"},
+		{"fixed64", func(p *pb) { p.fixed64(3, 1) }, "190100000000000000"},
+		{"fixed64 zero skipped", func(p *pb) { p.fixed64(3, 0) }, ""},
+		{"string", func(p *pb) { p.str(2, "hi") }, "12026869"},
+		{"string empty skipped", func(p *pb) { p.str(2, "") }, ""},
+		{"string always", func(p *pb) { p.strAlways(2, "") }, "1200"},
+		{"bytes", func(p *pb) { p.bytes(4, []byte{0xab}) }, "2201ab"},
+		{"bytes empty skipped", func(p *pb) { p.bytes(4, nil) }, ""},
+		{"empty message kept", func(p *pb) { p.message(1, func(*pb) {}) }, "0a00"},
+		{"nested message", func(p *pb) { p.message(1, func(s *pb) { s.varint(1, 1) }) }, "0a020801"},
+		{"two-byte tag", func(p *pb) { p.str(16, "a") }, "82010161"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var p pb
+			tt.write(&p)
+			if got := hex.EncodeToString(p.b); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEncodeKeyValue(t *testing.T) {
+	tests := []struct {
+		name string
+		attr Attr
+		want string // AnyValue after the key "k" (0a016b)
+	}{
+		{"string", A("k", "v"), "12030a0176"},
+		{"empty string", A("k", ""), "12020a00"},
+		{"true", A("k", true), "12021001"},
+		{"false", A("k", false), "12021000"},
+		{"int", A("k", 300), "120318ac02"},
+		{"negative int", A("k", -1), "120b18ffffffffffffffffff01"},
+		{"int64", A("k", int64(1)), "12021801"},
+		{"float64", A("k", 1.5), "120921000000000000f83f"},
+		{"other as string", A("k", uint(7)), "12030a0137"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var p pb
+			encodeKeyValue(&p, tt.attr)
+			if got, want := hex.EncodeToString(p.b), "0a016b"+tt.want; got != want {
+				t.Errorf("got %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+// field is one decoded protobuf field.
+type field struct {
+	num  int
+	wire int
+	v    uint64 // varint and fixed64
+	b    []byte // length-delimited
+}
+
+// decode splits a protobuf message into its fields.
+func decode(t *testing.T, b []byte) []field {
+	t.Helper()
+	var out []field
+	for len(b) > 0 {
+		key, n := binary.Uvarint(b)
+		if n <= 0 {
+			t.Fatalf("bad tag at %x", b)
+		}
+		b = b[n:]
+		f := field{num: int(key >> 3), wire: int(key & 7)}
+		switch f.wire {
+		case 0:
+			if f.v, n = binary.Uvarint(b); n <= 0 {
+				t.Fatalf("bad varint in field %d", f.num)
+			}
+			b = b[n:]
+		case 1:
+			if len(b) < 8 {
+				t.Fatalf("short fixed64 in field %d", f.num)
+			}
+			f.v, b = binary.LittleEndian.Uint64(b), b[8:]
+		case 2:
+			l, n := binary.Uvarint(b)
+			if n <= 0 || uint64(len(b)-n) < l {
+				t.Fatalf("bad length in field %d", f.num)
+			}
+			f.b, b = b[n:n+int(l)], b[n+int(l):]
+		default:
+			t.Fatalf("unexpected wire type %d in field %d", f.wire, f.num)
+		}
+		out = append(out, f)
+	}
+	return out
+}
+
+// only returns the single field num of fs.
+func only(t *testing.T, fs []field, num int) field {
+	t.Helper()
+	var got []field
+	for _, f := range fs {
+		if f.num == num {
+			got = append(got, f)
+		}
+	}
+	if len(got) != 1 {
+		t.Fatalf("field %d appears %d times, want once", num, len(got))
+	}
+	return got[0]
+}
+
+func TestEncodeSpan(t *testing.T) {
+	start := time.Unix(1700000000, 5)
+	base := func() *Span {
+		return &Span{
+			sc:    SpanContext{TraceID: [16]byte{1, 2, 3}, SpanID: [8]byte{4, 5}, TraceState: "v=1"},
+			kind:  KindServer,
+			name:  "GET /rpc",
+			start: start,
+			end:   start.Add(time.Millisecond),
+			attrs: []Attr{A("http.method", "GET"), A("http.status_code", 200)},
+		}
+	}
+	tests := []struct {
+		name       string
+		span       func() *Span
+		wantParent []byte
+		wantStatus string // error message, "" for no Status
+	}{
+		{"root", base, nil, ""},
+		{"child", func() *Span { s := base(); s.parent = [8]byte{9}; return s }, []byte{9, 0, 0, 0, 0, 0, 0, 0}, ""},
+		{"error", func() *Span { s := base(); s.isError, s.errMsg = true, "upstream timeout"; return s }, nil, "upstream timeout"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := tt.span()
+			var p pb
+			encodeSpan(&p, s)
+			fs := decode(t, p.b)
+
+			if f := only(t, fs, 1); !bytes.Equal(f.b, s.sc.TraceID[:]) {
+				t.Errorf("trace_id = %x", f.b)
+			}
+			if f := only(t, fs, 2); !bytes.Equal(f.b, s.sc.SpanID[:]) {
+				t.Errorf("span_id = %x", f.b)
+			}
+			if f := only(t, fs, 3); string(f.b) != "v=1" {
+				t.Errorf("trace_state = %q", f.b)
+			}
+			if f := only(t, fs, 5); string(f.b) != "GET /rpc" {
+				t.Errorf("name = %q", f.b)
+			}
+			if f := only(t, fs, 6); f.wire != 0 || f.v != uint64(KindServer) {
+				t.Errorf("kind = %d (wire %d)", f.v, f.wire)
+			}
+			if f := only(t, fs, 7); f.wire != 1 || f.v != uint64(start.UnixNano()) {
+				t.Errorf("start = %d (wire %d)", f.v, f.wire)
+			}
+			if f := only(t, fs, 8); f.v != uint64(start.Add(time.Millisecond).UnixNano()) {
+				t.Errorf("end = %d", f.v)
+			}
+			var attrs []string
+			for _, f := range fs {
+				if f.num == 9 {
+					attrs = append(attrs, string(only(t, decode(t, f.b), 1).b))
+				}
+			}
+			if len(attrs) != 2 || attrs[0] != "http.method" || attrs[1] != "http.status_code" {
+				t.Errorf("attributes = %q", attrs)
+			}
+
+			var parent []byte
+			var status []field
+			for _, f := range fs {
+				switch f.num {
+				case 4:
+					parent = f.b
+				case 15:
+					status = decode(t, f.b)
+				}
+			}
+			if !bytes.Equal(parent, tt.wantParent) {
+				t.Errorf("parent_span_id = %x, want %x", parent, tt.wantParent)
+			}
+			switch {
+			case tt.wantStatus == "" && status != nil:
+				t.Errorf("unexpected status %v", status)
+			case tt.wantStatus != "":
+				if f := only(t, status, 2); string(f.b) != tt.wantStatus {
+					t.Errorf("status message = %q", f.b)
+				}
+				if f := only(t, status, 3); f.v != 2 {
+					t.Errorf("status code = %d, want 2 (ERROR)", f.v)
+				}
+			}
+		})
+	}
+}
+
+func TestEncodeRequest(t *testing.T) {
+	resource := []byte{0x0a, 0x00}
+	spans := []*Span{{name: "a"}, {name: "b"}}
+	fs := decode(t, encodeRequest(resource, spans))
+
+	rs := decode(t, only(t, fs, 1).b) // ResourceSpans
+	if got := only(t, rs, 1).b; !bytes.Equal(got, resource) {
+		t.Errorf("resource = %x, want %x", got, resource)
+	}
+	ss := decode(t, only(t, rs, 2).b) // ScopeSpans
+	if scope := decode(t, only(t, ss, 1).b); string(only(t, scope, 1).b) != "vprox" {
+		t.Errorf("scope = %v", scope)
+	}
+	var names []string
+	for _, f := range ss {
+		if f.num == 2 {
+			names = append(names, string(only(t, decode(t, f.b), 5).b))
+		}
+	}
+	if len(names) != 2 || names[0] != "a" || names[1] != "b" {
+		t.Errorf("span names = %q", names)
+	}
+}
@@ -0,0 +1,301 @@
+// Package trace is a small OpenTelemetry-compatible tracer: W3C trace context
+// (traceparent/tracestate) propagation, spans with attributes, parent-based
+// ratio sampling and a batching OTLP exporter (HTTP or gRPC, protobuf).
+// Until Init is called every function is a cheap no-op and spans are nil;
+// all *Span methods are nil-safe.
+package trace
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Kind is the OTLP span kind.
+type Kind int
+
+const (
+	KindInternal Kind = 1
+	KindServer   Kind = 2
+	KindClient   Kind = 3
+)
+
+// SpanContext identifies a span across process boundaries.
+type SpanContext struct {
+	TraceID    [16]byte
+	SpanID     [8]byte
+	Sampled    bool
+	TraceState string
+}
+
+// Valid reports whether both IDs are non-zero.
+func (sc SpanContext) Valid() bool {
+	return sc.TraceID != [16]byte{} && sc.SpanID != [8]byte{}
+}
+
+// Traceparent formats sc as a W3C traceparent header value.
+func (sc SpanContext) Traceparent() string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return "00-" + hex.EncodeToString(sc.TraceID[:]) + "-" + hex.EncodeToString(sc.SpanID[:]) + "-" + flags
+}
+
+// ParseTraceparent parses a W3C traceparent header value (version 00, or a
+// higher version read as 00).
+func ParseTraceparent(v string) (SpanContext, bool) {
+	var sc SpanContext
+	parts := strings.Split(strings.TrimSpace(v), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" ||
+		len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 ||
+		parts[0] == "00" && len(parts) != 4 {
+		return sc, false
+	}
+	if _, err := hex.Decode(sc.TraceID[:], []byte(parts[1])); err != nil {
+		return sc, false
+	}
+	if _, err := hex.Decode(sc.SpanID[:], []byte(parts[2])); err != nil {
+		return sc, false
+	}
+	var flags [1]byte
+	if _, err := hex.Decode(flags[:], []byte(parts[3])); err != nil {
+		return sc, false
+	}
+	sc.Sampled = flags[0]&1 == 1
+	return sc, sc.Valid()
+}
+
+// Attr is a span attribute; Value is a string, bool, int, int64 or float64.
+type Attr struct {
+	Key   string
+	Value any
+}
+
+// A builds an Attr.
+func A(key string, v any) Attr { return Attr{key, v} }
+
+// Span is one timed operation. Spans that are not sampled still carry IDs
+// (so context propagates) but are not exported.
+type Span struct {
+	sc     SpanContext
+	parent [8]byte
+	kind   Kind
+
+	mu       sync.Mutex
+	name     string
+	start    time.Time
+	end      time.Time
+	attrs    []Attr
+	errMsg   string
+	isError  bool
+	finished bool
+}
+
+// Context returns the span's context (zero for a nil span).
+func (s *Span) Context() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.sc
+}
+
+// TraceID returns the hex trace ID of a sampled span, or "".
+func (s *Span) TraceID() string {
+	if s == nil || !s.sc.Sampled {
+		return ""
+	}
+	return hex.EncodeToString(s.sc.TraceID[:])
+}
+
+// SetName renames the span (e.g. once the route is known).
+func (s *Span) SetName(name string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.name = name
+	s.mu.Unlock()
+}
+
+// SetAttr sets (or replaces) an attribute.
+func (s *Span) SetAttr(key string, v any) {
+	if s == nil || !s.sc.Sampled {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.attrs {
+		if s.attrs[i].Key == key {
+			s.attrs[i].Value = v
+			return
+		}
+	}
+	s.attrs = append(s.attrs, Attr{key, v})
+}
+
+// SetError marks the span as failed.
+func (s *Span) SetError(msg string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.isError, s.errMsg = true, msg
+	s.mu.Unlock()
+}
+
+// End finishes the span and queues it for export. Later calls are ignored.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.finished {
+		s.mu.Unlock()
+		return
+	}
+	s.finished = true
+	s.end = time.Now()
+	s.mu.Unlock()
+	if s.sc.Sampled {
+		if e := exp.Load(); e != nil {
+			e.enqueue(s)
+		}
+	}
+}
+
+// --------- Tracer state ---------
+
+type (
+	spanKey   struct{}
+	remoteKey struct{}
+)
+
+var (
+	exp         atomic.Pointer[exporter]
+	sampleRatio atomic.Uint64 // ratio * 1e6 for root spans
+)
+
+// Enabled reports whether Init has configured an exporter.
+func Enabled() bool { return exp.Load() != nil }
+
+// FromContext returns the span in ctx, or nil.
+func FromContext(ctx context.Context) *Span {
+	s, _ := ctx.Value(spanKey{}).(*Span)
+	return s
+}
+
+// Extract reads traceparent/tracestate from h and records them in ctx as
+// the remote parent of the next span started from it.
+func Extract(ctx context.Context, h http.Header) context.Context {
+	sc, ok := ParseTraceparent(h.Get("Traceparent"))
+	if !ok {
+		return ctx
+	}
+	sc.TraceState = strings.TrimSpace(strings.Join(h.Values("Tracestate"), ","))
+	return context.WithValue(ctx, remoteKey{}, sc)
+}
+
+// Inject writes the context of the span in ctx as traceparent/tracestate.
+// Without an active span h is left untouched, so incoming headers pass
+// through unchanged.
+func Inject(ctx context.Context, h http.Header) {
+	s := FromContext(ctx)
+	if s == nil {
+		return
+	}
+	h.Set("Traceparent", s.sc.Traceparent())
+	if s.sc.TraceState != "" {
+		h.Set("Tracestate", s.sc.TraceState)
+	} else {
+		h.Del("Tracestate")
+	}
+}
+
+// Start begins a span as a child of the span (or remote parent) in ctx, or
+// as a new root. It returns ctx carrying the span. With tracing disabled it
+// returns ctx and nil.
+func Start(ctx context.Context, name string, kind Kind, attrs ...Attr) (context.Context, *Span) {
+	if !Enabled() {
+		return ctx, nil
+	}
+	s := &Span{kind: kind, name: name, start: time.Now()}
+	var parent SpanContext
+	if p := FromContext(ctx); p != nil {
+		parent = p.sc
+	} else if rc, ok := ctx.Value(remoteKey{}).(SpanContext); ok {
+		parent = rc
+	}
+	if parent.Valid() {
+		s.sc.TraceID = parent.TraceID
+		s.sc.Sampled = parent.Sampled
+		s.sc.TraceState = parent.TraceState
+		s.parent = parent.SpanID
+	} else {
+		fillRandom(s.sc.TraceID[:])
+		s.sc.Sampled = rand.Uint64N(1_000_000) < sampleRatio.Load()
+	}
+	fillRandom(s.sc.SpanID[:])
+	if s.sc.Sampled {
+		s.attrs = append(s.attrs, attrs...)
+	}
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+func fillRandom(b []byte) {
+	for {
+		for i := 0; i < len(b); i += 8 {
+			v := rand.Uint64()
+			for j := i; j < len(b) && j < i+8; j++ {
+				b[j] = byte(v)
+				v >>= 8
+			}
+		}
+		for _, c := range b {
+			if c != 0 {
+				return
+			}
+		}
+	}
+}
+
+// Options configures Init.
+type Options struct {
+	Endpoint    string            // collector base URL, e.g. http://otel:4318 (http) or http://otel:4317 (grpc)
+	Protocol    string            // "http" (OTLP/HTTP protobuf, default) or "grpc"
+	Headers     map[string]string // extra request headers (e.g. authorization)
+	ServiceName string            // resource service.name
+	Version     string            // resource service.version
+	SampleRatio float64           // share of new root traces recorded, 0-1 (children follow the parent)
+}
+
+// Init starts the exporter. Calling it again replaces the configuration.
+func Init(opts Options) error {
+	e, err := newExporter(opts)
+	if err != nil {
+		return err
+	}
+	r := opts.SampleRatio
+	if r < 0 || r > 1 {
+		return fmt.Errorf("trace: sample ratio %v out of range 0-1", r)
+	}
+	sampleRatio.Store(uint64(r * 1_000_000))
+	if old := exp.Swap(e); old != nil {
+		_ = old.shutdown(context.Background())
+	}
+	return nil
+}
+
+// Shutdown flushes queued spans and stops the exporter.
+func Shutdown(ctx context.Context) error {
+	if e := exp.Swap(nil); e != nil {
+		return e.shutdown(ctx)
+	}
+	return nil
+}
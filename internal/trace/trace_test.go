@@ -0,0 +1,33 @@
+package trace
+
+import "testing"
+
+func TestParseTraceparent(t *testing.T) {
+	const tid, sid = "4bf92f3577b34da6a3ce929d0e0e4736", "00f067aa0ba902b7"
+	tests := []struct {
+		in          string
+		ok, sampled bool
+	}{
+		{"00-" + tid + "-" + sid + "-01", true, true},
+		{"00-" + tid + "-" + sid + "-00", true, false},
+		{" 00-" + tid + "-" + sid + "-03 ", true, true},
+		{"01-" + tid + "-" + sid + "-01-future", true, true},
+		{"00-" + tid + "-" + sid + "-01-extra", false, false},
+		{"ff-" + tid + "-" + sid + "-01", false, false},
+		{"00-00000000000000000000000000000000-" + sid + "-01", false, false},
+		{"00-" + tid + "-0000000000000000-01", false, false},
+		{"00-" + tid[:31] + "-" + sid + "-01", false, false},
+		{"00-" + tid + "-" + sid + "-zz", false, false},
+		{"", false, false},
+	}
+	for _, tt := range tests {
+		sc, ok := ParseTraceparent(tt.in)
+		if ok != tt.ok || ok && sc.Sampled != tt.sampled {
+			t.Errorf("ParseTraceparent(%q) = sampled %v, ok %v; want %v, %v", tt.in, sc.Sampled, ok, tt.sampled, tt.ok)
+			continue
+		}
+		if ok && sc.Traceparent() != "00-"+tid+"-"+sid+"-"+map[bool]string{true: "01", false: "00"}[tt.sampled] {
+			t.Errorf("round trip of %q = %q", tt.in, sc.Traceparent())
+		}
+	}
+}
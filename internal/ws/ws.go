@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net"
 	"net/http"
+	"net/url"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -11,6 +12,18 @@ import (
 
 	"github.com/gorilla/websocket"
 	applog "github.com/vNodesV/vProx/internal/logging"
+	"github.com/vNodesV/vProx/internal/metrics"
+	"github.com/vNodesV/vProx/internal/trace"
+)
+
+var (
+	sessionsActive = metrics.NewGauge("vprox_ws_sessions_active", "Open proxied WebSocket sessions.")
+	sessionsTotal  = metrics.NewCounter("vprox_ws_sessions_total",
+		"WebSocket upgrade attempts by result (connected, denied, upgrade_failed, backend_failed).", "result")
+	sessionSeconds = metrics.NewHistogram("vprox_ws_session_duration_seconds",
+		"Duration of closed WebSocket sessions.", metrics.ExponentialBuckets(1, 4, 9))
+	wsBytes = metrics.NewCounter("vprox_ws_bytes_total",
+		"WebSocket payload bytes relayed, by direction (up = client to backend).", "direction")
 )
 
 // Deps abstracts what we need from main without importing it.
@@ -24,8 +37,14 @@ type Deps struct {
 	LogRequestSummary func(r *http.Request, proxied bool, route string, host string, start time.Time)
 
 	// BackendWSParams returns backend WS URL + timeouts, or ok=false if WS isn't enabled for this host.
-	// Example return: ("ws://10.0.0.13:26657/websocket", 300s, 0s, true)
-	BackendWSParams func(host string) (backendURL string, idle time.Duration, hard time.Duration, ok bool)
+	// done (may be nil) is called once the session ends so the caller can release the backend.
+	// Example return: ("ws://10.0.0.13:26657/websocket", 300s, 0s, release, true)
+	BackendWSParams func(host string) (backendURL string, idle time.Duration, hard time.Duration, done func(), ok bool)
+
+	// CheckMessage (optional) vets a client data frame before it is relayed.
+	// A non-nil reply is sent back to the client instead and the frame is
+	// dropped (e.g. a JSON-RPC error for a denied method).
+	CheckMessage func(r *http.Request, p []byte) (reply []byte)
 }
 
 var upgrader = websocket.Upgrader{
@@ -47,12 +66,16 @@ func HandleWS(d Deps) http.HandlerFunc {
 		requestID := wssID
 		applog.SetResponseRequestID(w, requestID)
 
-		backendURL, idle, hard, ok := d.BackendWSParams(host)
+		backendURL, idle, hard, done, ok := d.BackendWSParams(host)
 		if !ok {
 			http.Error(w, "WebSocket not enabled", http.StatusNotFound)
+			sessionsTotal.Inc("denied")
 			d.LogRequestSummary(r, false, "ws-deny", host, start)
 			return
 		}
+		if done != nil {
+			defer done()
+		}
 
 		// Upgrade client side (echo request id in handshake response headers)
 		respHdr := http.Header{}
@@ -61,6 +84,7 @@ func HandleWS(d Deps) http.HandlerFunc {
 		}
 		cConn, err := upgrader.Upgrade(w, r, respHdr)
 		if err != nil {
+			sessionsTotal.Inc("upgrade_failed")
 			d.LogRequestSummary(r, false, "ws-upgrade-fail", host, start)
 			return
 		}
@@ -73,14 +97,28 @@ func HandleWS(d Deps) http.HandlerFunc {
 		if requestID != "" {
 			hdr.Set(applog.RequestIDHeader, requestID)
 		}
+		// Trace context: pass the client's through, or continue it from the
+		// session span when tracing is on.
+		for _, k := range []string{"Traceparent", "Tracestate"} {
+			if v := r.Header.Get(k); v != "" {
+				hdr.Set(k, v)
+			}
+		}
+		ctx, span := trace.Start(r.Context(), "websocket session", trace.KindClient,
+			trace.A("server.address", backendHost(backendURL)),
+		)
+		defer span.End()
+		trace.Inject(ctx, hdr)
 
 		bConn, _, err := websocket.DefaultDialer.Dial(backendURL, hdr)
 		if err != nil {
+			span.SetError(err.Error())
 			_ = cConn.WriteControl(
 				websocket.CloseMessage,
 				websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "backend unreachable"),
 				time.Now().Add(2*time.Second),
 			)
+			sessionsTotal.Inc("backend_failed")
 			d.LogRequestSummary(r, false, "ws-backend-fail", host, start)
 			return
 		}
@@ -88,6 +126,9 @@ func HandleWS(d Deps) http.HandlerFunc {
 
 		// Emit CONNECTED log now that both sides are up.
 		d.LogRequestSummary(r, true, "websocket", host, start)
+		sessionsTotal.Inc("connected")
+		sessionsActive.Inc()
+		defer sessionsActive.Dec()
 
 		// Defaults
 		if idle <= 0 {
@@ -130,6 +171,7 @@ func HandleWS(d Deps) http.HandlerFunc {
 		var upBytes int64   // client -> backend
 		var downBytes int64 // backend -> client
 		var wg sync.WaitGroup
+		var cWrite sync.Mutex // both pumps write to the client
 
 		// client -> backend
 		wg.Add(1)
@@ -142,6 +184,19 @@ func HandleWS(d Deps) http.HandlerFunc {
 					return
 				}
 				extendDeadline(cConn)
+				if d.CheckMessage != nil && (mt == websocket.TextMessage || mt == websocket.BinaryMessage) {
+					if reply := d.CheckMessage(r, p); reply != nil {
+						cWrite.Lock()
+						_ = cConn.SetWriteDeadline(time.Now().Add(idle))
+						writeErr := cConn.WriteMessage(websocket.TextMessage, reply)
+						cWrite.Unlock()
+						if writeErr != nil {
+							errc <- writeErr
+							return
+						}
+						continue
+					}
+				}
 				_ = bConn.SetWriteDeadline(time.Now().Add(idle))
 
 				if writeErr := bConn.WriteMessage(mt, p); writeErr != nil {
@@ -149,6 +204,7 @@ func HandleWS(d Deps) http.HandlerFunc {
 					return
 				}
 				atomic.AddInt64(&upBytes, int64(len(p)))
+				wsBytes.Add(float64(len(p)), "up")
 			}
 		}()
 
@@ -163,13 +219,16 @@ func HandleWS(d Deps) http.HandlerFunc {
 					return
 				}
 				extendDeadline(bConn)
+				cWrite.Lock()
 				_ = cConn.SetWriteDeadline(time.Now().Add(idle))
-
-				if writeErr := cConn.WriteMessage(mt, p); writeErr != nil {
+				writeErr := cConn.WriteMessage(mt, p)
+				cWrite.Unlock()
+				if writeErr != nil {
 					errc <- writeErr
 					return
 				}
 				atomic.AddInt64(&downBytes, int64(len(p)))
+				wsBytes.Add(float64(len(p)), "down")
 			}
 		}()
 
@@ -202,10 +261,14 @@ func HandleWS(d Deps) http.HandlerFunc {
 
 		// Emit UPD with session stats.
 		dur := time.Since(start)
+		sessionSeconds.Observe(dur.Seconds())
 		up := atomic.LoadInt64(&upBytes)
 		down := atomic.LoadInt64(&downBytes)
 		total := up + down
-		applog.PrintLifecycle("UPD", "ws",
+		span.SetAttr("vprox.ws.close_reason", cause)
+		span.SetAttr("vprox.ws.bytes_up", up)
+		span.SetAttr("vprox.ws.bytes_down", down)
+		fields := []applog.Field{
 			applog.F("ID", requestID),
 			applog.F("status", "CLOSED"),
 			applog.F("reason", strings.ToUpper(cause)),
@@ -213,8 +276,19 @@ func HandleWS(d Deps) http.HandlerFunc {
 			applog.F("upload", humanBytes(up)),
 			applog.F("download", humanBytes(down)),
 			applog.F("averageRate", humanRate(total, dur)),
-		)
+		}
+		if id := span.TraceID(); id != "" {
+			fields = append(fields, applog.F("traceID", id))
+		}
+		applog.PrintLifecycle("UPD", "ws", fields...)
+	}
+}
+
+func backendHost(rawURL string) string {
+	if u, err := url.Parse(rawURL); err == nil {
+		return u.Host
 	}
+	return ""
 }
 
 func classifyWSCause(err error) string {